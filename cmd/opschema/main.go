@@ -0,0 +1,22 @@
+// Command opschema writes the JSON Schema of model.Op.
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+
+	"github.com/smarterwallet/demand-abstraction-serv/model"
+)
+
+func main() {
+	out := flag.String("o", "op.schema.json", "output file")
+	flag.Parse()
+	schema, err := model.OpSchema()
+	if err != nil {
+		log.Fatalf("generate op schema: %v", err)
+	}
+	if err := os.WriteFile(*out, append(schema, '\n'), 0o644); err != nil {
+		log.Fatalf("write op schema: %v", err)
+	}
+}
@@ -14,9 +14,35 @@ type Config struct {
 	CrossEndpoint  string    `json:"crossChainEndpoint"`
 	SwapEndpoint   string    `json:"swapEndpoint"`
 	ConfigEndpoint string    `json:"configEndpoint"`
+	// ChainAliases is a json file of chain aliases replacing the bundled ones.
+	ChainAliases string `json:"chainAliases"`
+	// TokenAliases is a json file of token aliases replacing the bundled ones.
+	TokenAliases string `json:"tokenAliases"`
+	// DefaultSlippageBps bounds swaps of wallets without their own slippage.
+	DefaultSlippageBps int `json:"defaultSlippageBps"`
+	// QuoteTTL is how long swap quotes of a plan hold.
+	QuoteTTL time.Duration `json:"quoteTTL"`
 }
 
 type AiConfig struct {
+	Endpoint    string        `json:"endpoint"`
+	Model       string        `json:"model"`
+	APIKey      string        `json:"apikey"`
+	Timeout     time.Duration `json:"timeout"`
+	Providers   []string      `json:"providers"`
+	Local       *LocalAiCfg   `json:"local"`
+	MockFixture string        `json:"mock_fixture"`
+	// RepairAttempts is how many times invalid function arguments are sent
+	// back to the llm for correction; 0 disables repair and unset uses the
+	// default.
+	RepairAttempts *int `json:"repair_attempts"`
+	// IntentThreshold is the classifier confidence below which the llm
+	// chooses the strategy.
+	IntentThreshold float64 `json:"intent_threshold"`
+}
+
+// LocalAiCfg points at an OpenAI compatible server such as Ollama.
+type LocalAiCfg struct {
 	Endpoint string `json:"endpoint"`
 	Model    string `json:"model"`
 	APIKey   string `json:"apikey"`
@@ -49,9 +75,21 @@ func LoadConfig(cfg interface{}) error {
 	_ = viper.BindEnv("CROSSENDPOINT")
 	_ = viper.BindEnv("SWAPENDPOINT")
 	_ = viper.BindEnv("CONFIGENDPOINT")
+	_ = viper.BindEnv("CHAINALIASES")
+	_ = viper.BindEnv("TOKENALIASES")
+	_ = viper.BindEnv("DEFAULTSLIPPAGEBPS")
+	_ = viper.BindEnv("QUOTETTL")
 	_ = viper.BindEnv("AICONFIG.ENDPOINT")
 	_ = viper.BindEnv("AICONFIG.MODEL")
 	_ = viper.BindEnv("AICONFIG.APIKEY")
+	_ = viper.BindEnv("AICONFIG.TIMEOUT")
+	_ = viper.BindEnv("AICONFIG.PROVIDERS")
+	_ = viper.BindEnv("AICONFIG.LOCAL.ENDPOINT")
+	_ = viper.BindEnv("AICONFIG.LOCAL.MODEL")
+	_ = viper.BindEnv("AICONFIG.LOCAL.APIKEY")
+	_ = viper.BindEnv("AICONFIG.MOCKFIXTURE")
+	_ = viper.BindEnv("AICONFIG.REPAIRATTEMPTS")
+	_ = viper.BindEnv("AICONFIG.INTENTTHRESHOLD")
 	_ = viper.BindEnv("REDIS.ADDR")
 	_ = viper.BindEnv("REDIS.PASSWORD")
 
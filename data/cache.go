@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"sort"
+	"strings"
 	"time"
 
 	log "github.com/cihub/seelog"
@@ -18,6 +19,17 @@ type Cache struct {
 	client *redis.Client
 }
 
+const (
+	// maxHistoryDialogues bounds how many past dialogues are kept and replayed
+	// to the llm.
+	maxHistoryDialogues = 20
+	// conversationExpiration is how long a conversation is kept after its last
+	// dialogue.
+	conversationExpiration = 24 * time.Hour
+)
+
+// keyConversation is the list of the dialogues of a conversation in order.
+// Conversations stored before were sets, see migrateConversation.
 func keyConversation(cid string) string {
 	return "smart-wallet-conversation:" + cid
 }
@@ -63,11 +75,14 @@ func NewCache(redisCfg *config.RedisCfg) (*Cache, error) {
 }
 
 func (c *Cache) ChatHistory(ctx context.Context, cid string) ([]model.Dialogue, error) {
-	res, err := c.client.SMembers(ctx, keyConversation(cid)).Result()
+	if err := c.migrateConversation(ctx, cid); err != nil {
+		return nil, err
+	}
+	res, err := c.client.LRange(ctx, keyConversation(cid), -maxHistoryDialogues, -1).Result()
 	if err != nil {
 		return nil, err
 	}
-	dialogues := make([]model.Dialogue, 0)
+	dialogues := make([]model.Dialogue, 0, len(res))
 	for _, s := range res {
 		var dialogue model.Dialogue
 		if err := json.Unmarshal([]byte(s), &dialogue); err != nil {
@@ -75,27 +90,82 @@ func (c *Cache) ChatHistory(ctx context.Context, cid string) ([]model.Dialogue,
 		}
 		dialogues = append(dialogues, dialogue)
 	}
-	sort.Slice(dialogues, func(i, j int) bool {
-		return dialogues[i].Timestamp < dialogues[j].Timestamp
-	})
 	return dialogues, nil
 }
 
 func (c *Cache) AppendChat(ctx context.Context, cid, content, role string) error {
-	var dialogue = &model.Dialogue{
-		Type:      "text",
-		Role:      role,
-		Content:   content,
-		Timestamp: time.Now().Unix(),
+	return c.AppendDialogues(ctx, cid, &model.Dialogue{
+		Type:    model.DialogueText,
+		Role:    role,
+		Content: content,
+	})
+}
+
+// AppendDialogues appends dialogues to the conversation in order.
+func (c *Cache) AppendDialogues(ctx context.Context, cid string, dialogues ...*model.Dialogue) error {
+	if len(dialogues) == 0 {
+		return nil
+	}
+	now := time.Now().Unix()
+	values := make([]interface{}, 0, len(dialogues))
+	for _, dialogue := range dialogues {
+		if dialogue.Timestamp == 0 {
+			dialogue.Timestamp = now
+		}
+		values = append(values, dialogue)
+	}
+	if err := c.migrateConversation(ctx, cid); err != nil {
+		return err
+	}
+	key := keyConversation(cid)
+	_, err := c.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.RPush(ctx, key, values...)
+		pipe.LTrim(ctx, key, -maxHistoryDialogues, -1)
+		pipe.Expire(ctx, key, conversationExpiration)
+		return nil
+	})
+	return err
+}
+
+// migrateConversation turns a conversation stored as a set of dialogues into
+// a list ordered by timestamp.
+func (c *Cache) migrateConversation(ctx context.Context, cid string) error {
+	key := keyConversation(cid)
+	kind, err := c.client.Type(ctx, key).Result()
+	if err != nil || kind != "set" {
+		return err
 	}
-	res, err := c.client.SAdd(ctx, keyConversation(cid), dialogue).Result()
+	members, err := c.client.SMembers(ctx, key).Result()
 	if err != nil {
 		return err
 	}
-	if res == 0 {
-		return errors.New("duplicate conversation")
+	timestamps := make(map[string]int64, len(members))
+	for _, member := range members {
+		var dialogue model.Dialogue
+		if err := json.Unmarshal([]byte(member), &dialogue); err != nil {
+			return err
+		}
+		timestamps[member] = dialogue.Timestamp
 	}
-	return nil
+	sort.SliceStable(members, func(i, j int) bool {
+		return timestamps[members[i]] < timestamps[members[j]]
+	})
+	if len(members) > maxHistoryDialogues {
+		members = members[len(members)-maxHistoryDialogues:]
+	}
+	values := make([]interface{}, 0, len(members))
+	for _, member := range members {
+		values = append(values, member)
+	}
+	_, err = c.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, key)
+		if len(values) > 0 {
+			pipe.RPush(ctx, key, values...)
+			pipe.Expire(ctx, key, conversationExpiration)
+		}
+		return nil
+	})
+	return err
 }
 
 func keyCtx(key string) string {
@@ -120,5 +190,95 @@ func (c *Cache) GetCtx(ctx context.Context, key string) (string, error) {
 }
 
 func (c *Cache) Invalid(ctx context.Context, cid string) error {
-	return c.client.Del(ctx, keyConversation(cid)).Err()
+	return c.client.Del(ctx, keyConversation(cid), keyPending(cid)).Err()
+}
+
+// pendingExpiration bounds how long a clarification question stays answerable.
+const pendingExpiration = 10 * time.Minute
+
+func keyPending(cid string) string {
+	return "smart-wallet-pending:" + cid
+}
+
+func (c *Cache) SetPending(ctx context.Context, cid string, pending *model.PendingIntent) error {
+	return c.client.Set(ctx, keyPending(cid), pending, pendingExpiration).Err()
+}
+
+// GetPending returns the intent waiting for an answer, or nil when there is none.
+func (c *Cache) GetPending(ctx context.Context, cid string) (*model.PendingIntent, error) {
+	res, err := c.client.Get(ctx, keyPending(cid)).Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	pending := &model.PendingIntent{}
+	dec := json.NewDecoder(strings.NewReader(res))
+	dec.UseNumber()
+	if err := dec.Decode(pending); err != nil {
+		return nil, err
+	}
+	return pending, nil
+}
+
+func (c *Cache) ClearPending(ctx context.Context, cid string) error {
+	return c.client.Del(ctx, keyPending(cid)).Err()
+}
+
+func keyContacts(wallet string) string {
+	return "smart-wallet-contacts:" + strings.ToLower(wallet)
+}
+
+func contactField(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// Contacts returns the address book of wallet sorted by name.
+func (c *Cache) Contacts(ctx context.Context, wallet string) ([]model.Contact, error) {
+	res, err := c.client.HGetAll(ctx, keyContacts(wallet)).Result()
+	if err != nil {
+		return nil, err
+	}
+	contacts := make([]model.Contact, 0, len(res))
+	for _, s := range res {
+		var contact model.Contact
+		if err := json.Unmarshal([]byte(s), &contact); err != nil {
+			return nil, err
+		}
+		contacts = append(contacts, contact)
+	}
+	sort.Slice(contacts, func(i, j int) bool {
+		return contactField(contacts[i].Name) < contactField(contacts[j].Name)
+	})
+	return contacts, nil
+}
+
+// GetContact returns the contact of wallet named name, or nil when there is none.
+func (c *Cache) GetContact(ctx context.Context, wallet, name string) (*model.Contact, error) {
+	res, err := c.client.HGet(ctx, keyContacts(wallet), contactField(name)).Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	contact := &model.Contact{}
+	if err := json.Unmarshal([]byte(res), contact); err != nil {
+		return nil, err
+	}
+	return contact, nil
+}
+
+// SetContact adds or replaces the contact of wallet with the same name, names
+// being case insensitive.
+func (c *Cache) SetContact(ctx context.Context, wallet string, contact *model.Contact) error {
+	return c.client.HSet(ctx, keyContacts(wallet), contactField(contact.Name), contact).Err()
+}
+
+// DeleteContact removes the contact of wallet named name and reports whether it
+// existed.
+func (c *Cache) DeleteContact(ctx context.Context, wallet, name string) (bool, error) {
+	n, err := c.client.HDel(ctx, keyContacts(wallet), contactField(name)).Result()
+	return n > 0, err
 }
@@ -1,16 +1,336 @@
 package data
 
 import (
+	_ "embed"
+	"encoding/json"
 	"fmt"
+	"os"
+	"sort"
 	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/smarterwallet/demand-abstraction-serv/utils"
 )
 
-var ChainIDMap = make(map[string]int)
+//go:embed chains.json
+var defaultChainAliases []byte
+
+// Chain is a chain known by name. ID and NetworkId are only set for chains of
+// the loaded asset config.
+type Chain struct {
+	// Name is the canonical name, the lowercased name of the asset config.
+	Name      string `json:"name"`
+	ID        int    `json:"id,omitempty"`
+	NetworkId int    `json:"network_id,omitempty"`
+	Native    string `json:"native"`
+	Testnet   bool   `json:"testnet,omitempty"`
+	// Pair is the testnet of a mainnet and the other way around.
+	Pair    string   `json:"pair,omitempty"`
+	Aliases []string `json:"aliases,omitempty"`
+	// Family is the address format of the chain, empty for EVM chains.
+	Family string `json:"family,omitempty"`
+}
+
+// Loaded reports whether the chain comes from the asset config.
+func (c Chain) Loaded() bool {
+	return c.ID != 0
+}
+
+// ChainRegistry resolves the chain names users and the llm come up with to the
+// chains of the asset config. It is only written while a Snapshot is built and
+// read-only once published.
+type ChainRegistry struct {
+	chains []*Chain
+	index  map[string]*Chain
+}
+
+// LoadChainAliases reads the chain aliases from path, or the bundled aliases
+// when path is empty.
+func LoadChainAliases(path string) ([]Chain, error) {
+	buf := defaultChainAliases
+	if path != "" {
+		var err error
+		if buf, err = os.ReadFile(path); err != nil {
+			return nil, errors.Wrap(err, "read chain aliases")
+		}
+	}
+	var chains []Chain
+	if err := json.Unmarshal(buf, &chains); err != nil {
+		return nil, errors.Wrap(err, "parse chain aliases")
+	}
+	for i, chain := range chains {
+		if normalizeChain(chain.Name) == "" {
+			return nil, errors.Errorf("chain alias %d: missing name", i)
+		}
+	}
+	return chains, nil
+}
+
+// NewChainRegistry returns a registry knowing chains by name and alias. None of
+// them resolves before it is registered from the asset config.
+func NewChainRegistry(chains []Chain) *ChainRegistry {
+	r := &ChainRegistry{index: make(map[string]*Chain)}
+	for _, chain := range chains {
+		c := chain
+		c.Name = strings.ToLower(strings.TrimSpace(c.Name))
+		c.Native = strings.ToUpper(c.Native)
+		c.Aliases = append([]string(nil), c.Aliases...)
+		c.ID, c.NetworkId = 0, 0
+		r.add(&c)
+	}
+	return r
+}
+
+func (r *ChainRegistry) add(c *Chain) {
+	r.chains = append(r.chains, c)
+	r.indexName(c, c.Name)
+	for _, alias := range c.Aliases {
+		r.indexName(c, alias)
+	}
+}
 
-func GetChainIdByName(chainName string) (int, error) {
-	chainId, ok := ChainIDMap[strings.ToLower(chainName)]
+func (r *ChainRegistry) indexName(c *Chain, name string) {
+	key := normalizeChain(name)
+	if _, ok := r.index[key]; !ok && key != "" {
+		r.index[key] = c
+	}
+}
+
+// Register records a chain of the asset config. A chain already known by name
+// or alias takes name as its canonical name; an empty native keeps the symbol
+// of the aliases.
+func (r *ChainRegistry) Register(name string, id, networkId int, native string) {
+	canonical := strings.ToLower(strings.TrimSpace(name))
+	c, ok := r.index[normalizeChain(name)]
+	if !ok || (c.Loaded() && c.Name != canonical) {
+		c = &Chain{Name: canonical}
+		r.add(c)
+	}
+	if c.Name != canonical {
+		c.Aliases = append(c.Aliases, c.Name)
+		c.Name = canonical
+		r.indexName(c, canonical)
+	}
+	c.ID, c.NetworkId = id, networkId
+	if native != "" {
+		c.Native = strings.ToUpper(native)
+	}
+}
+
+// Resolve returns the loaded chain called name. Names match ignoring case,
+// punctuation and words like "network", then by edit distance. A chain missing
+// from the asset config resolves to its loaded pair unless name asks for a
+// testnet or mainnet explicitly.
+func (r *ChainRegistry) Resolve(name string) (Chain, error) {
+	key := normalizeChain(name)
+	if key == "" {
+		return Chain{}, errors.New("missing chain name")
+	}
+	stripped, network := stripNetwork(key)
+	c, ok := r.index[key]
+	if !ok && stripped != "" {
+		c, ok = r.index[stripped]
+	}
 	if !ok {
-		return 0, fmt.Errorf("chain %s not found", chainName)
+		var err error
+		if c, err = r.closest(key, stripped); err != nil {
+			return Chain{}, errors.Errorf("chain %s not found: %s", name, err)
+		}
+		if c == nil {
+			return Chain{}, errors.Errorf("chain %s not found", name)
+		}
+	}
+	pair := r.index[normalizeChain(c.Pair)]
+	switch {
+	case network == "testnet" && !c.Testnet && pair != nil && pair.Testnet:
+		c = pair
+	case network == "mainnet" && c.Testnet && pair != nil && !pair.Testnet:
+		c = pair
+	case network == "" && !c.Loaded() && pair != nil && pair.Loaded():
+		c = pair
+	}
+	if !c.Loaded() {
+		return Chain{}, errors.Errorf("chain %s is not supported", name)
+	}
+	return r.view(c), nil
+}
+
+// closest returns the chain whose name or alias is within the typos allowed for
+// key. Names of several chains at the same distance are ambiguous.
+func (r *ChainRegistry) closest(keys ...string) (*Chain, error) {
+	var (
+		best  []*Chain
+		bestD = -1
+	)
+	for _, key := range keys {
+		if key == "" {
+			continue
+		}
+		limit := utils.MaxTypos(len(key))
+		for name, c := range r.index {
+			d := utils.EditDistance(key, name)
+			switch {
+			case d > limit:
+			case bestD < 0 || d < bestD:
+				best, bestD = []*Chain{c}, d
+			case d == bestD && !containsChain(best, c):
+				best = append(best, c)
+			}
+		}
+	}
+	switch len(best) {
+	case 0:
+		return nil, nil
+	case 1:
+		return best[0], nil
+	}
+	names := make([]string, 0, len(best))
+	for _, c := range best {
+		names = append(names, c.Name)
+	}
+	sort.Strings(names)
+	return nil, fmt.Errorf("did you mean %s", strings.Join(names, " or "))
+}
+
+func containsChain(chains []*Chain, c *Chain) bool {
+	for _, chain := range chains {
+		if chain == c {
+			return true
+		}
+	}
+	return false
+}
+
+// Known reports whether name is the name or alias of a chain, loaded or not.
+func (r *ChainRegistry) Known(name string) bool {
+	_, ok := r.index[normalizeChain(name)]
+	return ok
+}
+
+// Family returns the address family of the chain called name, loaded or not,
+// ignoring words like "testnet". It is empty for EVM and unknown chains.
+func (r *ChainRegistry) Family(name string) string {
+	key := normalizeChain(name)
+	c, ok := r.index[key]
+	if !ok {
+		stripped, _ := stripNetwork(key)
+		if c, ok = r.index[stripped]; !ok {
+			return ""
+		}
+	}
+	return c.Family
+}
+
+// Loaded returns the chains of the asset config sorted by name.
+func (r *ChainRegistry) Loaded() []Chain {
+	chains := make([]Chain, 0, len(r.chains))
+	for _, c := range r.chains {
+		if c.Loaded() {
+			chains = append(chains, r.view(c))
+		}
+	}
+	sort.Slice(chains, func(i, j int) bool {
+		return chains[i].Name < chains[j].Name
+	})
+	return chains
+}
+
+// Names returns the canonical names of the loaded chains sorted.
+func (r *ChainRegistry) Names() []string {
+	chains := r.Loaded()
+	names := make([]string, 0, len(chains))
+	for _, c := range chains {
+		names = append(names, c.Name)
+	}
+	return names
+}
+
+// view copies c with its pair given by canonical name.
+func (r *ChainRegistry) view(c *Chain) Chain {
+	chain := *c
+	chain.Aliases = append([]string(nil), c.Aliases...)
+	if pair, ok := r.index[normalizeChain(c.Pair)]; ok {
+		chain.Pair = pair.Name
+	}
+	return chain
+}
+
+// normalizeChain lowercases name and turns punctuation into single spaces.
+func normalizeChain(name string) string {
+	return strings.Join(strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9')
+	}), " ")
+}
+
+var networkWords = map[string]string{
+	"testnet":    "testnet",
+	"test":       "testnet",
+	"mainnet":    "mainnet",
+	"main":       "mainnet",
+	"network":    "",
+	"chain":      "",
+	"blockchain": "",
+}
+
+// stripNetwork drops the words of key naming no chain in particular and returns
+// which network they ask for, if any.
+func stripNetwork(key string) (string, string) {
+	var (
+		words   []string
+		network string
+	)
+	for _, word := range strings.Fields(key) {
+		kind, ok := networkWords[word]
+		if !ok {
+			words = append(words, word)
+			continue
+		}
+		if kind != "" {
+			network = kind
+		}
+	}
+	return strings.Join(words, " "), network
+}
+
+func keyChain(chainName string) string {
+	return strings.ToLower(strings.TrimSpace(chainName))
+}
+
+// Chain resolves name to a loaded chain, see ChainRegistry.Resolve.
+func (s *Snapshot) Chain(name string) (Chain, error) {
+	if s == nil || s.chains == nil {
+		return Chain{}, errors.Errorf("chain %s not found", name)
+	}
+	return s.chains.Resolve(name)
+}
+
+func (s *Snapshot) ChainID(name string) (int, error) {
+	chain, err := s.Chain(name)
+	if err != nil {
+		return 0, err
+	}
+	return chain.ID, nil
+}
+
+// KnownChain reports whether name is the name or alias of a chain, loaded or
+// not.
+func (s *Snapshot) KnownChain(name string) bool {
+	return s != nil && s.chains != nil && s.chains.Known(name)
+}
+
+// ChainFamily returns the address family of chain, see ChainRegistry.Family.
+func (s *Snapshot) ChainFamily(name string) string {
+	if s == nil || s.chains == nil {
+		return ""
+	}
+	return s.chains.Family(name)
+}
+
+// ChainNames returns the canonical names of the loaded chains sorted.
+func (s *Snapshot) ChainNames() []string {
+	if s == nil || s.chains == nil {
+		return nil
 	}
-	return chainId, nil
+	return s.chains.Names()
 }
@@ -0,0 +1,92 @@
+package data
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testChains(t *testing.T) *ChainRegistry {
+	aliases, err := LoadChainAliases("")
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+	r := NewChainRegistry(aliases)
+	r.Register("Mumbai", 80001, 80001, "")
+	r.Register("Fuji", 43113, 43113, "")
+	r.Register("Avalanche Mainnet", 43114, 43114, "avax")
+	return r
+}
+
+func TestChainResolve(t *testing.T) {
+	r := testChains(t)
+	for _, test := range []struct {
+		name  string
+		chain string
+	}{
+		{"mumbai", "mumbai"},
+		{"Polygon Mumbai", "mumbai"},
+		{"polygon-mumbai", "mumbai"},
+		{"Mumbai Testnet", "mumbai"},
+		{"mumbay", "mumbai"},
+		{"Fuji testnet", "fuji"},
+		{"Avalanche Fuji", "fuji"},
+		{"Avalanche", "avalanche mainnet"},
+		{"avax", "avalanche mainnet"},
+		{"Avalanche C-Chain", "avalanche mainnet"},
+		{"avalanche testnet", "fuji"},
+		{"Avalanche Network", "avalanche mainnet"},
+		{"avalanch", "avalanche mainnet"},
+		// polygon isn't loaded, its testnet is
+		{"Polygon", "mumbai"},
+		{"matic", "mumbai"},
+	} {
+		chain, err := r.Resolve(test.name)
+		if assert.Nil(t, err, test.name) {
+			assert.Equal(t, test.chain, chain.Name, test.name)
+		}
+	}
+
+	chain, err := r.Resolve("avax")
+	assert.Nil(t, err)
+	assert.Equal(t, 43114, chain.ID)
+	assert.Equal(t, "AVAX", chain.Native)
+	assert.False(t, chain.Testnet)
+	assert.Equal(t, "fuji", chain.Pair)
+
+	chain, err = r.Resolve("fuji")
+	assert.Nil(t, err)
+	assert.True(t, chain.Testnet)
+	assert.Equal(t, "avalanche mainnet", chain.Pair)
+
+	_, err = r.Resolve("polygon mainnet")
+	assert.ErrorContains(t, err, "not supported")
+	_, err = r.Resolve("goerli")
+	assert.ErrorContains(t, err, "not supported")
+	_, err = r.Resolve("cosmos")
+	assert.ErrorContains(t, err, "not found")
+	_, err = r.Resolve("")
+	assert.NotNil(t, err)
+}
+
+func TestChainRegistry(t *testing.T) {
+	r := testChains(t)
+	assert.Equal(t, []string{"avalanche mainnet", "fuji", "mumbai"}, r.Names())
+	assert.True(t, r.Known("matic"))
+	assert.False(t, r.Known("cosmos"))
+	assert.Equal(t, "solana", r.Family("Solana Testnet"))
+	assert.Equal(t, "", r.Family("matic"))
+
+	r.Register("Zora", 7777777, 7777777, "ETH")
+	chain, err := r.Resolve("zora")
+	assert.Nil(t, err)
+	assert.Equal(t, 7777777, chain.ID)
+	assert.Equal(t, "ETH", chain.Native)
+
+	// reloading keeps the canonical name and the aliases
+	r.Register("Fuji", 43113, 43113, "AVAX")
+	chain, err = r.Resolve("fuji testnet")
+	assert.Nil(t, err)
+	assert.Equal(t, "fuji", chain.Name)
+	assert.Len(t, r.Names(), 4)
+}
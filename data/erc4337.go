@@ -0,0 +1,27 @@
+package data
+
+import (
+	"fmt"
+)
+
+// Erc4337Contracts are the account abstraction contracts deployed on a chain.
+type Erc4337Contracts struct {
+	NetworkId            int
+	EntryPoint           string
+	SimpleAccountFactory string
+	TokenPaymaster       string
+}
+
+func (s *Snapshot) Erc4337Contracts(chainName string) (Erc4337Contracts, error) {
+	var (
+		contracts Erc4337Contracts
+		ok        bool
+	)
+	if s != nil {
+		contracts, ok = s.erc4337[keyChain(chainName)]
+	}
+	if !ok {
+		return Erc4337Contracts{}, fmt.Errorf("chain %s has no erc4337 contracts", chainName)
+	}
+	return contracts, nil
+}
@@ -0,0 +1,130 @@
+package data
+
+import (
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/smarterwallet/demand-abstraction-serv/model"
+)
+
+// Snapshot is the immutable set of chains, tokens and contracts of one load of
+// the asset config. Lookups on a nil snapshot find nothing.
+type Snapshot struct {
+	// Version increases with every snapshot published by a Registry.
+	Version uint64
+	chains  *ChainRegistry
+	tokens  map[string]map[string]Token
+	erc4337 map[string]Erc4337Contracts
+	// tokenBases maps normalized token aliases to their base symbol.
+	tokenBases map[string]string
+}
+
+// SnapshotBuilder collects the chains of one asset config load into a snapshot.
+type SnapshotBuilder struct {
+	snap *Snapshot
+}
+
+// NewSnapshotBuilder starts a snapshot whose chains and tokens are known by
+// their aliases.
+func NewSnapshotBuilder(chains []Chain, tokens TokenAliases) *SnapshotBuilder {
+	return &SnapshotBuilder{snap: &Snapshot{
+		chains:     NewChainRegistry(chains),
+		tokenBases: tokens.bases(),
+		tokens:     make(map[string]map[string]Token),
+		erc4337:    make(map[string]Erc4337Contracts),
+	}}
+}
+
+func (b *SnapshotBuilder) AddChain(name string, id, networkId int, native string) {
+	b.snap.chains.Register(name, id, networkId, native)
+	if _, ok := b.snap.tokens[keyChain(name)]; !ok {
+		b.snap.tokens[keyChain(name)] = make(map[string]Token)
+	}
+}
+
+// AddToken adds token to chainName, adding the chain to the tokens when it
+// isn't yet.
+func (b *SnapshotBuilder) AddToken(chainName string, token Token) {
+	tokens, ok := b.snap.tokens[keyChain(chainName)]
+	if !ok {
+		tokens = make(map[string]Token)
+		b.snap.tokens[keyChain(chainName)] = tokens
+	}
+	tokens[strings.ToUpper(token.Symbol)] = token
+}
+
+func (b *SnapshotBuilder) AddErc4337Contracts(chainName string, contracts Erc4337Contracts) {
+	b.snap.erc4337[keyChain(chainName)] = contracts
+}
+
+// AddAssetConfig adds the chains, tokens and contracts of an asset config load.
+func (b *SnapshotBuilder) AddAssetConfig(res *model.AssetConfigResp) {
+	for _, chain := range res.Result.Chain {
+		if aa := chain.Erc4337ContractAddress; aa != nil {
+			b.AddErc4337Contracts(chain.Name, Erc4337Contracts{
+				NetworkId:            chain.NetWorkId,
+				EntryPoint:           aa.Entrypoint,
+				SimpleAccountFactory: aa.SimpleAccountFactory,
+				TokenPaymaster:       aa.TokenPaymaster.Swt,
+			})
+		}
+		native := ""
+		for _, token := range chain.Tokens {
+			if isNativeToken(token.Address) {
+				native = token.Name
+			}
+			b.AddToken(chain.Name, Token{Symbol: token.Name, Address: token.Address, Decimal: token.Decimal})
+		}
+		b.AddChain(chain.Name, chain.ID, chain.NetWorkId, native)
+	}
+}
+
+// isNativeToken reports whether address stands for the native token of a chain
+// rather than a contract.
+func isNativeToken(address string) bool {
+	switch strings.ToLower(address) {
+	case "", "0x0000000000000000000000000000000000000000", "0xeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeee":
+		return true
+	}
+	return false
+}
+
+// Build returns the snapshot outside of any registry, with version 0. b must
+// not be used afterwards.
+func (b *SnapshotBuilder) Build() *Snapshot {
+	snap := b.snap
+	b.snap = nil
+	return snap
+}
+
+// Registry hands out the current snapshot and swaps in reloaded ones, so
+// readers never see a half loaded asset config.
+type Registry struct {
+	mu      sync.Mutex
+	current atomic.Pointer[Snapshot]
+}
+
+// NewRegistry returns a registry holding an empty snapshot of version 0.
+func NewRegistry() *Registry {
+	r := &Registry{}
+	r.current.Store(&Snapshot{})
+	return r
+}
+
+// Snapshot returns the current snapshot. Use the same snapshot for all lookups
+// of one request.
+func (r *Registry) Snapshot() *Snapshot {
+	return r.current.Load()
+}
+
+// Publish replaces the current snapshot with the one built by b and returns it.
+// b must not be used afterwards.
+func (r *Registry) Publish(b *SnapshotBuilder) *Snapshot {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	snap := b.Build()
+	snap.Version = r.current.Load().Version + 1
+	r.current.Store(snap)
+	return snap
+}
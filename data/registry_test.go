@@ -0,0 +1,102 @@
+package data
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testSnapshot(r *Registry, decimals int) *Snapshot {
+	b := NewSnapshotBuilder([]Chain{{Name: "fuji", Native: "AVAX", Testnet: true, Aliases: []string{"avalanche fuji"}}}, nil)
+	b.AddChain("Fuji", 43113, 43113, "")
+	b.AddToken("Fuji", Token{Symbol: "USDC", Address: "0x5425890298aed601595a70ab815c96711a31bc65", Decimal: decimals})
+	b.AddErc4337Contracts("Fuji", Erc4337Contracts{NetworkId: 43113})
+	return r.Publish(b)
+}
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry()
+	empty := r.Snapshot()
+	assert.Equal(t, uint64(0), empty.Version)
+	_, err := empty.ChainID("fuji")
+	assert.NotNil(t, err)
+	_, err = empty.Token("fuji", "USDC")
+	assert.NotNil(t, err)
+
+	snap := testSnapshot(r, 6)
+	assert.Equal(t, uint64(1), snap.Version)
+	assert.Same(t, snap, r.Snapshot())
+	id, err := snap.ChainID("Avalanche Fuji")
+	assert.Nil(t, err)
+	assert.Equal(t, 43113, id)
+	token, err := snap.Token("FUJI", "usdc")
+	assert.Nil(t, err)
+	assert.Equal(t, 6, token.Decimal)
+	tokens, ok := snap.Tokens("fuji")
+	assert.True(t, ok)
+	assert.Len(t, tokens, 1)
+	contracts, err := snap.Erc4337Contracts("fuji")
+	assert.Nil(t, err)
+	assert.Equal(t, 43113, contracts.NetworkId)
+	assert.Equal(t, []string{"fuji"}, snap.ChainNames())
+
+	// published snapshots never change
+	next := testSnapshot(r, 18)
+	assert.Equal(t, uint64(2), next.Version)
+	token, _ = snap.Token("fuji", "USDC")
+	assert.Equal(t, 6, token.Decimal)
+	token, _ = r.Snapshot().Token("fuji", "USDC")
+	assert.Equal(t, 18, token.Decimal)
+
+	var nilSnap *Snapshot
+	assert.False(t, nilSnap.KnownChain("fuji"))
+	assert.Empty(t, nilSnap.ChainNames())
+}
+
+func TestRegistryConcurrentReload(t *testing.T) {
+	r := NewRegistry()
+	testSnapshot(r, 6)
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				testSnapshot(r, 6+i)
+			}
+		}(i)
+	}
+	errs := make(chan error, 8)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var last uint64
+			for j := 0; j < 200; j++ {
+				snap := r.Snapshot()
+				if snap.Version < last {
+					errs <- fmt.Errorf("version went back from %d to %d", last, snap.Version)
+					return
+				}
+				last = snap.Version
+				if _, err := snap.ChainID("fuji"); err != nil {
+					errs <- err
+					return
+				}
+				if _, err := snap.Token("fuji", "USDC"); err != nil {
+					errs <- err
+					return
+				}
+				snap.KnownChain("avalanche fuji")
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+	assert.Equal(t, uint64(201), r.Snapshot().Version)
+}
@@ -0,0 +1,204 @@
+package data
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/smarterwallet/demand-abstraction-serv/utils"
+)
+
+// Token is a token of the loaded asset config.
+type Token struct {
+	Symbol  string
+	Address string
+	Decimal int
+}
+
+func (s *Snapshot) Token(chainName, symbol string) (Token, error) {
+	token, ok := s.chainTokens(chainName)[strings.ToUpper(symbol)]
+	if !ok {
+		return Token{}, fmt.Errorf("token %s on chain %s not found", symbol, chainName)
+	}
+	return token, nil
+}
+
+func (s *Snapshot) TokenDecimal(chainName, symbol string) (int, error) {
+	token, err := s.Token(chainName, symbol)
+	if err != nil {
+		return 0, err
+	}
+	return token.Decimal, nil
+}
+
+// Tokens returns the tokens of chainName keyed by symbol, or false when the
+// chain isn't loaded. The map must not be modified.
+func (s *Snapshot) Tokens(chainName string) (map[string]Token, bool) {
+	if s == nil {
+		return nil, false
+	}
+	tokens, ok := s.tokens[keyChain(chainName)]
+	return tokens, ok
+}
+
+func (s *Snapshot) chainTokens(chainName string) map[string]Token {
+	tokens, _ := s.Tokens(chainName)
+	return tokens
+}
+
+//go:embed tokens.json
+var defaultTokenAliases []byte
+
+// TokenAliases maps a symbol to the symbols and names of its bridged and wrapped
+// variants and what users call it.
+type TokenAliases map[string][]string
+
+// LoadTokenAliases reads the token aliases from path, or the bundled aliases
+// when path is empty.
+func LoadTokenAliases(path string) (TokenAliases, error) {
+	buf := defaultTokenAliases
+	if path != "" {
+		var err error
+		if buf, err = os.ReadFile(path); err != nil {
+			return nil, errors.Wrap(err, "read token aliases")
+		}
+	}
+	var aliases TokenAliases
+	if err := json.Unmarshal(buf, &aliases); err != nil {
+		return nil, errors.Wrap(err, "parse token aliases")
+	}
+	return aliases, nil
+}
+
+// bases maps every normalized alias to the normalized symbol it is a variant of.
+func (a TokenAliases) bases() map[string]string {
+	bases := make(map[string]string)
+	for symbol, aliases := range a {
+		base := normalizeSymbol(symbol)
+		bases[base] = base
+		for _, alias := range aliases {
+			if key := normalizeSymbol(alias); key != "" {
+				bases[key] = base
+			}
+		}
+	}
+	return bases
+}
+
+// normalizeSymbol uppercases symbol and drops everything but letters and digits,
+// so "usdc.e" and "USDCe" compare equal.
+func normalizeSymbol(symbol string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		}
+		return -1
+	}, symbol)
+}
+
+// tokenBase returns the symbol token is a variant of. Symbols without alias
+// only lose a bridge suffix such as ".e".
+func (s *Snapshot) tokenBase(symbol string) string {
+	if base, ok := s.tokenBases[normalizeSymbol(symbol)]; ok {
+		return base
+	}
+	symbol = strings.TrimSpace(symbol)
+	if i := strings.LastIndexAny(symbol, ".-_"); i > 0 && i == len(symbol)-2 {
+		symbol = symbol[:i]
+	}
+	return normalizeSymbol(symbol)
+}
+
+// usdStables are the token families pegged to the dollar.
+var usdStables = map[string]bool{"USDC": true, "USDT": true, "DAI": true}
+
+// UsdStable reports whether symbol is a variant of a dollar stablecoin.
+func (s *Snapshot) UsdStable(symbol string) bool {
+	return s != nil && usdStables[s.tokenBase(symbol)]
+}
+
+// AmbiguousTokenError is returned when a symbol stands for several tokens of a
+// chain.
+type AmbiguousTokenError struct {
+	Symbol     string
+	Chain      string
+	Candidates []Token
+}
+
+func (e *AmbiguousTokenError) Error() string {
+	return fmt.Sprintf("token %s on chain %s is ambiguous: %s", e.Symbol, e.Chain, e.List())
+}
+
+// List names the candidates with their addresses.
+func (e *AmbiguousTokenError) List() string {
+	names := make([]string, 0, len(e.Candidates))
+	for _, token := range e.Candidates {
+		address := token.Address
+		if address == "" {
+			address = "native"
+		}
+		names = append(names, fmt.Sprintf("%s (%s)", token.Symbol, address))
+	}
+	return strings.Join(names, ", ")
+}
+
+// minFuzzySymbol is the length up to which symbols only match exactly, as most
+// short symbols are a typo away from another token.
+const minFuzzySymbol = 4
+
+// ResolveToken returns the token of chainName symbol stands for. Symbols and
+// addresses match ignoring case and punctuation, then tokens of the same
+// family, such as USDC.e for USDC or WETH for ETH, then unknown symbols longer
+// than minFuzzySymbol within a couple of typos. Several tokens matching give an
+// *AmbiguousTokenError.
+func (s *Snapshot) ResolveToken(chainName, symbol string) (Token, error) {
+	tokens := s.chainTokens(chainName)
+	want := normalizeSymbol(symbol)
+	if want == "" {
+		return Token{}, errors.New("missing token symbol")
+	}
+	var candidates []Token
+	for _, token := range tokens {
+		if normalizeSymbol(token.Symbol) == want || strings.EqualFold(token.Address, strings.TrimSpace(symbol)) {
+			candidates = append(candidates, token)
+		}
+	}
+	if len(candidates) == 0 {
+		base := s.tokenBase(symbol)
+		for _, token := range tokens {
+			if s.tokenBase(token.Symbol) == base {
+				candidates = append(candidates, token)
+			}
+		}
+	}
+	if _, known := s.tokenBases[want]; len(candidates) == 0 && !known && len(want) > minFuzzySymbol {
+		best := utils.MaxTypos(len(want)) + 1
+		for _, token := range tokens {
+			d := utils.EditDistance(want, normalizeSymbol(token.Symbol))
+			switch {
+			case d < best:
+				best, candidates = d, []Token{token}
+			case d == best:
+				candidates = append(candidates, token)
+			}
+		}
+	}
+	switch len(candidates) {
+	case 0:
+		return Token{}, fmt.Errorf("token %s on chain %s not found", symbol, chainName)
+	case 1:
+		return candidates[0], nil
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].Symbol < candidates[j].Symbol
+	})
+	return Token{}, &AmbiguousTokenError{Symbol: symbol, Chain: chainName, Candidates: candidates}
+}
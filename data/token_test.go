@@ -0,0 +1,65 @@
+package data
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolveToken(t *testing.T) {
+	aliases, err := LoadTokenAliases("")
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+	b := NewSnapshotBuilder(nil, aliases)
+	b.AddChain("fuji", 43113, 43113, "AVAX")
+	b.AddToken("fuji", Token{Symbol: "AVAX", Decimal: 18})
+	b.AddToken("fuji", Token{Symbol: "WAVAX", Address: "0xd00ae08403b9bbb9124bb305c09058e32c39a48c", Decimal: 18})
+	b.AddToken("fuji", Token{Symbol: "USDC", Address: "0x5425890298aed601595a70ab815c96711a31bc65", Decimal: 6})
+	b.AddToken("fuji", Token{Symbol: "USDC.e", Address: "0x45ea5d57ba80b5e3b0ed502e9a08d568c96278f9", Decimal: 6})
+	b.AddChain("mumbai", 80001, 80001, "MATIC")
+	b.AddToken("mumbai", Token{Symbol: "USDC.e", Address: "0x9999f7fea5938fd3b1e26a12c3f2fdd5c2e0f9a1", Decimal: 6})
+	b.AddToken("mumbai", Token{Symbol: "axlUSDC", Address: "0x8888f7fea5938fd3b1e26a12c3f2fdd5c2e0f9a1", Decimal: 6})
+	b.AddToken("mumbai", Token{Symbol: "WETH", Address: "0x7777f7fea5938fd3b1e26a12c3f2fdd5c2e0f9a1", Decimal: 18})
+	b.AddToken("mumbai", Token{Symbol: "CHAINLINK", Address: "0x6666f7fea5938fd3b1e26a12c3f2fdd5c2e0f9a1", Decimal: 18})
+	snap := NewRegistry().Publish(b)
+
+	for _, test := range []struct {
+		chain  string
+		symbol string
+		want   string
+	}{
+		{"fuji", "usdc", "USDC"},
+		{"fuji", "usdc.e", "USDC.e"},
+		{"fuji", "USDCe", "USDC.e"},
+		{"fuji", "0x45EA5D57BA80B5E3B0ED502E9A08D568C96278F9", "USDC.e"},
+		{"fuji", "avax", "AVAX"},
+		{"fuji", "wavax", "WAVAX"},
+		{"mumbai", "wrapped ether", "WETH"},
+		{"mumbai", "ETH", "WETH"},
+		{"mumbai", "ether", "WETH"},
+		{"mumbai", "axlusdc", "axlUSDC"},
+		{"mumbai", "chainlnk", "CHAINLINK"},
+	} {
+		token, err := snap.ResolveToken(test.chain, test.symbol)
+		if assert.Nil(t, err, test.symbol) {
+			assert.Equal(t, test.want, token.Symbol, test.symbol)
+		}
+	}
+
+	_, err = snap.ResolveToken("mumbai", "USDC")
+	var ambiguous *AmbiguousTokenError
+	if assert.True(t, errors.As(err, &ambiguous)) {
+		assert.Equal(t, []string{"USDC.e", "axlUSDC"}, []string{ambiguous.Candidates[0].Symbol, ambiguous.Candidates[1].Symbol})
+		assert.Contains(t, err.Error(), "USDC.e (0x9999f7fea5938fd3b1e26a12c3f2fdd5c2e0f9a1)")
+	}
+
+	// known symbols missing from the chain don't fall back to a lookalike
+	_, err = snap.ResolveToken("fuji", "USDT")
+	assert.ErrorContains(t, err, "not found")
+	_, err = snap.ResolveToken("fuji", "DAI")
+	assert.ErrorContains(t, err, "not found")
+	_, err = snap.ResolveToken("goerli", "USDC")
+	assert.ErrorContains(t, err, "not found")
+}
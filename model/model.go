@@ -13,51 +13,187 @@ const (
 	CrossChainTransfer    = "cross-chain-transfer"
 )
 
+// Fee kinds.
+const (
+	FeeBridge = "bridge"
+	FeeGas    = "gas"
+)
+
+const (
+	IntentSourceRule = "rule"
+	IntentSourceLlm  = "llm"
+	// IntentSourcePending marks demands answering a clarification question.
+	IntentSourcePending = "pending"
+)
+
 type (
 	Reserve struct {
-		Symbol  string  `json:"symbol"`
-		Balance float64 `json:"balance"`
-		Address string  `json:"address"`
+		Symbol  string          `json:"symbol"`
+		Balance decimal.Decimal `json:"balance"`
+		Address string          `json:"address"`
 	}
 	CtxRequest struct {
 		Address   string               `json:"address"`
 		BaseChain string               `json:"baseChain"`
 		Balances  map[string][]Reserve `json:"balances"`
+		// SlippageBps is the slippage the wallet accepts on swaps, in basis
+		// points, zero for the default.
+		SlippageBps int `json:"slippageBps,omitempty"`
 	}
 	DemandRequest struct {
 		Model  string `json:"model"`
 		Demand string `json:"demand"`
+		Stream bool   `json:"stream"`
+		// SlippageBps overrides the slippage of the wallet for this demand.
+		SlippageBps int `json:"slippageBps,omitempty"`
 	}
 	DemandResponse struct {
 		Category string     `json:"category"`
 		Summary  string     `json:"summary"`
 		Detail   DetailResp `json:"detail"`
+		Intent   *Intent    `json:"intent,omitempty"`
+	}
+	// RequoteRequest asks to quote the swaps of a plan again, those expired
+	// or all of them with Force. Sender is the wallet the swaps pay out to,
+	// that of the conversation when empty.
+	RequoteRequest struct {
+		Ops         []Op   `json:"ops"`
+		Sender      string `json:"sender,omitempty"`
+		SlippageBps int    `json:"slippageBps,omitempty"`
+		Force       bool   `json:"force,omitempty"`
+	}
+	// RequoteResponse is the plan with fresh quotes and the steps requoted.
+	RequoteResponse struct {
+		Ops      []Op        `json:"ops"`
+		Requoted []int       `json:"requoted"`
+		Fees     *FeeSummary `json:"fees,omitempty"`
+	}
+	// UserOpsRequest asks for the UserOperations executing a rendered plan.
+	// Sender defaults to the wallet address of the conversation.
+	UserOpsRequest struct {
+		Sender               string            `json:"sender"`
+		Ops                  []Op              `json:"ops"`
+		Nonces               map[string]string `json:"nonces"`
+		InitCodes            map[string]string `json:"init_codes"`
+		MaxFeePerGas         string            `json:"max_fee_per_gas"`
+		MaxPriorityFeePerGas string            `json:"max_priority_fee_per_gas"`
+		PaymasterData        string            `json:"paymaster_data,omitempty"`
+	}
+	// Intent records how the strategy of a demand was chosen. Confidence is
+	// the score of the local classifier, zero when the llm chose.
+	Intent struct {
+		Category   string  `json:"category"`
+		Confidence float64 `json:"confidence"`
+		Source     string  `json:"source"`
+	}
+	// Call is a contract call executing an op, with a hex encoded value and
+	// calldata.
+	Call struct {
+		To    string `json:"to"`
+		Value string `json:"value"`
+		Data  string `json:"data"`
 	}
 	DetailResp struct {
-		Reply string        `json:"reply"`
-		OPs   []interface{} `json:"ops"`
+		Reply string      `json:"reply"`
+		OPs   []Op        `json:"ops"`
+		Fees  *FeeSummary `json:"fees,omitempty"`
+	}
+	// Fee is a cost of an op in Token, and in USD when it can be priced.
+	// Amount is missing for fees only known in USD.
+	Fee struct {
+		Kind   string `json:"kind"`
+		Token  string `json:"token"`
+		Amount string `json:"amount,omitempty"`
+		USD    string `json:"usd,omitempty"`
+	}
+	// FeeSummary totals the fees of a plan by kind and token. USD is set when
+	// every fee is priced.
+	FeeSummary struct {
+		Total []Fee  `json:"total"`
+		USD   string `json:"usd,omitempty"`
 	}
 	CrossChainResponse struct {
 		Type            string          `json:"type"`
-		RawResponse     json.RawMessage `json:"raw_response"`
+		Step            int             `json:"step"`
+		DependsOn       []int           `json:"depends_on,omitempty"`
+		RawResponse     json.RawMessage `json:"raw_response,omitempty"`
 		SourceChainId   int             `json:"source_chain_id"`
 		SourceChainName string          `json:"source_chain_name"`
 		Token           string          `json:"token"`
 		Amount          string          `json:"amount"`
+		AmountRaw       string          `json:"amount_raw,omitempty"`
 		Receiver        string          `json:"receiver"`
 		TargetChainId   int             `json:"target_chain_id"`
 		TargetChainName string          `json:"target_chain_name"`
+		// Fees are what the bridge charges; Received is the amount left for
+		// the receiver after them. Warning is set when the fees leave the
+		// receiver short of what was asked.
+		Fees     []Fee  `json:"fees,omitempty"`
+		Received string `json:"received,omitempty"`
+		Warning  string `json:"warning,omitempty"`
+		Calldata []Call `json:"calldata,omitempty"`
 	}
 	SwapResponse struct {
 		Type        string          `json:"type"`
-		RawResponse json.RawMessage `json:"raw_response"`
+		Step        int             `json:"step"`
+		DependsOn   []int           `json:"depends_on,omitempty"`
+		RawResponse json.RawMessage `json:"raw_response,omitempty"`
 		ChainId     int             `json:"chain_id"`
 		ChainName   string          `json:"chain_name"`
 		SourceToken string          `json:"source_token"`
 		TargetToken string          `json:"target_token"`
 		Dex         string          `json:"dex"`
 		SwapIn      string          `json:"swap_in"`
+		SwapInRaw   string          `json:"swap_in_raw,omitempty"`
 		SwapOut     string          `json:"swap_out"`
+		SwapOutRaw  string          `json:"swap_out_raw,omitempty"`
+		// ExactIn marks swaps of a fixed input. EstimatedOut marks a SwapOut
+		// derived from exact output quotes rather than quoted itself.
+		ExactIn      bool `json:"exact_in,omitempty"`
+		EstimatedOut bool `json:"estimated_out,omitempty"`
+		// SlippageBps bounds the swap to MaxAmountIn for an exact output and to
+		// MinAmountOut for an exact input.
+		SlippageBps     int    `json:"slippage_bps,omitempty"`
+		MaxAmountIn     string `json:"max_amount_in,omitempty"`
+		MaxAmountInRaw  string `json:"max_amount_in_raw,omitempty"`
+		MinAmountOut    string `json:"min_amount_out,omitempty"`
+		MinAmountOutRaw string `json:"min_amount_out_raw,omitempty"`
+		// QuotedAt and ExpiresAt are unix seconds; BlockNumber is the block
+		// the quote was priced at.
+		QuotedAt    int64  `json:"quoted_at,omitempty"`
+		BlockNumber uint64 `json:"block_number,omitempty"`
+		ExpiresAt   int64  `json:"expires_at,omitempty"`
+		// GasUSD and CostUSD price the quote, CostUSD being the input plus gas.
+		GasUSD       string            `json:"gas_usd,omitempty"`
+		CostUSD      string            `json:"cost_usd,omitempty"`
+		Alternatives []SwapAlternative `json:"alternatives,omitempty"`
+		// ExecutionPrice is the output bought per input and PriceImpact the
+		// share of the output lost against the mid price of the pools.
+		// Warning is set when the trade looks bad.
+		ExecutionPrice string      `json:"execution_price,omitempty"`
+		PriceImpact    string      `json:"price_impact,omitempty"`
+		Route          []SwapRoute `json:"route,omitempty"`
+		// GasUsed is the gas the router estimates and GasCost its price in
+		// the native currency.
+		GasUsed  uint64 `json:"gas_used,omitempty"`
+		GasCost  string `json:"gas_cost,omitempty"`
+		Warning  string `json:"warning,omitempty"`
+		Fees     []Fee  `json:"fees,omitempty"`
+		Calldata []Call `json:"calldata,omitempty"`
+	}
+	// SwapRoute is a share of a swap routed through Path, token by token.
+	SwapRoute struct {
+		Protocol string   `json:"protocol"`
+		Percent  int      `json:"percent"`
+		Path     []string `json:"path"`
+		Pools    []string `json:"pools,omitempty"`
+	}
+	// SwapAlternative is a runner-up quote of a swap, from the best down.
+	SwapAlternative struct {
+		SourceToken string `json:"source_token"`
+		SwapIn      string `json:"swap_in"`
+		GasUSD      string `json:"gas_usd,omitempty"`
+		CostUSD     string `json:"cost_usd,omitempty"`
 	}
 	TradeStrategyResponse struct {
 		BotName    string      `json:"bot_name"`
@@ -91,19 +227,55 @@ const (
 	CIDHeader        = "X-SmartWallet-CID"
 	DialogueRoleUser = "User"
 	DialogueRoleAI   = "AI"
+	DialogueRoleTool = "Tool"
+	DialogueText     = "text"
+	DialogueToolCall = "tool_call"
 	ModelV1          = "v1"
 )
 
+const (
+	StageStrategySelected   = "strategy_selected"
+	StageArgumentsExtracted = "arguments_extracted"
+	StageCrossChainChecked  = "cross_chain_checked"
+	StageSwapQuoted         = "swap_quoted"
+	StageResult             = "result"
+	StageError              = "error"
+)
+
+type (
+	StreamEvent struct {
+		Event string      `json:"event"`
+		Data  interface{} `json:"data"`
+	}
+	StrategySelectedEvent struct {
+		Strategy   string  `json:"strategy"`
+		Confidence float64 `json:"confidence"`
+		Source     string  `json:"source"`
+	}
+	ArgumentsExtractedEvent struct {
+		Function  string          `json:"function"`
+		Arguments json.RawMessage `json:"arguments"`
+	}
+	CrossChainCheckedEvent struct {
+		SourceChain string `json:"source_chain"`
+		TargetChain string `json:"target_chain"`
+		Token       string `json:"token"`
+		Supported   bool   `json:"supported"`
+	}
+)
+
 type (
 	ConversationCtx struct {
 		Cid       string     `json:"cid"`
 		Dialogues []Dialogue `json:"dialogues"`
 	}
 	Dialogue struct {
-		Type      string `json:"type"`
-		Role      string `json:"role"`
-		Content   string `json:"content"`
-		Timestamp int64  `json:"timestamp"`
+		Type       string `json:"type"`
+		Role       string `json:"role"`
+		Content    string `json:"content"`
+		Name       string `json:"name,omitempty"`
+		ToolCallID string `json:"tool_call_id,omitempty"`
+		Timestamp  int64  `json:"timestamp"`
 	}
 )
 
@@ -124,389 +296,11 @@ type CrossChainResp struct {
 	} `json:"result"`
 }
 
-type SwapResult struct {
-	Quote struct {
-		Numerator   []interface{} `json:"numerator"`
-		Denominator []int         `json:"denominator"`
-		Currency    struct {
-			ChainId  int    `json:"chainId"`
-			Decimals int    `json:"decimals"`
-			IsNative bool   `json:"isNative"`
-			IsToken  bool   `json:"isToken"`
-			Address  string `json:"address"`
-		} `json:"currency"`
-		DecimalScale []int `json:"decimalScale"`
-	} `json:"quote"`
-	QuoteGasAdjusted struct {
-		Numerator   []int `json:"numerator"`
-		Denominator []int `json:"denominator"`
-		Currency    struct {
-			ChainId  int    `json:"chainId"`
-			Decimals int    `json:"decimals"`
-			IsNative bool   `json:"isNative"`
-			IsToken  bool   `json:"isToken"`
-			Address  string `json:"address"`
-		} `json:"currency"`
-		DecimalScale []int `json:"decimalScale"`
-	} `json:"quoteGasAdjusted"`
-	EstimatedGasUsed struct {
-		Type string `json:"type"`
-		Hex  string `json:"hex"`
-	} `json:"estimatedGasUsed"`
-	EstimatedGasUsedQuoteToken struct {
-		Numerator   []int `json:"numerator"`
-		Denominator []int `json:"denominator"`
-		Currency    struct {
-			ChainId  int    `json:"chainId"`
-			Decimals int    `json:"decimals"`
-			Symbol   string `json:"symbol"`
-			Name     string `json:"name"`
-			IsNative bool   `json:"isNative"`
-			IsToken  bool   `json:"isToken"`
-			Address  string `json:"address"`
-		} `json:"currency"`
-		DecimalScale []int `json:"decimalScale"`
-	} `json:"estimatedGasUsedQuoteToken"`
-	EstimatedGasUsedUSD struct {
-		Numerator   []int `json:"numerator"`
-		Denominator []int `json:"denominator"`
-		Currency    struct {
-			ChainId  int    `json:"chainId"`
-			Decimals int    `json:"decimals"`
-			Symbol   string `json:"symbol"`
-			Name     string `json:"name"`
-			IsNative bool   `json:"isNative"`
-			IsToken  bool   `json:"isToken"`
-			Address  string `json:"address"`
-		} `json:"currency"`
-		DecimalScale []int `json:"decimalScale"`
-	} `json:"estimatedGasUsedUSD"`
-	GasPriceWei struct {
-		Type string `json:"type"`
-		Hex  string `json:"hex"`
-	} `json:"gasPriceWei"`
-	Route []struct {
-		Protocol string `json:"protocol"`
-		Amount   struct {
-			Numerator   []int `json:"numerator"`
-			Denominator []int `json:"denominator"`
-			Currency    struct {
-				ChainId  int    `json:"chainId"`
-				Decimals int    `json:"decimals"`
-				IsNative bool   `json:"isNative"`
-				IsToken  bool   `json:"isToken"`
-				Address  string `json:"address"`
-			} `json:"currency"`
-			DecimalScale []int `json:"decimalScale"`
-		} `json:"amount"`
-		RawQuote struct {
-			Type string `json:"type"`
-			Hex  string `json:"hex"`
-		} `json:"rawQuote"`
-		SqrtPriceX96AfterList []struct {
-			Type string `json:"type"`
-			Hex  string `json:"hex"`
-		} `json:"sqrtPriceX96AfterList"`
-		InitializedTicksCrossedList []int `json:"initializedTicksCrossedList"`
-		QuoterGasEstimate           struct {
-			Type string `json:"type"`
-			Hex  string `json:"hex"`
-		} `json:"quoterGasEstimate"`
-		Quote struct {
-			Numerator   []interface{} `json:"numerator"`
-			Denominator []int         `json:"denominator"`
-			Currency    struct {
-				ChainId  int    `json:"chainId"`
-				Decimals int    `json:"decimals"`
-				IsNative bool   `json:"isNative"`
-				IsToken  bool   `json:"isToken"`
-				Address  string `json:"address"`
-			} `json:"currency"`
-			DecimalScale []int `json:"decimalScale"`
-		} `json:"quote"`
-		Percent int `json:"percent"`
-		Route   struct {
-			MidPrice interface{} `json:"_midPrice"`
-			Pools    []struct {
-				Token0 struct {
-					ChainId  int    `json:"chainId"`
-					Decimals int    `json:"decimals"`
-					IsNative bool   `json:"isNative"`
-					IsToken  bool   `json:"isToken"`
-					Address  string `json:"address"`
-				} `json:"token0"`
-				Token1 struct {
-					ChainId  int    `json:"chainId"`
-					Decimals int    `json:"decimals"`
-					Symbol   string `json:"symbol"`
-					Name     string `json:"name"`
-					IsNative bool   `json:"isNative"`
-					IsToken  bool   `json:"isToken"`
-					Address  string `json:"address"`
-				} `json:"token1"`
-				Fee              int   `json:"fee"`
-				SqrtRatioX96     []int `json:"sqrtRatioX96"`
-				Liquidity        []int `json:"liquidity"`
-				TickCurrent      int   `json:"tickCurrent"`
-				TickDataProvider struct {
-				} `json:"tickDataProvider"`
-			} `json:"pools"`
-			TokenPath []struct {
-				ChainId  int    `json:"chainId"`
-				Decimals int    `json:"decimals"`
-				IsNative bool   `json:"isNative"`
-				IsToken  bool   `json:"isToken"`
-				Address  string `json:"address"`
-				Symbol   string `json:"symbol,omitempty"`
-				Name     string `json:"name,omitempty"`
-			} `json:"tokenPath"`
-			Input struct {
-				ChainId  int    `json:"chainId"`
-				Decimals int    `json:"decimals"`
-				IsNative bool   `json:"isNative"`
-				IsToken  bool   `json:"isToken"`
-				Address  string `json:"address"`
-			} `json:"input"`
-			Output struct {
-				ChainId  int    `json:"chainId"`
-				Decimals int    `json:"decimals"`
-				IsNative bool   `json:"isNative"`
-				IsToken  bool   `json:"isToken"`
-				Address  string `json:"address"`
-			} `json:"output"`
-			Protocol string `json:"protocol"`
-		} `json:"route"`
-		GasModel struct {
-		} `json:"gasModel"`
-		QuoteToken struct {
-			ChainId  int    `json:"chainId"`
-			Decimals int    `json:"decimals"`
-			IsNative bool   `json:"isNative"`
-			IsToken  bool   `json:"isToken"`
-			Address  string `json:"address"`
-		} `json:"quoteToken"`
-		TradeType      int `json:"tradeType"`
-		GasCostInToken struct {
-			Numerator   []int `json:"numerator"`
-			Denominator []int `json:"denominator"`
-			Currency    struct {
-				ChainId  int    `json:"chainId"`
-				Decimals int    `json:"decimals"`
-				Symbol   string `json:"symbol"`
-				Name     string `json:"name"`
-				IsNative bool   `json:"isNative"`
-				IsToken  bool   `json:"isToken"`
-				Address  string `json:"address"`
-			} `json:"currency"`
-			DecimalScale []int `json:"decimalScale"`
-		} `json:"gasCostInToken"`
-		GasCostInUSD struct {
-			Numerator   []int `json:"numerator"`
-			Denominator []int `json:"denominator"`
-			Currency    struct {
-				ChainId  int    `json:"chainId"`
-				Decimals int    `json:"decimals"`
-				Symbol   string `json:"symbol"`
-				Name     string `json:"name"`
-				IsNative bool   `json:"isNative"`
-				IsToken  bool   `json:"isToken"`
-				Address  string `json:"address"`
-			} `json:"currency"`
-			DecimalScale []int `json:"decimalScale"`
-		} `json:"gasCostInUSD"`
-		GasEstimate struct {
-			Type string `json:"type"`
-			Hex  string `json:"hex"`
-		} `json:"gasEstimate"`
-		QuoteAdjustedForGas struct {
-			Numerator   []int `json:"numerator"`
-			Denominator []int `json:"denominator"`
-			Currency    struct {
-				ChainId  int    `json:"chainId"`
-				Decimals int    `json:"decimals"`
-				IsNative bool   `json:"isNative"`
-				IsToken  bool   `json:"isToken"`
-				Address  string `json:"address"`
-			} `json:"currency"`
-			DecimalScale []int `json:"decimalScale"`
-		} `json:"quoteAdjustedForGas"`
-		PoolAddresses []string `json:"poolAddresses"`
-		TokenPath     []struct {
-			ChainId  int    `json:"chainId"`
-			Decimals int    `json:"decimals"`
-			IsNative bool   `json:"isNative"`
-			IsToken  bool   `json:"isToken"`
-			Address  string `json:"address"`
-			Symbol   string `json:"symbol,omitempty"`
-			Name     string `json:"name,omitempty"`
-		} `json:"tokenPath"`
-	} `json:"route"`
-	Trade struct {
-		Swaps []struct {
-			Route struct {
-				MidPrice interface{} `json:"_midPrice"`
-				Pools    []struct {
-					Token0 struct {
-						ChainId  int    `json:"chainId"`
-						Decimals int    `json:"decimals"`
-						IsNative bool   `json:"isNative"`
-						IsToken  bool   `json:"isToken"`
-						Address  string `json:"address"`
-					} `json:"token0"`
-					Token1 struct {
-						ChainId  int    `json:"chainId"`
-						Decimals int    `json:"decimals"`
-						Symbol   string `json:"symbol"`
-						Name     string `json:"name"`
-						IsNative bool   `json:"isNative"`
-						IsToken  bool   `json:"isToken"`
-						Address  string `json:"address"`
-					} `json:"token1"`
-					Fee              int   `json:"fee"`
-					SqrtRatioX96     []int `json:"sqrtRatioX96"`
-					Liquidity        []int `json:"liquidity"`
-					TickCurrent      int   `json:"tickCurrent"`
-					TickDataProvider struct {
-					} `json:"tickDataProvider"`
-				} `json:"pools"`
-				TokenPath []struct {
-					ChainId  int    `json:"chainId"`
-					Decimals int    `json:"decimals"`
-					IsNative bool   `json:"isNative"`
-					IsToken  bool   `json:"isToken"`
-					Address  string `json:"address"`
-					Symbol   string `json:"symbol,omitempty"`
-					Name     string `json:"name,omitempty"`
-				} `json:"tokenPath"`
-				Input struct {
-					ChainId  int    `json:"chainId"`
-					Decimals int    `json:"decimals"`
-					IsNative bool   `json:"isNative"`
-					IsToken  bool   `json:"isToken"`
-					Address  string `json:"address"`
-				} `json:"input"`
-				Output struct {
-					ChainId  int    `json:"chainId"`
-					Decimals int    `json:"decimals"`
-					IsNative bool   `json:"isNative"`
-					IsToken  bool   `json:"isToken"`
-					Address  string `json:"address"`
-				} `json:"output"`
-				Protocol string `json:"protocol"`
-				Path     []struct {
-					ChainId  int    `json:"chainId"`
-					Decimals int    `json:"decimals"`
-					IsNative bool   `json:"isNative"`
-					IsToken  bool   `json:"isToken"`
-					Address  string `json:"address"`
-					Symbol   string `json:"symbol,omitempty"`
-					Name     string `json:"name,omitempty"`
-				} `json:"path"`
-			} `json:"route"`
-			InputAmount struct {
-				Numerator   []int `json:"numerator"`
-				Denominator []int `json:"denominator"`
-				Currency    struct {
-					ChainId  int    `json:"chainId"`
-					Decimals int    `json:"decimals"`
-					IsNative bool   `json:"isNative"`
-					IsToken  bool   `json:"isToken"`
-					Address  string `json:"address"`
-				} `json:"currency"`
-				DecimalScale []int `json:"decimalScale"`
-			} `json:"inputAmount"`
-			OutputAmount struct {
-				Numerator   []interface{} `json:"numerator"`
-				Denominator []int         `json:"denominator"`
-				Currency    struct {
-					ChainId  int    `json:"chainId"`
-					Decimals int    `json:"decimals"`
-					IsNative bool   `json:"isNative"`
-					IsToken  bool   `json:"isToken"`
-					Address  string `json:"address"`
-				} `json:"currency"`
-				DecimalScale []int `json:"decimalScale"`
-			} `json:"outputAmount"`
-		} `json:"swaps"`
-		Routes []struct {
-			MidPrice interface{} `json:"_midPrice"`
-			Pools    []struct {
-				Token0 struct {
-					ChainId  int    `json:"chainId"`
-					Decimals int    `json:"decimals"`
-					IsNative bool   `json:"isNative"`
-					IsToken  bool   `json:"isToken"`
-					Address  string `json:"address"`
-				} `json:"token0"`
-				Token1 struct {
-					ChainId  int    `json:"chainId"`
-					Decimals int    `json:"decimals"`
-					Symbol   string `json:"symbol"`
-					Name     string `json:"name"`
-					IsNative bool   `json:"isNative"`
-					IsToken  bool   `json:"isToken"`
-					Address  string `json:"address"`
-				} `json:"token1"`
-				Fee              int   `json:"fee"`
-				SqrtRatioX96     []int `json:"sqrtRatioX96"`
-				Liquidity        []int `json:"liquidity"`
-				TickCurrent      int   `json:"tickCurrent"`
-				TickDataProvider struct {
-				} `json:"tickDataProvider"`
-			} `json:"pools"`
-			TokenPath []struct {
-				ChainId  int    `json:"chainId"`
-				Decimals int    `json:"decimals"`
-				IsNative bool   `json:"isNative"`
-				IsToken  bool   `json:"isToken"`
-				Address  string `json:"address"`
-				Symbol   string `json:"symbol,omitempty"`
-				Name     string `json:"name,omitempty"`
-			} `json:"tokenPath"`
-			Input struct {
-				ChainId  int    `json:"chainId"`
-				Decimals int    `json:"decimals"`
-				IsNative bool   `json:"isNative"`
-				IsToken  bool   `json:"isToken"`
-				Address  string `json:"address"`
-			} `json:"input"`
-			Output struct {
-				ChainId  int    `json:"chainId"`
-				Decimals int    `json:"decimals"`
-				IsNative bool   `json:"isNative"`
-				IsToken  bool   `json:"isToken"`
-				Address  string `json:"address"`
-			} `json:"output"`
-			Protocol string `json:"protocol"`
-			Path     []struct {
-				ChainId  int    `json:"chainId"`
-				Decimals int    `json:"decimals"`
-				IsNative bool   `json:"isNative"`
-				IsToken  bool   `json:"isToken"`
-				Address  string `json:"address"`
-				Symbol   string `json:"symbol,omitempty"`
-				Name     string `json:"name,omitempty"`
-			} `json:"path"`
-		} `json:"routes"`
-		TradeType int `json:"tradeType"`
-	} `json:"trade"`
-	MethodParameters struct {
-		Calldata string `json:"calldata"`
-		Value    string `json:"value"`
-		To       string `json:"to"`
-	} `json:"methodParameters"`
-	BlockNumber struct {
-		Type string `json:"type"`
-		Hex  string `json:"hex"`
-	} `json:"blockNumber"`
-	HitsCachedRoute bool `json:"hitsCachedRoute"`
-}
-
 type SwapReq struct {
-	ChainId         int     `json:"chainId"`
-	TokenInAddress  string  `json:"tokenIn"`
-	TokenOutAddress string  `json:"tokenOut"`
-	AmountOut       float64 `json:"amountOut"`
+	ChainId         int         `json:"chainId"`
+	TokenInAddress  string      `json:"tokenIn"`
+	TokenOutAddress string      `json:"tokenOut"`
+	AmountOut       json.Number `json:"amountOut"`
 }
 
 type SwapResp struct {
@@ -514,9 +308,13 @@ type SwapResp struct {
 	Message string `json:"message"`
 	Result  struct {
 		MinInAmount string `json:"minInAmount"`
+		SwapResult
 	} `json:"result"`
 }
 
+// MaxSlippageBps bounds the slippage a wallet or demand may ask for.
+const MaxSlippageBps = 5000
+
 type AssetConfigResp struct {
 	Code    int    `json:"code"`
 	Message string `json:"message"`
@@ -579,6 +377,38 @@ type AssetConfigResp struct {
 	} `json:"result"`
 }
 
+type (
+	// PendingIntent is a demand waiting for the user to fill a missing argument
+	// or to confirm the values listed in Confirm.
+	PendingIntent struct {
+		Strategy string        `json:"strategy"`
+		Calls    []PendingCall `json:"calls"`
+		Missing  string        `json:"missing"`
+		Question string        `json:"question"`
+		Confirm  []string      `json:"confirm,omitempty"`
+	}
+	PendingCall struct {
+		Function string                 `json:"function"`
+		Args     map[string]interface{} `json:"args"`
+	}
+)
+
+// Contact is a named receiver in the address book of a wallet. Chain is the
+// chain family or chain its address is for, empty for EVM chains.
+type Contact struct {
+	Name    string `json:"name"`
+	Address string `json:"address"`
+	Chain   string `json:"chain,omitempty"`
+}
+
+func (c *Contact) MarshalBinary() ([]byte, error) {
+	return json.Marshal(c)
+}
+
+func (p *PendingIntent) MarshalBinary() ([]byte, error) {
+	return json.Marshal(p)
+}
+
 func (d *Dialogue) MarshalBinary() ([]byte, error) {
 	return json.Marshal(d)
 }
@@ -587,6 +417,16 @@ func (c *CtxRequest) MarshalBinary() ([]byte, error) {
 	return json.Marshal(c)
 }
 
+// MarshalJSON writes Balance as a JSON number, as wallets send it, rather
+// than the quoted string of decimal.Decimal.
+func (r Reserve) MarshalJSON() ([]byte, error) {
+	type reserve Reserve
+	return json.Marshal(struct {
+		reserve
+		Balance json.Number `json:"balance"`
+	}{reserve(r), json.Number(r.Balance.String())})
+}
+
 func (c *CtxRequest) GetTokenAddress(chain, symbol string) string {
 	for _, b := range c.Balances[chain] {
 		if b.Symbol == symbol {
@@ -599,12 +439,27 @@ func (c *CtxRequest) GetTokenAddress(chain, symbol string) string {
 func (c *CtxRequest) GetTokenBalance(chain, symbol string) decimal.Decimal {
 	for _, b := range c.Balances[chain] {
 		if b.Symbol == symbol {
-			return decimal.NewFromFloat(b.Balance)
+			return b.Balance
 		}
 	}
 	return decimal.Zero
 }
 
+// AdjustBalance adds delta to the balance of symbol on chain, so later steps of
+// a plan see the outcome of earlier ones.
+func (c *CtxRequest) AdjustBalance(chain, symbol string, delta decimal.Decimal) {
+	if c.Balances == nil {
+		c.Balances = make(map[string][]Reserve)
+	}
+	for i, b := range c.Balances[chain] {
+		if b.Symbol == symbol {
+			c.Balances[chain][i].Balance = b.Balance.Add(delta)
+			return
+		}
+	}
+	c.Balances[chain] = append(c.Balances[chain], Reserve{Symbol: symbol, Balance: delta})
+}
+
 func (c *CtxRequest) Format() {
 	c.BaseChain = strings.ToLower(c.BaseChain)
 	b := make(map[string][]Reserve)
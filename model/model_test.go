@@ -0,0 +1,21 @@
+package model
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReserveJSON(t *testing.T) {
+	body, err := json.Marshal(Reserve{Symbol: "USDC", Balance: decimal.RequireFromString("10.000001"), Address: "0x1"})
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{"symbol":"USDC","balance":10.000001,"address":"0x1"}`, string(body))
+
+	var r Reserve
+	assert.Nil(t, json.Unmarshal(body, &r))
+	assert.Equal(t, "10.000001", r.Balance.String())
+	assert.Nil(t, json.Unmarshal([]byte(`{"balance":"2.5"}`), &r))
+	assert.Equal(t, "2.5", r.Balance.String())
+}
@@ -0,0 +1,222 @@
+package model
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+//go:generate go run ../cmd/opschema -o op.schema.json
+
+// OpSchemaJSON is the published JSON Schema of an Op, generated by OpSchema.
+//
+//go:embed op.schema.json
+var OpSchemaJSON []byte
+
+// Op kinds, the discriminator clients switch on when decoding DetailResp.OPs.
+const (
+	OpTransfer           = "transfer"
+	OpCrossChainTransfer = "cross-chain-transfer"
+	OpSwap               = "swap"
+	OpTradeBot           = "trade-bot"
+)
+
+// OpBody is implemented by every operation carried in an Op.
+type OpBody interface {
+	OpKind() string
+}
+
+// Op is one operation of a plan. It is encoded as the fields of its body plus
+// a "kind" discriminator.
+type Op struct {
+	Body OpBody
+}
+
+// opKinds maps every kind to a constructor of its body.
+var opKinds = map[string]func() OpBody{
+	OpTransfer:           func() OpBody { return &CrossChainResponse{Type: ChainInternalTransfer} },
+	OpCrossChainTransfer: func() OpBody { return &CrossChainResponse{Type: CrossChainTransfer} },
+	OpSwap:               func() OpBody { return &SwapResponse{Type: OpSwap} },
+	OpTradeBot:           func() OpBody { return &TradeStrategyResponse{} },
+}
+
+func NewOp(body OpBody) Op {
+	return Op{Body: body}
+}
+
+func (o Op) Kind() string {
+	if o.Body == nil {
+		return ""
+	}
+	return o.Body.OpKind()
+}
+
+func (r *CrossChainResponse) OpKind() string {
+	if r.Type == ChainInternalTransfer {
+		return OpTransfer
+	}
+	return OpCrossChainTransfer
+}
+
+func (r *SwapResponse) OpKind() string {
+	return OpSwap
+}
+
+func (r *TradeStrategyResponse) OpKind() string {
+	return OpTradeBot
+}
+
+func (o Op) MarshalJSON() ([]byte, error) {
+	if o.Body == nil {
+		return nil, fmt.Errorf("op without body")
+	}
+	body, err := json.Marshal(o.Body)
+	if err != nil {
+		return nil, err
+	}
+	kind, _ := json.Marshal(o.Kind())
+	buf := bytes.NewBufferString(`{"kind":`)
+	buf.Write(kind)
+	if fields := bytes.TrimSpace(body[1 : len(body)-1]); len(fields) > 0 {
+		buf.WriteByte(',')
+		buf.Write(fields)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// UnmarshalJSON decodes the body type named by "kind" and rejects unknown
+// kinds and fields.
+func (o *Op) UnmarshalJSON(data []byte) error {
+	fields := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	var kind string
+	if err := json.Unmarshal(fields["kind"], &kind); err != nil {
+		return fmt.Errorf("op kind: %w", err)
+	}
+	newBody, ok := opKinds[kind]
+	if !ok {
+		return fmt.Errorf("unknown op kind %q", kind)
+	}
+	delete(fields, "kind")
+	rest, err := json.Marshal(fields)
+	if err != nil {
+		return err
+	}
+	body := newBody()
+	dec := json.NewDecoder(bytes.NewReader(rest))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(body); err != nil {
+		return fmt.Errorf("op %s: %w", kind, err)
+	}
+	if body.OpKind() != kind {
+		return fmt.Errorf("op %s: body is a %s", kind, body.OpKind())
+	}
+	o.Body = body
+	return nil
+}
+
+// OpSchema generates the JSON Schema of an Op from the Go types of its bodies.
+func OpSchema() ([]byte, error) {
+	kinds := make([]string, 0, len(opKinds))
+	for kind := range opKinds {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	variants := make([]interface{}, 0, len(kinds))
+	for _, kind := range kinds {
+		body := opKinds[kind]()
+		schema := typeSchema(reflect.TypeOf(body).Elem())
+		schema["properties"].(map[string]interface{})["kind"] = map[string]interface{}{"const": kind}
+		schema["required"] = append([]string{"kind"}, schema["required"].([]string)...)
+		if r, ok := body.(*CrossChainResponse); ok {
+			schema["properties"].(map[string]interface{})["type"] = map[string]interface{}{"const": r.Type}
+		}
+		schema["title"] = kind
+		variants = append(variants, schema)
+	}
+	return json.MarshalIndent(map[string]interface{}{
+		"$schema":     "http://json-schema.org/draft-07/schema#",
+		"title":       "Op",
+		"description": "One operation of DetailResp.ops, discriminated by kind.",
+		"type":        "object",
+		"required":    []string{"kind"},
+		"properties": map[string]interface{}{
+			"kind": map[string]interface{}{"enum": kinds},
+		},
+		"oneOf": variants,
+	}, "", "  ")
+}
+
+var rawMessageType = reflect.TypeOf(json.RawMessage{})
+
+func typeSchema(t reflect.Type) map[string]interface{} {
+	if t == rawMessageType {
+		return map[string]interface{}{}
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		return typeSchema(t.Elem())
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": []string{"array", "null"}, "items": typeSchema(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": typeSchema(t.Elem())}
+	case reflect.Struct:
+		properties := make(map[string]interface{})
+		required := make([]string, 0)
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			name, omitempty := jsonName(field)
+			if name == "" {
+				continue
+			}
+			properties[name] = typeSchema(field.Type)
+			if !omitempty {
+				required = append(required, name)
+			}
+		}
+		return map[string]interface{}{
+			"type":                 "object",
+			"properties":           properties,
+			"required":             required,
+			"additionalProperties": false,
+		}
+	}
+	return map[string]interface{}{}
+}
+
+func jsonName(field reflect.StructField) (string, bool) {
+	if !field.IsExported() {
+		return "", false
+	}
+	tag := field.Tag.Get("json")
+	if tag == "-" {
+		return "", false
+	}
+	parts := strings.Split(tag, ",")
+	name := parts[0]
+	if name == "" {
+		name = field.Name
+	}
+	for _, opt := range parts[1:] {
+		if opt == "omitempty" {
+			return name, true
+		}
+	}
+	return name, false
+}
@@ -0,0 +1,36 @@
+package model
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOpRoundTrip(t *testing.T) {
+	ops := []Op{
+		NewOp(&CrossChainResponse{Type: ChainInternalTransfer, Step: 1, SourceChainName: "mumbai", Token: "USDC", Amount: "1.5", AmountRaw: "1500000"}),
+		NewOp(&CrossChainResponse{Type: CrossChainTransfer, Step: 2, DependsOn: []int{1}, RawResponse: json.RawMessage(`{"code":200}`)}),
+		NewOp(&SwapResponse{Type: OpSwap, ChainName: "mumbai", SourceToken: "DAI", TargetToken: "USDC", SwapIn: "2", SwapOut: "1.9"}),
+		NewOp(&TradeStrategyResponse{BotName: "grid", Operations: []Operation{{Seq: 1, Type: "swap"}}}),
+	}
+	buf, err := json.Marshal(ops)
+	assert.Nil(t, err)
+
+	var decoded []Op
+	assert.Nil(t, json.Unmarshal(buf, &decoded))
+	assert.Equal(t, ops, decoded)
+	assert.Equal(t, []string{OpTransfer, OpCrossChainTransfer, OpSwap, OpTradeBot},
+		[]string{decoded[0].Kind(), decoded[1].Kind(), decoded[2].Kind(), decoded[3].Kind()})
+
+	var op Op
+	assert.ErrorContains(t, json.Unmarshal([]byte(`{"kind":"stake"}`), &op), "unknown op kind")
+	assert.ErrorContains(t, json.Unmarshal([]byte(`{"kind":"swap","amount":"1"}`), &op), "unknown field")
+	assert.ErrorContains(t, json.Unmarshal([]byte(`{"kind":"transfer","type":"cross-chain-transfer"}`), &op), "body is a cross-chain-transfer")
+}
+
+func TestOpSchema(t *testing.T) {
+	schema, err := OpSchema()
+	assert.Nil(t, err)
+	assert.JSONEq(t, string(OpSchemaJSON), string(schema), "op.schema.json is stale, run go generate ./model")
+}
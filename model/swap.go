@@ -0,0 +1,389 @@
+package model
+
+import (
+	"encoding/json"
+	"math/big"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+)
+
+// Trade types of the swap router.
+const (
+	TradeExactInput  = 0
+	TradeExactOutput = 1
+)
+
+// SwapResult is the trade the swap router quotes, amounts being fractions of
+// JSBI integers.
+type SwapResult struct {
+	Quote                      CurrencyAmount   `json:"quote"`
+	QuoteGasAdjusted           CurrencyAmount   `json:"quoteGasAdjusted"`
+	EstimatedGasUsed           BigNumber        `json:"estimatedGasUsed"`
+	EstimatedGasUsedQuoteToken CurrencyAmount   `json:"estimatedGasUsedQuoteToken"`
+	EstimatedGasUsedUSD        CurrencyAmount   `json:"estimatedGasUsedUSD"`
+	GasPriceWei                BigNumber        `json:"gasPriceWei"`
+	Route                      []RouteWithQuote `json:"route"`
+	Trade                      Trade            `json:"trade"`
+	MethodParameters           MethodParameters `json:"methodParameters"`
+	BlockNumber                BigNumber        `json:"blockNumber"`
+	HitsCachedRoute            bool             `json:"hitsCachedRoute"`
+}
+
+// RouteWithQuote is the share of a trade sent down one route. Amount is the
+// amount the trade fixes and Quote the one quoted for it: the input for an
+// exact input trade, the output for an exact output one.
+type RouteWithQuote struct {
+	Protocol                    string         `json:"protocol"`
+	Amount                      CurrencyAmount `json:"amount"`
+	RawQuote                    BigNumber      `json:"rawQuote"`
+	SqrtPriceX96AfterList       []BigNumber    `json:"sqrtPriceX96AfterList"`
+	InitializedTicksCrossedList []int          `json:"initializedTicksCrossedList"`
+	QuoterGasEstimate           BigNumber      `json:"quoterGasEstimate"`
+	Quote                       CurrencyAmount `json:"quote"`
+	Percent                     int            `json:"percent"`
+	Route                       PoolRoute      `json:"route"`
+	QuoteToken                  Currency       `json:"quoteToken"`
+	TradeType                   int            `json:"tradeType"`
+	GasCostInToken              CurrencyAmount `json:"gasCostInToken"`
+	GasCostInUSD                CurrencyAmount `json:"gasCostInUSD"`
+	GasEstimate                 BigNumber      `json:"gasEstimate"`
+	QuoteAdjustedForGas         CurrencyAmount `json:"quoteAdjustedForGas"`
+	PoolAddresses               []string       `json:"poolAddresses"`
+	TokenPath                   []Currency     `json:"tokenPath"`
+}
+
+// PoolRoute is a path of pools from Input to Output, TokenPath listing the
+// tokens met along it.
+type PoolRoute struct {
+	Pools     []Pool     `json:"pools"`
+	TokenPath []Currency `json:"tokenPath"`
+	Input     Currency   `json:"input"`
+	Output    Currency   `json:"output"`
+	Protocol  string     `json:"protocol"`
+	Path      []Currency `json:"path"`
+}
+
+// Pool is a Uniswap v3 pool as the router saw it when quoting.
+type Pool struct {
+	Token0       Currency `json:"token0"`
+	Token1       Currency `json:"token1"`
+	Fee          int      `json:"fee"`
+	SqrtRatioX96 JSBI     `json:"sqrtRatioX96"`
+	Liquidity    JSBI     `json:"liquidity"`
+	TickCurrent  int      `json:"tickCurrent"`
+}
+
+// Trade is the trade the router built from its routes.
+type Trade struct {
+	Swaps []struct {
+		Route        PoolRoute      `json:"route"`
+		InputAmount  CurrencyAmount `json:"inputAmount"`
+		OutputAmount CurrencyAmount `json:"outputAmount"`
+	} `json:"swaps"`
+	Routes    []PoolRoute `json:"routes"`
+	TradeType int         `json:"tradeType"`
+}
+
+// MethodParameters is the router call executing the trade.
+type MethodParameters struct {
+	Calldata string `json:"calldata"`
+	Value    string `json:"value"`
+	To       string `json:"to"`
+}
+
+// Currency is a token, or the native currency, of the swap router.
+type Currency struct {
+	ChainId  int    `json:"chainId"`
+	Decimals int    `json:"decimals"`
+	Symbol   string `json:"symbol,omitempty"`
+	Name     string `json:"name,omitempty"`
+	IsNative bool   `json:"isNative"`
+	IsToken  bool   `json:"isToken"`
+	Address  string `json:"address"`
+}
+
+// Label names the currency by symbol, by address when it has none.
+func (c Currency) Label() string {
+	if c.Symbol != "" {
+		return c.Symbol
+	}
+	return c.Address
+}
+
+func (c Currency) same(o Currency) bool {
+	return strings.EqualFold(c.Address, o.Address)
+}
+
+// BigNumber is an ethers BigNumber as serialized by the swap router.
+type BigNumber struct {
+	Type string `json:"type"`
+	Hex  string `json:"hex"`
+}
+
+// Int returns the number, zero when it is missing or invalid.
+func (n BigNumber) Int() *big.Int {
+	v, ok := new(big.Int).SetString(strings.TrimPrefix(strings.ToLower(n.Hex), "0x"), 16)
+	if !ok {
+		return new(big.Int)
+	}
+	return v
+}
+
+// Uint64 returns the number, zero when it is missing or invalid.
+func (n BigNumber) Uint64() uint64 {
+	if v := n.Int(); v.IsUint64() {
+		return v.Uint64()
+	}
+	return 0
+}
+
+// JSBI is an integer serialized by JSBI, 30 bit limbs lowest first.
+type JSBI []int
+
+// UnmarshalJSON reads limbs given as numbers or numeric strings.
+func (j *JSBI) UnmarshalJSON(b []byte) error {
+	var limbs []json.Number
+	if err := json.Unmarshal(b, &limbs); err != nil {
+		return err
+	}
+	*j = make(JSBI, len(limbs))
+	for i, limb := range limbs {
+		v, err := limb.Int64()
+		if err != nil || v < 0 || v >= 1<<30 {
+			return errors.Errorf("invalid JSBI limb %s", limb)
+		}
+		(*j)[i] = int(v)
+	}
+	return nil
+}
+
+// Int rebuilds the integer.
+func (j JSBI) Int() *big.Int {
+	n := new(big.Int)
+	for i := len(j) - 1; i >= 0; i-- {
+		n.Lsh(n, 30)
+		n.Or(n, big.NewInt(int64(j[i])))
+	}
+	return n
+}
+
+// CurrencyAmount is an amount of the swap router: a fraction of JSBI integers
+// in the smallest unit of its currency.
+type CurrencyAmount struct {
+	Numerator    JSBI     `json:"numerator"`
+	Denominator  JSBI     `json:"denominator"`
+	DecimalScale JSBI     `json:"decimalScale"`
+	Currency     Currency `json:"currency"`
+}
+
+// Rat returns the amount in whole tokens, false when its denominator is zero.
+// A missing denominator or scale counts as one.
+func (a CurrencyAmount) Rat() (*big.Rat, bool) {
+	den := big.NewInt(1)
+	for _, limbs := range []JSBI{a.Denominator, a.DecimalScale} {
+		if len(limbs) > 0 {
+			den.Mul(den, limbs.Int())
+		}
+	}
+	if den.Sign() == 0 {
+		return new(big.Rat), false
+	}
+	return new(big.Rat).SetFrac(a.Numerator.Int(), den), true
+}
+
+// Decimal returns the amount in whole tokens, zero when it is missing.
+func (a CurrencyAmount) Decimal() decimal.Decimal {
+	r, _ := a.Rat()
+	return RatDecimal(r)
+}
+
+// RatDecimal rounds a fraction to 18 decimal places.
+func RatDecimal(r *big.Rat) decimal.Decimal {
+	return decimal.NewFromBigInt(r.Num(), 0).DivRound(decimal.NewFromBigInt(r.Denom(), 0), 18)
+}
+
+// DecodeSwapResult decodes the trade of a swap router body, bare or wrapped in
+// the result of the swap service.
+func DecodeSwapResult(body []byte) (*SwapResult, error) {
+	var wrapped struct {
+		Result json.RawMessage `json:"result"`
+	}
+	if err := json.Unmarshal(body, &wrapped); err != nil {
+		return nil, errors.Wrap(err, "decode swap result")
+	}
+	if len(wrapped.Result) > 0 && string(wrapped.Result) != "null" {
+		body = wrapped.Result
+	}
+	var res SwapResult
+	if err := json.Unmarshal(body, &res); err != nil {
+		return nil, errors.Wrap(err, "decode swap result")
+	}
+	return &res, nil
+}
+
+// Amounts returns what the route spends and buys in whole tokens.
+func (r RouteWithQuote) Amounts() (in, out *big.Rat, ok bool) {
+	amount, okAmount := r.Amount.Rat()
+	quote, okQuote := r.Quote.Rat()
+	if r.TradeType == TradeExactOutput {
+		amount, quote = quote, amount
+	}
+	return amount, quote, okAmount && okQuote
+}
+
+// Amounts returns what the trade spends and buys in whole tokens, summed over
+// its routes, or over the swaps of the trade when the routes are missing.
+func (r *SwapResult) Amounts() (in, out *big.Rat, ok bool) {
+	in, out = new(big.Rat), new(big.Rat)
+	for _, route := range r.Route {
+		routeIn, routeOut, routeOk := route.Amounts()
+		if !routeOk {
+			return nil, nil, false
+		}
+		in.Add(in, routeIn)
+		out.Add(out, routeOut)
+	}
+	if len(r.Route) == 0 {
+		for _, swap := range r.Trade.Swaps {
+			swapIn, okIn := swap.InputAmount.Rat()
+			swapOut, okOut := swap.OutputAmount.Rat()
+			if !okIn || !okOut {
+				return nil, nil, false
+			}
+			in.Add(in, swapIn)
+			out.Add(out, swapOut)
+		}
+	}
+	return in, out, in.Sign() > 0 && out.Sign() > 0
+}
+
+// ExecutionPrice returns the output the trade buys per input.
+func (r *SwapResult) ExecutionPrice() (*big.Rat, bool) {
+	in, out, ok := r.Amounts()
+	if !ok {
+		return nil, false
+	}
+	return new(big.Rat).Quo(out, in), true
+}
+
+// PriceImpact returns the share of the output lost to the pools: one less the
+// output over what the input buys at the mid price of each route. It is
+// negative when the trade does better than the mid price.
+func (r *SwapResult) PriceImpact() (*big.Rat, bool) {
+	if len(r.Route) == 0 {
+		return nil, false
+	}
+	expected, out := new(big.Rat), new(big.Rat)
+	for _, route := range r.Route {
+		mid, ok := route.Route.MidPrice()
+		routeIn, routeOut, amountsOk := route.Amounts()
+		if !ok || !amountsOk {
+			return nil, false
+		}
+		expected.Add(expected, new(big.Rat).Mul(mid, routeIn))
+		out.Add(out, routeOut)
+	}
+	if expected.Sign() <= 0 {
+		return nil, false
+	}
+	return new(big.Rat).Quo(new(big.Rat).Sub(expected, out), expected), true
+}
+
+// MidPrice returns the output of the trade per input at the prices of the
+// pools of its routes, weighted by the share of each route.
+func (r *SwapResult) MidPrice() (*big.Rat, bool) {
+	price, total := new(big.Rat), 0
+	for _, route := range r.Route {
+		mid, ok := route.Route.MidPrice()
+		if !ok || route.Percent <= 0 {
+			return nil, false
+		}
+		price.Add(price, new(big.Rat).Mul(mid, big.NewRat(int64(route.Percent), 1)))
+		total += route.Percent
+	}
+	if total == 0 || price.Sign() <= 0 {
+		return nil, false
+	}
+	return price.Quo(price, big.NewRat(int64(total), 1)), true
+}
+
+// MidPrice returns the output of the route per input at the prices of its
+// pools, before the trade moves them.
+func (p PoolRoute) MidPrice() (*big.Rat, bool) {
+	if len(p.Pools) == 0 || len(p.TokenPath) != len(p.Pools)+1 {
+		return nil, false
+	}
+	price := big.NewRat(1, 1)
+	for i, pool := range p.Pools {
+		poolPrice, ok := pool.Price()
+		if !ok {
+			return nil, false
+		}
+		switch {
+		case p.TokenPath[i].same(pool.Token0):
+			price.Mul(price, poolPrice)
+		case p.TokenPath[i].same(pool.Token1):
+			price.Quo(price, poolPrice)
+		default:
+			return nil, false
+		}
+	}
+	return price, true
+}
+
+// Price returns token1 per token0 in whole tokens: the square of sqrtRatioX96
+// over 2^192, scaled by the decimals of the tokens.
+func (p Pool) Price() (*big.Rat, bool) {
+	sqrt := p.SqrtRatioX96.Int()
+	if sqrt.Sign() == 0 {
+		return nil, false
+	}
+	num := new(big.Int).Mul(sqrt, sqrt)
+	den := new(big.Int).Lsh(big.NewInt(1), 192)
+	scale := p.Token0.Decimals - p.Token1.Decimals
+	pow := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(abs(scale))), nil)
+	if scale > 0 {
+		num.Mul(num, pow)
+	} else {
+		den.Mul(den, pow)
+	}
+	return new(big.Rat).SetFrac(num, den), true
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// Routes lists the routes of the trade by the symbols they go through.
+func (r *SwapResult) Routes() []SwapRoute {
+	var routes []SwapRoute
+	for _, route := range r.Route {
+		tokens := route.TokenPath
+		if len(tokens) == 0 {
+			tokens = route.Route.TokenPath
+		}
+		path := make([]string, 0, len(tokens))
+		for _, token := range tokens {
+			path = append(path, token.Label())
+		}
+		routes = append(routes, SwapRoute{Protocol: route.Protocol, Percent: route.Percent, Path: path, Pools: route.PoolAddresses})
+	}
+	return routes
+}
+
+// GasCost returns the gas the trade is estimated to use and what it costs in
+// the native currency at the quoted gas price.
+func (r *SwapResult) GasCost() (uint64, decimal.Decimal) {
+	used := r.EstimatedGasUsed.Int()
+	wei := new(big.Int).Mul(used, r.GasPriceWei.Int())
+	var gas uint64
+	if used.IsUint64() {
+		gas = used.Uint64()
+	}
+	return gas, decimal.NewFromBigInt(wei, -18)
+}
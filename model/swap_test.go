@@ -0,0 +1,82 @@
+package model
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCurrencyAmount(t *testing.T) {
+	assert.Equal(t, "1000000000000000000", JSBI{660865024, 931322574}.Int().String())
+	assert.Equal(t, "0", JSBI(nil).Int().String())
+
+	var res SwapResp
+	assert.Nil(t, json.Unmarshal([]byte(`{"code":200,"result":{"minInAmount":"1.5",
+		"estimatedGasUsedUSD":{"numerator":[500000],"denominator":[1],"decimalScale":[1000000]},
+		"estimatedGasUsedQuoteToken":{"numerator":["660865024",931322574],"denominator":[4],"decimalScale":[660865024,931322574]}}}`), &res))
+	assert.Equal(t, "0.5", res.Result.EstimatedGasUsedUSD.Decimal().String())
+	assert.Equal(t, "0.25", res.Result.EstimatedGasUsedQuoteToken.Decimal().String())
+	assert.True(t, CurrencyAmount{}.Decimal().IsZero())
+	assert.ErrorContains(t, json.Unmarshal([]byte(`{"numerator":[1073741824]}`), &CurrencyAmount{}), "invalid JSBI limb")
+}
+
+// swapResult buys 1 WETH with 2600 USDC through a pool pricing USDC at 0.0004
+// WETH.
+const swapResult = `{"code":200,"result":{"minInAmount":"2600",
+	"estimatedGasUsed":{"type":"BigNumber","hex":"0x030d40"},
+	"gasPriceWei":{"type":"BigNumber","hex":"0x3b9aca00"},
+	"blockNumber":{"type":"BigNumber","hex":"0x2a"},
+	"route":[{"protocol":"V3","percent":100,"tradeType":1,
+		"amount":{"numerator":[660865024,931322574],"denominator":[1],"decimalScale":[660865024,931322574],
+			"currency":{"chainId":80001,"decimals":18,"symbol":"WETH","address":"0xB"}},
+		"quote":{"numerator":[452516352,2],"denominator":[1],"decimalScale":[1000000],
+			"currency":{"chainId":80001,"decimals":6,"symbol":"USDC","address":"0xA"}},
+		"route":{"pools":[{"token0":{"decimals":6,"address":"0xa"},"token1":{"decimals":18,"address":"0xb"},
+			"fee":500,"sqrtRatioX96":[0,0,0,1280000],"liquidity":[1],"tickCurrent":0,"tickDataProvider":{}}],
+			"tokenPath":[{"decimals":6,"symbol":"USDC","address":"0xA"},{"decimals":18,"symbol":"WETH","address":"0xB"}]},
+		"poolAddresses":["0xpool"],
+		"tokenPath":[{"decimals":6,"symbol":"USDC","address":"0xA"},{"decimals":18,"symbol":"WETH","address":"0xB"}]}]}}`
+
+func TestSwapResult(t *testing.T) {
+	res, err := DecodeSwapResult([]byte(swapResult))
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, uint64(42), res.BlockNumber.Uint64())
+
+	in, out, ok := res.Amounts()
+	assert.True(t, ok)
+	assert.Equal(t, []string{"2600", "1"}, []string{in.FloatString(0), out.FloatString(0)})
+	price, ok := res.ExecutionPrice()
+	assert.True(t, ok)
+	assert.Equal(t, "0.000384615384615385", RatDecimal(price).String())
+	mid, ok := res.Route[0].Route.MidPrice()
+	assert.True(t, ok)
+	assert.Equal(t, "0.0004", RatDecimal(mid).String())
+	mid, ok = res.MidPrice()
+	assert.True(t, ok)
+	assert.Equal(t, "0.0004", RatDecimal(mid).String())
+	// 2600 USDC buy 1.04 WETH at the mid price
+	impact, ok := res.PriceImpact()
+	assert.True(t, ok)
+	assert.Equal(t, "0.038462", RatDecimal(impact).Round(6).String())
+
+	assert.Equal(t, []SwapRoute{{Protocol: "V3", Percent: 100, Path: []string{"USDC", "WETH"}, Pools: []string{"0xpool"}}}, res.Routes())
+	gas, cost := res.GasCost()
+	assert.Equal(t, uint64(200000), gas)
+	assert.Equal(t, "0.0002", cost.String())
+
+	// the swaps of the trade stand in for missing routes, without a mid price
+	bare, err := DecodeSwapResult([]byte(`{"trade":{"swaps":[{
+		"inputAmount":{"numerator":[3],"decimalScale":[1]},"outputAmount":{"numerator":[6],"decimalScale":[1]}}]}}`))
+	assert.Nil(t, err)
+	price, ok = bare.ExecutionPrice()
+	assert.True(t, ok)
+	assert.Equal(t, "2", RatDecimal(price).String())
+	_, ok = bare.PriceImpact()
+	assert.False(t, ok)
+
+	_, err = DecodeSwapResult([]byte(`{"result":{"route":[{"quote":{"numerator":[-1]}}]}}`))
+	assert.ErrorContains(t, err, "decode swap result")
+}
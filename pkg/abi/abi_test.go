@@ -0,0 +1,248 @@
+package abi
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	receiver = "0xd8da6bf26964af9d7eed9e03e53415d37aa96045"
+	usdc     = "0x9999f7fea5938fd3b1e26a12c3f2fdd5c2e0f9a1"
+	dai      = "0x8888f7fea5938fd3b1e26a12c3f2fdd5c2e0f9a1"
+)
+
+func TestSelector(t *testing.T) {
+	for signature, selector := range map[string]string{
+		"transfer(address,uint256)":             "0xa9059cbb",
+		"approve(address,uint256)":              "0x095ea7b3",
+		"transferFrom(address,address,uint256)": "0x23b872dd",
+		"balanceOf(address)":                    "0x70a08231",
+		"execute(address,uint256,bytes)":        "0xb61d27f6",
+		"executeBatch(address[],bytes[])":       "0x18dfb3c7",
+		"multicall(bytes[])":                    "0xac9650d8",
+		"f(uint256,uint32[],bytes10,bytes)":     "0x8be65246",
+		"baz(uint32,bool)":                      "0xcdcd77c0",
+		"exactInputSingle((address,address,uint24,address,uint256,uint256,uint160))": "0x04e45aaf",
+	} {
+		assert.Equal(t, selector, EncodeHex(Selector(signature)), signature)
+	}
+}
+
+func TestPack(t *testing.T) {
+	// examples of the Solidity ABI specification
+	data, err := MustMethod("baz(uint32,bool)").Pack(69, true)
+	assert.Nil(t, err)
+	assert.Equal(t, "0xcdcd77c0"+
+		"0000000000000000000000000000000000000000000000000000000000000045"+
+		"0000000000000000000000000000000000000000000000000000000000000001", EncodeHex(data))
+
+	data, err = MustMethod("f(uint256,uint32[],bytes10,bytes)").Pack(
+		big.NewInt(0x123), []uint32{0x456, 0x789}, []byte("1234567890"), []byte("Hello, world!"))
+	assert.Nil(t, err)
+	assert.Equal(t, "0x8be65246"+strings.Join([]string{
+		"0000000000000000000000000000000000000000000000000000000000000123",
+		"0000000000000000000000000000000000000000000000000000000000000080",
+		"3132333435363738393000000000000000000000000000000000000000000000",
+		"00000000000000000000000000000000000000000000000000000000000000e0",
+		"0000000000000000000000000000000000000000000000000000000000000002",
+		"0000000000000000000000000000000000000000000000000000000000000456",
+		"0000000000000000000000000000000000000000000000000000000000000789",
+		"000000000000000000000000000000000000000000000000000000000000000d",
+		"48656c6c6f2c20776f726c642100000000000000000000000000000000000000",
+	}, ""), EncodeHex(data))
+
+	data, err = MustMethod("g(uint256[][],string[])").Pack(
+		[][]int{{1, 2}, {3}}, []string{"one", "two", "three"})
+	assert.Nil(t, err)
+	assert.Equal(t, "0x2289b18c"+strings.Join([]string{
+		"0000000000000000000000000000000000000000000000000000000000000040",
+		"0000000000000000000000000000000000000000000000000000000000000140",
+		"0000000000000000000000000000000000000000000000000000000000000002",
+		"0000000000000000000000000000000000000000000000000000000000000040",
+		"00000000000000000000000000000000000000000000000000000000000000a0",
+		"0000000000000000000000000000000000000000000000000000000000000002",
+		"0000000000000000000000000000000000000000000000000000000000000001",
+		"0000000000000000000000000000000000000000000000000000000000000002",
+		"0000000000000000000000000000000000000000000000000000000000000001",
+		"0000000000000000000000000000000000000000000000000000000000000003",
+		"0000000000000000000000000000000000000000000000000000000000000003",
+		"0000000000000000000000000000000000000000000000000000000000000060",
+		"00000000000000000000000000000000000000000000000000000000000000a0",
+		"00000000000000000000000000000000000000000000000000000000000000e0",
+		"0000000000000000000000000000000000000000000000000000000000000003",
+		"6f6e650000000000000000000000000000000000000000000000000000000000",
+		"0000000000000000000000000000000000000000000000000000000000000003",
+		"74776f0000000000000000000000000000000000000000000000000000000000",
+		"0000000000000000000000000000000000000000000000000000000000000005",
+		"7468726565000000000000000000000000000000000000000000000000000000",
+	}, ""), EncodeHex(data))
+
+	data, err = Encode(MustTypes("int256,int8"), big.NewInt(-1), -128)
+	assert.Nil(t, err)
+	assert.Equal(t, "0x"+strings.Repeat("f", 64)+strings.Repeat("f", 62)+"80", EncodeHex(data))
+
+	for _, c := range []struct {
+		types  string
+		values []interface{}
+	}{
+		{"uint8", []interface{}{256}},
+		{"uint256", []interface{}{-1}},
+		{"int8", []interface{}{128}},
+		{"address", []interface{}{"0x1234"}},
+		{"bytes2", []interface{}{[]byte{1, 2, 3}}},
+		{"uint256[2]", []interface{}{[]int{1}}},
+		{"bool", []interface{}{1}},
+		{"uint256,uint256", []interface{}{1}},
+	} {
+		_, err := Encode(MustTypes(c.types), c.values...)
+		assert.NotNil(t, err, c.types)
+	}
+}
+
+func TestUnpack(t *testing.T) {
+	m := MustMethod("f(uint256,uint32[],bytes10,bytes)")
+	data, _ := m.Pack(big.NewInt(0x123), []uint32{0x456, 0x789}, []byte("1234567890"), []byte("Hello, world!"))
+	args, err := m.Unpack(data)
+	assert.Nil(t, err)
+	assert.Equal(t, []interface{}{
+		big.NewInt(0x123),
+		[]interface{}{big.NewInt(0x456), big.NewInt(0x789)},
+		[]byte("1234567890"),
+		[]byte("Hello, world!"),
+	}, args)
+
+	m = MustMethod("g(uint256[][],string[],(address,bool,int16))")
+	data, _ = m.Pack([][]int{{1, 2}, {3}}, []string{"one", "two", "three"}, []interface{}{receiver, true, -2})
+	args, err = m.Unpack(data)
+	assert.Nil(t, err)
+	assert.Equal(t, []interface{}{
+		[]interface{}{
+			[]interface{}{big.NewInt(1), big.NewInt(2)},
+			[]interface{}{big.NewInt(3)},
+		},
+		[]interface{}{"one", "two", "three"},
+		[]interface{}{receiver, true, big.NewInt(-2)},
+	}, args)
+
+	_, err = m.Unpack(data[:len(data)-32])
+	assert.NotNil(t, err)
+	_, err = ERC20Transfer.Unpack(data)
+	assert.NotNil(t, err)
+}
+
+func TestERC20(t *testing.T) {
+	data, err := Transfer(receiver, big.NewInt(1500000))
+	assert.Nil(t, err)
+	assert.Equal(t, "0xa9059cbb"+
+		"000000000000000000000000d8da6bf26964af9d7eed9e03e53415d37aa96045"+
+		"000000000000000000000000000000000000000000000000000000000016e360", EncodeHex(data))
+
+	data, err = Approve(receiver, new(big.Int).Sub(tt256, bigOne))
+	assert.Nil(t, err)
+	assert.Equal(t, "0x095ea7b3"+
+		"000000000000000000000000d8da6bf26964af9d7eed9e03e53415d37aa96045"+
+		strings.Repeat("f", 64), EncodeHex(data))
+
+	data, err = TransferFrom(usdc, receiver, big.NewInt(1))
+	assert.Nil(t, err)
+	assert.Equal(t, "0x23b872dd"+
+		"0000000000000000000000009999f7fea5938fd3b1e26a12c3f2fdd5c2e0f9a1"+
+		"000000000000000000000000d8da6bf26964af9d7eed9e03e53415d37aa96045"+
+		"0000000000000000000000000000000000000000000000000000000000000001", EncodeHex(data))
+
+	_, err = Transfer("0x1234", big.NewInt(1))
+	assert.NotNil(t, err)
+}
+
+func TestExecuteBatch(t *testing.T) {
+	data, err := ExecuteBatch([]string{usdc, dai}, [][]byte{{0xaa}, {0xbb, 0xcc}})
+	assert.Nil(t, err)
+	words := []string{
+		"0000000000000000000000000000000000000000000000000000000000000040", // dest offset
+		"00000000000000000000000000000000000000000000000000000000000000a0", // func offset
+		"0000000000000000000000000000000000000000000000000000000000000002", // dest length
+		"0000000000000000000000009999f7fea5938fd3b1e26a12c3f2fdd5c2e0f9a1",
+		"0000000000000000000000008888f7fea5938fd3b1e26a12c3f2fdd5c2e0f9a1",
+		"0000000000000000000000000000000000000000000000000000000000000002", // func length
+		"0000000000000000000000000000000000000000000000000000000000000040", // func[0] offset
+		"0000000000000000000000000000000000000000000000000000000000000080", // func[1] offset
+		"0000000000000000000000000000000000000000000000000000000000000001",
+		"aa00000000000000000000000000000000000000000000000000000000000000",
+		"0000000000000000000000000000000000000000000000000000000000000002",
+		"bbcc000000000000000000000000000000000000000000000000000000000000",
+	}
+	assert.Equal(t, "0x18dfb3c7"+strings.Join(words, ""), EncodeHex(data))
+
+	data, err = Execute(receiver, big.NewInt(1), nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "0xb61d27f6"+
+		"000000000000000000000000d8da6bf26964af9d7eed9e03e53415d37aa96045"+
+		"0000000000000000000000000000000000000000000000000000000000000001"+
+		"0000000000000000000000000000000000000000000000000000000000000060"+
+		"0000000000000000000000000000000000000000000000000000000000000000", EncodeHex(data))
+}
+
+func TestExactInputSingle(t *testing.T) {
+	data, err := ExactInputSingle(SingleSwap{
+		TokenIn:   dai,
+		TokenOut:  usdc,
+		Fee:       3000,
+		Recipient: receiver,
+		Amount:    big.NewInt(1e18),
+		Limit:     big.NewInt(990000),
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, "0x04e45aaf"+strings.Join([]string{
+		"0000000000000000000000008888f7fea5938fd3b1e26a12c3f2fdd5c2e0f9a1",
+		"0000000000000000000000009999f7fea5938fd3b1e26a12c3f2fdd5c2e0f9a1",
+		"0000000000000000000000000000000000000000000000000000000000000bb8",
+		"000000000000000000000000d8da6bf26964af9d7eed9e03e53415d37aa96045",
+		"0000000000000000000000000000000000000000000000000de0b6b3a7640000",
+		"00000000000000000000000000000000000000000000000000000000000f1b30",
+		"0000000000000000000000000000000000000000000000000000000000000000",
+	}, ""), EncodeHex(data))
+
+	data, err = Multicall([][]byte{data})
+	assert.Nil(t, err)
+	assert.Equal(t, "0xac9650d8", EncodeHex(data[:4]))
+	args, err := RouterMulticall.Unpack(data)
+	assert.Nil(t, err)
+	assert.Len(t, args[0], 1)
+}
+
+func TestExactInput(t *testing.T) {
+	path, err := EncodePath([]string{dai, usdc}, []uint32{500})
+	assert.Nil(t, err)
+	assert.Equal(t, "0x8888f7fea5938fd3b1e26a12c3f2fdd5c2e0f9a10001f49999f7fea5938fd3b1e26a12c3f2fdd5c2e0f9a1", EncodeHex(path))
+	_, err = EncodePath([]string{dai, usdc}, nil)
+	assert.NotNil(t, err)
+
+	data, err := ExactInput(PathSwap{Path: path, Recipient: receiver, Amount: big.NewInt(1e18), Limit: big.NewInt(990000)})
+	assert.Nil(t, err)
+	assert.Equal(t, "0xb858183f", EncodeHex(data[:4]))
+	args, err := RouterExactInput.Unpack(data)
+	assert.Nil(t, err)
+	assert.Equal(t, []interface{}{path, receiver, big.NewInt(1e18), big.NewInt(990000)}, args[0])
+
+	data, err = UnwrapWETH9(big.NewInt(1), receiver)
+	assert.Nil(t, err)
+	assert.Equal(t, "0x49404b7c", EncodeHex(data[:4]))
+	data, err = RefundETH()
+	assert.Nil(t, err)
+	assert.Equal(t, "0x12210e8a", EncodeHex(data))
+}
+
+func TestCCIPSend(t *testing.T) {
+	data, err := CCIPSend(14767482510784806043, receiver, usdc, big.NewInt(1000000), "0x0000000000000000000000000000000000000000")
+	assert.Nil(t, err)
+	args, err := CCIPRouterSend.Unpack(data)
+	assert.Nil(t, err)
+	assert.Equal(t, new(big.Int).SetUint64(14767482510784806043), args[0])
+	message := args[1].([]interface{})
+	to, _ := Decode(MustTypes("address"), message[0].([]byte))
+	assert.Equal(t, receiver, to[0])
+	assert.Equal(t, []interface{}{[]interface{}{usdc, big.NewInt(1000000)}}, message[2])
+}
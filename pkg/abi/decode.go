@@ -0,0 +1,135 @@
+package abi
+
+import (
+	"fmt"
+	"math/big"
+)
+
+// Decode decodes data encoded as the tuple of types. Integers decode to
+// *big.Int, addresses to lowercase 0x prefixed strings, bool to bool, bytes and
+// bytesN to []byte, string to string, and arrays and tuples to []interface{}.
+func Decode(types []Type, data []byte) ([]interface{}, error) {
+	values, err := decodeTuple(types, data)
+	if err != nil {
+		return nil, fmt.Errorf("abi: %w", err)
+	}
+	return values, nil
+}
+
+func decodeTuple(types []Type, data []byte) ([]interface{}, error) {
+	values := make([]interface{}, len(types))
+	offset := 0
+	for i, t := range types {
+		at := offset
+		if t.dynamic() {
+			word, err := readWord(data, offset)
+			if err != nil {
+				return nil, fmt.Errorf("%s value %d: %w", t, i, err)
+			}
+			at, err = toOffset(word, len(data))
+			if err != nil {
+				return nil, fmt.Errorf("%s value %d: %w", t, i, err)
+			}
+		}
+		v, err := decodeValue(t, data, at)
+		if err != nil {
+			return nil, fmt.Errorf("%s value %d: %w", t, i, err)
+		}
+		values[i] = v
+		offset += t.headSize()
+	}
+	return values, nil
+}
+
+// decodeValue decodes the value of t starting at data[at:].
+func decodeValue(t Type, data []byte, at int) (interface{}, error) {
+	switch t.Kind {
+	case UintKind, IntKind, AddressKind, BoolKind, FixedBytesKind:
+		word, err := readWord(data, at)
+		if err != nil {
+			return nil, err
+		}
+		return decodeWord(t, word)
+	case BytesKind, StringKind:
+		word, err := readWord(data, at)
+		if err != nil {
+			return nil, err
+		}
+		length, err := toOffset(word, len(data))
+		if err != nil {
+			return nil, err
+		}
+		start := at + wordSize
+		if start+length > len(data) {
+			return nil, fmt.Errorf("%d bytes past the end of data", length)
+		}
+		b := append([]byte{}, data[start:start+length]...)
+		if t.Kind == StringKind {
+			return string(b), nil
+		}
+		return b, nil
+	case SliceKind, ArrayKind:
+		n := t.Size
+		start := at
+		if t.Kind == SliceKind {
+			word, err := readWord(data, at)
+			if err != nil {
+				return nil, err
+			}
+			if n, err = toOffset(word, len(data)); err != nil {
+				return nil, err
+			}
+			start += wordSize
+		}
+		types := make([]Type, n)
+		for i := range types {
+			types[i] = *t.Elem
+		}
+		return decodeTuple(types, data[start:])
+	case TupleKind:
+		return decodeTuple(t.Components, data[at:])
+	}
+	return nil, fmt.Errorf("unsupported type %s", t)
+}
+
+func decodeWord(t Type, word []byte) (interface{}, error) {
+	switch t.Kind {
+	case UintKind:
+		n := new(big.Int).SetBytes(word)
+		if n.BitLen() > t.Size {
+			return nil, fmt.Errorf("%s out of range for %s", n, t)
+		}
+		return n, nil
+	case IntKind:
+		n := new(big.Int).SetBytes(word)
+		if word[0]&0x80 != 0 {
+			n.Sub(n, tt256)
+		}
+		return n, nil
+	case AddressKind:
+		return EncodeHex(word[wordSize-20:]), nil
+	case BoolKind:
+		n := new(big.Int).SetBytes(word)
+		if n.BitLen() > 1 {
+			return nil, fmt.Errorf("invalid bool %s", n)
+		}
+		return n.Sign() == 1, nil
+	}
+	return append([]byte{}, word[:t.Size]...), nil
+}
+
+func readWord(data []byte, at int) ([]byte, error) {
+	if at < 0 || at+wordSize > len(data) {
+		return nil, fmt.Errorf("word at %d past the end of data", at)
+	}
+	return data[at : at+wordSize], nil
+}
+
+// toOffset reads a word as an offset or length, bounded by the data length.
+func toOffset(word []byte, limit int) (int, error) {
+	n := new(big.Int).SetBytes(word)
+	if !n.IsInt64() || n.Int64() > int64(limit) {
+		return 0, fmt.Errorf("offset %s past the end of data", n)
+	}
+	return int(n.Int64()), nil
+}
@@ -0,0 +1,244 @@
+package abi
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"reflect"
+	"strings"
+)
+
+const wordSize = 32
+
+var (
+	bigOne = big.NewInt(1)
+	// tt256 is 2^256, used for two's complement of negative integers.
+	tt256 = new(big.Int).Lsh(bigOne, 256)
+)
+
+// Encode encodes values as the tuple of types.
+//
+// Values are *big.Int (or any Go integer) for integers, a 0x prefixed hex
+// string or [20]byte for addresses, bool, []byte for bytes and bytesN, string,
+// and a slice or array of element values for arrays and tuples.
+func Encode(types []Type, values ...interface{}) ([]byte, error) {
+	if len(types) != len(values) {
+		return nil, fmt.Errorf("abi: %d values for %d types", len(values), len(types))
+	}
+	enc, err := encodeTuple(types, values)
+	if err != nil {
+		return nil, fmt.Errorf("abi: %w", err)
+	}
+	return enc, nil
+}
+
+func encodeTuple(types []Type, values []interface{}) ([]byte, error) {
+	headSize := 0
+	for _, t := range types {
+		headSize += t.headSize()
+	}
+	var head, tail []byte
+	for i, t := range types {
+		enc, err := encodeValue(t, values[i])
+		if err != nil {
+			return nil, fmt.Errorf("%s value %d: %w", t, i, err)
+		}
+		if t.dynamic() {
+			head = append(head, uintWord(big.NewInt(int64(headSize+len(tail))))...)
+			tail = append(tail, enc...)
+			continue
+		}
+		head = append(head, enc...)
+	}
+	return append(head, tail...), nil
+}
+
+func encodeValue(t Type, v interface{}) ([]byte, error) {
+	switch t.Kind {
+	case UintKind, IntKind:
+		n, err := toBig(v)
+		if err != nil {
+			return nil, err
+		}
+		return encodeInt(t, n)
+	case AddressKind:
+		b, err := toAddress(v)
+		if err != nil {
+			return nil, err
+		}
+		return leftPad(b), nil
+	case BoolKind:
+		b, ok := v.(bool)
+		if !ok {
+			return nil, fmt.Errorf("want bool, got %T", v)
+		}
+		if b {
+			return uintWord(bigOne), nil
+		}
+		return make([]byte, wordSize), nil
+	case FixedBytesKind:
+		b, err := toBytes(v)
+		if err != nil {
+			return nil, err
+		}
+		if len(b) > t.Size {
+			return nil, fmt.Errorf("%d bytes do not fit %s", len(b), t)
+		}
+		return rightPad(b), nil
+	case BytesKind, StringKind:
+		b, err := toBytes(v)
+		if err != nil {
+			return nil, err
+		}
+		return append(uintWord(big.NewInt(int64(len(b)))), rightPad(b)...), nil
+	case SliceKind, ArrayKind:
+		elems, err := toSlice(v)
+		if err != nil {
+			return nil, err
+		}
+		if t.Kind == ArrayKind && len(elems) != t.Size {
+			return nil, fmt.Errorf("want %d elements, got %d", t.Size, len(elems))
+		}
+		types := make([]Type, len(elems))
+		for i := range types {
+			types[i] = *t.Elem
+		}
+		enc, err := encodeTuple(types, elems)
+		if err != nil {
+			return nil, err
+		}
+		if t.Kind == SliceKind {
+			enc = append(uintWord(big.NewInt(int64(len(elems)))), enc...)
+		}
+		return enc, nil
+	case TupleKind:
+		fields, err := toSlice(v)
+		if err != nil {
+			return nil, err
+		}
+		if len(fields) != len(t.Components) {
+			return nil, fmt.Errorf("want %d fields, got %d", len(t.Components), len(fields))
+		}
+		return encodeTuple(t.Components, fields)
+	}
+	return nil, fmt.Errorf("unsupported type %s", t)
+}
+
+func encodeInt(t Type, n *big.Int) ([]byte, error) {
+	if t.Kind == UintKind {
+		if n.Sign() < 0 || n.BitLen() > t.Size {
+			return nil, fmt.Errorf("%s out of range for %s", n, t)
+		}
+		return uintWord(n), nil
+	}
+	limit := new(big.Int).Lsh(bigOne, uint(t.Size-1))
+	if n.Cmp(limit) >= 0 || n.Cmp(new(big.Int).Neg(limit)) < 0 {
+		return nil, fmt.Errorf("%s out of range for %s", n, t)
+	}
+	if n.Sign() < 0 {
+		return uintWord(new(big.Int).Add(tt256, n)), nil
+	}
+	return uintWord(n), nil
+}
+
+func uintWord(n *big.Int) []byte {
+	return leftPad(n.Bytes())
+}
+
+func leftPad(b []byte) []byte {
+	word := make([]byte, wordSize)
+	copy(word[wordSize-len(b):], b)
+	return word
+}
+
+// rightPad pads b with zeros to a multiple of the word size.
+func rightPad(b []byte) []byte {
+	padded := make([]byte, (len(b)+wordSize-1)/wordSize*wordSize)
+	copy(padded, b)
+	return padded
+}
+
+func toBig(v interface{}) (*big.Int, error) {
+	switch n := v.(type) {
+	case *big.Int:
+		if n == nil {
+			return nil, fmt.Errorf("nil integer")
+		}
+		return n, nil
+	case big.Int:
+		return &n, nil
+	case string:
+		b, ok := new(big.Int).SetString(n, 0)
+		if !ok {
+			return nil, fmt.Errorf("invalid integer %q", n)
+		}
+		return b, nil
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return big.NewInt(rv.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return new(big.Int).SetUint64(rv.Uint()), nil
+	}
+	return nil, fmt.Errorf("want integer, got %T", v)
+}
+
+func toAddress(v interface{}) ([]byte, error) {
+	switch a := v.(type) {
+	case [20]byte:
+		return a[:], nil
+	case string:
+		b, err := DecodeHex(a)
+		if err != nil || len(b) != 20 {
+			return nil, fmt.Errorf("invalid address %q", a)
+		}
+		return b, nil
+	}
+	return nil, fmt.Errorf("want address, got %T", v)
+}
+
+func toBytes(v interface{}) ([]byte, error) {
+	switch b := v.(type) {
+	case []byte:
+		return b, nil
+	case string:
+		return []byte(b), nil
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Array && rv.Type().Elem().Kind() == reflect.Uint8 {
+		b := make([]byte, rv.Len())
+		reflect.Copy(reflect.ValueOf(b), rv)
+		return b, nil
+	}
+	return nil, fmt.Errorf("want bytes, got %T", v)
+}
+
+func toSlice(v interface{}) ([]interface{}, error) {
+	if s, ok := v.([]interface{}); ok {
+		return s, nil
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return nil, fmt.Errorf("want slice, got %T", v)
+	}
+	s := make([]interface{}, rv.Len())
+	for i := range s {
+		s[i] = rv.Index(i).Interface()
+	}
+	return s, nil
+}
+
+// DecodeHex decodes a hex string with or without 0x prefix.
+func DecodeHex(s string) ([]byte, error) {
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "0x"), "0X")
+	if len(s)%2 == 1 {
+		s = "0" + s
+	}
+	return hex.DecodeString(s)
+}
+
+// EncodeHex encodes b as a 0x prefixed hex string.
+func EncodeHex(b []byte) string {
+	return "0x" + hex.EncodeToString(b)
+}
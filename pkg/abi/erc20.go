@@ -0,0 +1,25 @@
+package abi
+
+import "math/big"
+
+// ERC-20 methods.
+var (
+	ERC20Transfer     = MustMethod("transfer(address,uint256)")
+	ERC20Approve      = MustMethod("approve(address,uint256)")
+	ERC20TransferFrom = MustMethod("transferFrom(address,address,uint256)")
+)
+
+// Transfer encodes transfer(to, amount).
+func Transfer(to string, amount *big.Int) ([]byte, error) {
+	return ERC20Transfer.Pack(to, amount)
+}
+
+// Approve encodes approve(spender, amount).
+func Approve(spender string, amount *big.Int) ([]byte, error) {
+	return ERC20Approve.Pack(spender, amount)
+}
+
+// TransferFrom encodes transferFrom(from, to, amount).
+func TransferFrom(from, to string, amount *big.Int) ([]byte, error) {
+	return ERC20TransferFrom.Pack(from, to, amount)
+}
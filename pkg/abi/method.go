@@ -0,0 +1,81 @@
+package abi
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/sha3"
+)
+
+// Keccak256 hashes data with the legacy Keccak-256 used by Ethereum.
+func Keccak256(data ...[]byte) []byte {
+	h := sha3.NewLegacyKeccak256()
+	for _, b := range data {
+		h.Write(b)
+	}
+	return h.Sum(nil)
+}
+
+// Selector is the first four bytes of the Keccak-256 of a canonical function
+// signature such as "transfer(address,uint256)".
+func Selector(signature string) []byte {
+	return Keccak256([]byte(signature))[:4]
+}
+
+// Method is a contract function parsed from its canonical signature.
+type Method struct {
+	Name      string
+	Signature string
+	Selector  []byte
+	Inputs    []Type
+}
+
+// NewMethod parses a signature such as "approve(address,uint256)".
+func NewMethod(signature string) (*Method, error) {
+	signature = strings.ReplaceAll(signature, " ", "")
+	open := strings.Index(signature, "(")
+	if open <= 0 || !strings.HasSuffix(signature, ")") {
+		return nil, fmt.Errorf("abi: invalid signature %q", signature)
+	}
+	inputs, err := ParseTypes(signature[open+1 : len(signature)-1])
+	if err != nil {
+		return nil, err
+	}
+	return &Method{
+		Name:      signature[:open],
+		Signature: signature,
+		Selector:  Selector(signature),
+		Inputs:    inputs,
+	}, nil
+}
+
+// MustMethod is NewMethod for signatures known to be valid.
+func MustMethod(signature string) *Method {
+	m, err := NewMethod(signature)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+// Pack encodes a call of the method with args.
+func (m *Method) Pack(args ...interface{}) ([]byte, error) {
+	enc, err := Encode(m.Inputs, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", m.Name, err)
+	}
+	return append(append([]byte{}, m.Selector...), enc...), nil
+}
+
+// Unpack decodes the arguments of a call of the method.
+func (m *Method) Unpack(calldata []byte) ([]interface{}, error) {
+	if len(calldata) < 4 || !bytes.Equal(calldata[:4], m.Selector) {
+		return nil, fmt.Errorf("abi: calldata is not a call of %s", m.Signature)
+	}
+	args, err := Decode(m.Inputs, calldata[4:])
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", m.Name, err)
+	}
+	return args, nil
+}
@@ -0,0 +1,148 @@
+package abi
+
+import (
+	"fmt"
+	"math/big"
+)
+
+// SimpleAccount methods of the ERC-4337 wallet.
+var (
+	AccountExecute      = MustMethod("execute(address,uint256,bytes)")
+	AccountExecuteBatch = MustMethod("executeBatch(address[],bytes[])")
+)
+
+// Uniswap SwapRouter02 methods.
+var (
+	RouterExactInputSingle  = MustMethod("exactInputSingle((address,address,uint24,address,uint256,uint256,uint160))")
+	RouterExactOutputSingle = MustMethod("exactOutputSingle((address,address,uint24,address,uint256,uint256,uint160))")
+	RouterExactInput        = MustMethod("exactInput((bytes,address,uint256,uint256))")
+	RouterExactOutput       = MustMethod("exactOutput((bytes,address,uint256,uint256))")
+	RouterUnwrapWETH9       = MustMethod("unwrapWETH9(uint256,address)")
+	RouterRefundETH         = MustMethod("refundETH()")
+	RouterMulticall         = MustMethod("multicall(bytes[])")
+)
+
+// RouterSelf is the recipient SwapRouter02 reads as the router itself, to
+// unwrap WETH before paying out.
+const RouterSelf = "0x0000000000000000000000000000000000000002"
+
+// CCIPRouterSend is Router.ccipSend of Chainlink CCIP, taking the destination
+// chain selector and an EVM2AnyMessage.
+var CCIPRouterSend = MustMethod("ccipSend(uint64,(bytes,bytes,(address,uint256)[],address,bytes))")
+
+// Execute encodes a SimpleAccount call of dest with value and data.
+func Execute(dest string, value *big.Int, data []byte) ([]byte, error) {
+	return AccountExecute.Pack(dest, value, data)
+}
+
+// ExecuteBatch encodes SimpleAccount calls of dests with data, without value.
+func ExecuteBatch(dests []string, data [][]byte) ([]byte, error) {
+	return AccountExecuteBatch.Pack(dests, data)
+}
+
+// SingleSwap are the parameters of a single pool swap on SwapRouter02. Amount
+// is the exact input or output and Limit the minimum output or maximum input.
+type SingleSwap struct {
+	TokenIn           string
+	TokenOut          string
+	Fee               uint32
+	Recipient         string
+	Amount            *big.Int
+	Limit             *big.Int
+	SqrtPriceLimitX96 *big.Int
+}
+
+func (s SingleSwap) tuple() []interface{} {
+	limit := s.SqrtPriceLimitX96
+	if limit == nil {
+		limit = new(big.Int)
+	}
+	return []interface{}{s.TokenIn, s.TokenOut, s.Fee, s.Recipient, s.Amount, s.Limit, limit}
+}
+
+// ExactInputSingle encodes a swap of exactly s.Amount of TokenIn for at least
+// s.Limit of TokenOut.
+func ExactInputSingle(s SingleSwap) ([]byte, error) {
+	return RouterExactInputSingle.Pack(s.tuple())
+}
+
+// ExactOutputSingle encodes a swap of at most s.Limit of TokenIn for exactly
+// s.Amount of TokenOut.
+func ExactOutputSingle(s SingleSwap) ([]byte, error) {
+	return RouterExactOutputSingle.Pack(s.tuple())
+}
+
+// PathSwap are the parameters of a multi pool swap on SwapRouter02. Path is
+// the encoded pool path, see EncodePath, reversed for exact outputs.
+type PathSwap struct {
+	Path      []byte
+	Recipient string
+	Amount    *big.Int
+	Limit     *big.Int
+}
+
+// EncodePath packs tokens and the fees of the pools between them as
+// token, fee, token, ... with 3 byte fees.
+func EncodePath(tokens []string, fees []uint32) ([]byte, error) {
+	if len(tokens) != len(fees)+1 {
+		return nil, fmt.Errorf("abi: path of %d tokens has %d fees", len(tokens), len(fees))
+	}
+	var path []byte
+	for i, token := range tokens {
+		address, err := toAddress(token)
+		if err != nil {
+			return nil, err
+		}
+		path = append(path, address...)
+		if i < len(fees) {
+			path = append(path, byte(fees[i]>>16), byte(fees[i]>>8), byte(fees[i]))
+		}
+	}
+	return path, nil
+}
+
+// ExactInput encodes a swap of exactly s.Amount along s.Path for at least
+// s.Limit of the last token.
+func ExactInput(s PathSwap) ([]byte, error) {
+	return RouterExactInput.Pack([]interface{}{s.Path, s.Recipient, s.Amount, s.Limit})
+}
+
+// ExactOutput encodes a swap of at most s.Limit along the reversed s.Path
+// for exactly s.Amount of its first token.
+func ExactOutput(s PathSwap) ([]byte, error) {
+	return RouterExactOutput.Pack([]interface{}{s.Path, s.Recipient, s.Amount, s.Limit})
+}
+
+// UnwrapWETH9 encodes paying out the WETH held by the router as at least
+// minimum native tokens to recipient.
+func UnwrapWETH9(minimum *big.Int, recipient string) ([]byte, error) {
+	return RouterUnwrapWETH9.Pack(minimum, recipient)
+}
+
+// RefundETH encodes returning the native tokens the router did not spend.
+func RefundETH() ([]byte, error) {
+	return RouterRefundETH.Pack()
+}
+
+// Multicall encodes router calls executed in one transaction.
+func Multicall(calls [][]byte) ([]byte, error) {
+	return RouterMulticall.Pack(calls)
+}
+
+// CCIPSend encodes a CCIP transfer of amount of token to receiver on the chain
+// identified by destChainSelector. Fees are paid in feeToken, or natively as
+// the call value when feeToken is the zero address.
+func CCIPSend(destChainSelector uint64, receiver, token string, amount *big.Int, feeToken string) ([]byte, error) {
+	to, err := Encode(MustTypes("address"), receiver)
+	if err != nil {
+		return nil, err
+	}
+	message := []interface{}{
+		to,
+		[]byte{},
+		[]interface{}{[]interface{}{token, amount}},
+		feeToken,
+		[]byte{},
+	}
+	return CCIPRouterSend.Pack(destChainSelector, message)
+}
@@ -0,0 +1,210 @@
+// Package abi encodes and decodes Solidity contract calls following the
+// Ethereum contract ABI specification, enough to build ERC-20, router and
+// account calls without a node.
+package abi
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type Kind int
+
+const (
+	UintKind Kind = iota
+	IntKind
+	AddressKind
+	BoolKind
+	FixedBytesKind
+	BytesKind
+	StringKind
+	SliceKind
+	ArrayKind
+	TupleKind
+)
+
+// Type is a parsed ABI type such as uint256, address[] or (address,uint24).
+type Type struct {
+	Kind Kind
+	// Size is the bit size of integers, the length of bytesN and of fixed
+	// arrays.
+	Size       int
+	Elem       *Type
+	Components []Type
+	raw        string
+}
+
+func (t Type) String() string {
+	return t.raw
+}
+
+// ParseType parses the canonical name of an ABI type.
+func ParseType(s string) (Type, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return Type{}, fmt.Errorf("abi: empty type")
+	}
+	if strings.HasSuffix(s, "]") {
+		open := strings.LastIndex(s, "[")
+		if open < 0 {
+			return Type{}, fmt.Errorf("abi: invalid type %q", s)
+		}
+		elem, err := ParseType(s[:open])
+		if err != nil {
+			return Type{}, err
+		}
+		length := s[open+1 : len(s)-1]
+		if length == "" {
+			return Type{Kind: SliceKind, Elem: &elem, raw: s}, nil
+		}
+		n, err := strconv.Atoi(length)
+		if err != nil || n <= 0 {
+			return Type{}, fmt.Errorf("abi: invalid array length in %q", s)
+		}
+		return Type{Kind: ArrayKind, Size: n, Elem: &elem, raw: s}, nil
+	}
+	if strings.HasPrefix(s, "(") && strings.HasSuffix(s, ")") {
+		parts, err := splitTypes(s[1 : len(s)-1])
+		if err != nil {
+			return Type{}, err
+		}
+		components := make([]Type, 0, len(parts))
+		for _, part := range parts {
+			c, err := ParseType(part)
+			if err != nil {
+				return Type{}, err
+			}
+			components = append(components, c)
+		}
+		return Type{Kind: TupleKind, Components: components, raw: s}, nil
+	}
+	switch {
+	case s == "address":
+		return Type{Kind: AddressKind, Size: 160, raw: s}, nil
+	case s == "bool":
+		return Type{Kind: BoolKind, raw: s}, nil
+	case s == "bytes":
+		return Type{Kind: BytesKind, raw: s}, nil
+	case s == "string":
+		return Type{Kind: StringKind, raw: s}, nil
+	case strings.HasPrefix(s, "uint"):
+		size, err := intSize(s, "uint")
+		return Type{Kind: UintKind, Size: size, raw: s}, err
+	case strings.HasPrefix(s, "int"):
+		size, err := intSize(s, "int")
+		return Type{Kind: IntKind, Size: size, raw: s}, err
+	case strings.HasPrefix(s, "bytes"):
+		n, err := strconv.Atoi(strings.TrimPrefix(s, "bytes"))
+		if err != nil || n < 1 || n > 32 {
+			return Type{}, fmt.Errorf("abi: invalid type %q", s)
+		}
+		return Type{Kind: FixedBytesKind, Size: n, raw: s}, nil
+	}
+	return Type{}, fmt.Errorf("abi: unsupported type %q", s)
+}
+
+// ParseTypes parses a comma separated list of types, e.g. "address,uint256".
+func ParseTypes(s string) ([]Type, error) {
+	parts, err := splitTypes(s)
+	if err != nil {
+		return nil, err
+	}
+	types := make([]Type, 0, len(parts))
+	for _, part := range parts {
+		t, err := ParseType(part)
+		if err != nil {
+			return nil, err
+		}
+		types = append(types, t)
+	}
+	return types, nil
+}
+
+// MustTypes is ParseTypes for type lists known to be valid.
+func MustTypes(s string) []Type {
+	types, err := ParseTypes(s)
+	if err != nil {
+		panic(err)
+	}
+	return types
+}
+
+func intSize(s, prefix string) (int, error) {
+	digits := strings.TrimPrefix(s, prefix)
+	if digits == "" {
+		return 256, fmt.Errorf("abi: use the canonical %s256 instead of %q", prefix, s)
+	}
+	n, err := strconv.Atoi(digits)
+	if err != nil || n < 8 || n > 256 || n%8 != 0 {
+		return 0, fmt.Errorf("abi: invalid type %q", s)
+	}
+	return n, nil
+}
+
+// splitTypes splits a type list on the commas outside of tuples.
+func splitTypes(s string) ([]string, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+	var (
+		parts []string
+		depth int
+		start int
+	)
+	for i, r := range s {
+		switch r {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth < 0 {
+				return nil, fmt.Errorf("abi: unbalanced parentheses in %q", s)
+			}
+		case ',':
+			if depth == 0 {
+				parts = append(parts, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	if depth != 0 {
+		return nil, fmt.Errorf("abi: unbalanced parentheses in %q", s)
+	}
+	return append(parts, s[start:]), nil
+}
+
+// dynamic reports whether values of t are encoded in the tail.
+func (t Type) dynamic() bool {
+	switch t.Kind {
+	case BytesKind, StringKind, SliceKind:
+		return true
+	case ArrayKind:
+		return t.Elem.dynamic()
+	case TupleKind:
+		for _, c := range t.Components {
+			if c.dynamic() {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// headSize is the number of bytes t takes in the head of its enclosing tuple.
+func (t Type) headSize() int {
+	if t.dynamic() {
+		return wordSize
+	}
+	switch t.Kind {
+	case ArrayKind:
+		return t.Size * t.Elem.headSize()
+	case TupleKind:
+		size := 0
+		for _, c := range t.Components {
+			size += c.headSize()
+		}
+		return size
+	}
+	return wordSize
+}
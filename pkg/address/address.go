@@ -0,0 +1,51 @@
+// Package address validates and normalizes receiver addresses in the format of
+// the chain family they are sent on.
+package address
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/smarterwallet/demand-abstraction-serv/data"
+)
+
+// Family is a group of chains sharing one address format.
+type Family string
+
+const (
+	FamilyEVM    Family = "evm"
+	FamilySolana Family = "solana"
+)
+
+// FamilyOf returns the family chains give chain; unknown chains are EVM
+// chains.
+func FamilyOf(chains *data.Snapshot, chain string) Family {
+	if family := chains.ChainFamily(chain); family != "" {
+		return Family(family)
+	}
+	return FamilyEVM
+}
+
+// InvalidError explains why an address was rejected.
+type InvalidError struct {
+	Address string
+	Reason  string
+}
+
+func (e *InvalidError) Error() string {
+	return fmt.Sprintf("invalid address %q: %s", e.Address, e.Reason)
+}
+
+// Normalize validates address for chain and returns its canonical form: the
+// EIP-55 checksummed address on EVM chains.
+func Normalize(chains *data.Snapshot, chain, address string) (string, error) {
+	address = strings.TrimSpace(address)
+	if address == "" {
+		return "", &InvalidError{Address: address, Reason: "the address is empty"}
+	}
+	switch FamilyOf(chains, chain) {
+	case FamilySolana:
+		return normalizeSolana(address)
+	}
+	return normalizeEVM(address)
+}
@@ -0,0 +1,53 @@
+package address
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/smarterwallet/demand-abstraction-serv/data"
+)
+
+func TestNormalize(t *testing.T) {
+	aliases, err := data.LoadChainAliases("")
+	assert.Nil(t, err)
+	chains := data.NewSnapshotBuilder(aliases, nil).Build()
+	cases := []struct {
+		name    string
+		chain   string
+		address string
+		want    string
+		reason  string
+	}{
+		{"lowercase", "mumbai", "0xd8da6bf26964af9d7eed9e03e53415d37aa96045", "0xd8dA6BF26964aF9D7eEd9e03E53415D37aA96045", ""},
+		{"uppercase", "fuji", "0XD8DA6BF26964AF9D7EED9E03E53415D37AA96045", "0xd8dA6BF26964aF9D7eEd9e03E53415D37aA96045", ""},
+		{"checksummed", "", " 0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed ", "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed", ""},
+		{"checksummed", "", "0xfB6916095ca1df60bB79Ce92cE3Ea74c37c5d359", "0xfB6916095ca1df60bB79Ce92cE3Ea74c37c5d359", ""},
+		{"checksummed", "", "0xdbF03B407c01E7cD3CBea99509d93f8DDDC8C6FB", "0xdbF03B407c01E7cD3CBea99509d93f8DDDC8C6FB", ""},
+		{"checksummed", "", "0xD1220A0cf47c7B9Be7A2E6BA89F429762e7b9aDb", "0xD1220A0cf47c7B9Be7A2E6BA89F429762e7b9aDb", ""},
+		{"bad checksum", "", "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAeD", "", "checksum"},
+		{"truncated", "", "0xd8da6bf26964af9d7eed9e03e53415d37aa9604", "", "40 hex characters"},
+		{"no prefix", "", "d8da6bf26964af9d7eed9e03e53415d37aa96045", "", "start with 0x"},
+		{"not hex", "", "0xd8da6bf26964af9d7eed9e03e53415d37aa9604g", "", "not hex"},
+		{"zero", "", "0x0000000000000000000000000000000000000000", "", "zero address"},
+		{"precompile", "", "0x0000000000000000000000000000000000000004", "", "precompiled"},
+		{"empty", "", " ", "", "empty"},
+		{"solana", "Solana", "EPjFWdd5AufqSSqeM2qN1xzybapC8G4wEGGkZwyTDt1v", "EPjFWdd5AufqSSqeM2qN1xzybapC8G4wEGGkZwyTDt1v", ""},
+		{"solana evm address", "solana", "0xd8da6bf26964af9d7eed9e03e53415d37aa96045", "", "base58"},
+		{"solana short", "solana", "EPjFWdd5AufqSSqeM2qN1xzyb", "", "32 byte"},
+		{"solana system program", "solana", "11111111111111111111111111111111", "", "system program"},
+		{"solana testnet", "solana-testnet", "0xd8da6bf26964af9d7eed9e03e53415d37aa96045", "", "base58"},
+		{"solana alias", "sol devnet", "EPjFWdd5AufqSSqeM2qN1xzybapC8G4wEGGkZwyTDt1v", "EPjFWdd5AufqSSqeM2qN1xzybapC8G4wEGGkZwyTDt1v", ""},
+	}
+	for _, c := range cases {
+		got, err := Normalize(chains, c.chain, c.address)
+		if c.reason == "" {
+			assert.Nil(t, err, c.name)
+			assert.Equal(t, c.want, got, c.name)
+			continue
+		}
+		if assert.NotNil(t, err, c.name) {
+			assert.Contains(t, err.(*InvalidError).Reason, c.reason, c.name)
+		}
+	}
+}
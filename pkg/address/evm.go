@@ -0,0 +1,54 @@
+package address
+
+import (
+	"encoding/hex"
+	"math/big"
+	"strings"
+
+	"github.com/smarterwallet/demand-abstraction-serv/pkg/abi"
+)
+
+// maxPrecompile is the highest address reserved for precompiled contracts.
+// Ethereum uses 0x01 to 0x11 so far; the rest of the range is kept for new ones.
+var maxPrecompile = big.NewInt(0xff)
+
+func normalizeEVM(address string) (string, error) {
+	digits := address
+	if strings.HasPrefix(digits, "0x") || strings.HasPrefix(digits, "0X") {
+		digits = digits[2:]
+	} else {
+		return "", &InvalidError{Address: address, Reason: "the address must start with 0x"}
+	}
+	if len(digits) != 40 {
+		return "", &InvalidError{Address: address, Reason: "the address must have 40 hex characters"}
+	}
+	b, err := hex.DecodeString(digits)
+	if err != nil {
+		return "", &InvalidError{Address: address, Reason: "the address is not hex"}
+	}
+	checksummed := Checksum(b)
+	if digits != strings.ToLower(digits) && digits != strings.ToUpper(digits) && digits != checksummed[2:] {
+		return "", &InvalidError{Address: address, Reason: "the EIP-55 checksum does not match, a character may be mistyped"}
+	}
+	n := new(big.Int).SetBytes(b)
+	if n.Sign() == 0 {
+		return "", &InvalidError{Address: address, Reason: "the zero address burns the funds"}
+	}
+	if n.Cmp(maxPrecompile) <= 0 {
+		return "", &InvalidError{Address: address, Reason: "the address is reserved for precompiled contracts"}
+	}
+	return checksummed, nil
+}
+
+// Checksum returns the EIP-55 mixed case encoding of a 20 byte address.
+func Checksum(address []byte) string {
+	lower := hex.EncodeToString(address)
+	hash := hex.EncodeToString(abi.Keccak256([]byte(lower)))
+	out := []byte(lower)
+	for i, c := range out {
+		if c >= 'a' && hash[i] >= '8' {
+			out[i] = c - 'a' + 'A'
+		}
+	}
+	return "0x" + string(out)
+}
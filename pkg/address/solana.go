@@ -0,0 +1,43 @@
+package address
+
+import (
+	"math/big"
+	"strings"
+)
+
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+// systemProgram is the all zero Solana public key.
+const systemProgram = "11111111111111111111111111111111"
+
+func normalizeSolana(address string) (string, error) {
+	b, ok := decodeBase58(address)
+	if !ok {
+		return "", &InvalidError{Address: address, Reason: "the address is not base58"}
+	}
+	if len(b) != 32 {
+		return "", &InvalidError{Address: address, Reason: "the address must be a 32 byte public key"}
+	}
+	if address == systemProgram {
+		return "", &InvalidError{Address: address, Reason: "the system program cannot receive tokens"}
+	}
+	return address, nil
+}
+
+func decodeBase58(s string) ([]byte, bool) {
+	n := new(big.Int)
+	radix := big.NewInt(58)
+	for _, r := range s {
+		i := strings.IndexRune(base58Alphabet, r)
+		if i < 0 {
+			return nil, false
+		}
+		n.Mul(n, radix)
+		n.Add(n, big.NewInt(int64(i)))
+	}
+	zeros := 0
+	for zeros < len(s) && s[zeros] == '1' {
+		zeros++
+	}
+	return append(make([]byte, zeros), n.Bytes()...), true
+}
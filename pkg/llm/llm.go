@@ -2,6 +2,7 @@ package llm
 
 import (
 	"context"
+	"fmt"
 
 	log "github.com/cihub/seelog"
 
@@ -11,8 +12,9 @@ import (
 	"github.com/smarterwallet/demand-abstraction-serv/config"
 )
 
+// Llm answers a conversation with the tool calls to make, in the order given.
 type Llm interface {
-	Chat(ctx context.Context, prompt, content string, functions []openai.FunctionDefinition) (string, string, error)
+	Chat(ctx context.Context, messages []openai.ChatCompletionMessage, functions []openai.FunctionDefinition) ([]openai.ToolCall, error)
 }
 
 type OpenAI struct {
@@ -24,14 +26,18 @@ func NewOpenAI(cfg *config.AiConfig) *OpenAI {
 	if cfg.APIKey == "" || cfg.Model == "" {
 		panic("missing apikey or model")
 	}
-	openaiCfg := openai.DefaultConfig(cfg.APIKey)
-	if cfg.Endpoint != "" {
-		openaiCfg.BaseURL = cfg.Endpoint
+	return newOpenAI(cfg.Endpoint, cfg.Model, cfg.APIKey)
+}
+
+func newOpenAI(endpoint, model, apiKey string) *OpenAI {
+	openaiCfg := openai.DefaultConfig(apiKey)
+	if endpoint != "" {
+		openaiCfg.BaseURL = endpoint
 	}
-	return &OpenAI{client: openai.NewClientWithConfig(openaiCfg), model: cfg.Model}
+	return &OpenAI{client: openai.NewClientWithConfig(openaiCfg), model: model}
 }
 
-func (a *OpenAI) Chat(ctx context.Context, prompt, content string, functions []openai.FunctionDefinition) (string, string, error) {
+func (a *OpenAI) Chat(ctx context.Context, messages []openai.ChatCompletionMessage, functions []openai.FunctionDefinition) ([]openai.ToolCall, error) {
 	tools := make([]openai.Tool, 0, len(functions))
 	for _, function := range functions {
 		tools = append(tools, openai.Tool{
@@ -40,38 +46,29 @@ func (a *OpenAI) Chat(ctx context.Context, prompt, content string, functions []o
 		})
 	}
 	request := openai.ChatCompletionRequest{
-		Model: a.model,
-		Messages: []openai.ChatCompletionMessage{
-			{
-				Role:    openai.ChatMessageRoleAssistant,
-				Content: prompt,
-			},
-			{
-				Role:    openai.ChatMessageRoleUser,
-				Content: content,
-			},
-		},
-		Tools: tools,
+		Model:    a.model,
+		Messages: messages,
+		Tools:    tools,
 	}
 	resp, err := a.client.CreateChatCompletion(ctx, request)
 	if err != nil {
-		return "", "", err
+		return nil, err
 	}
 	if len(resp.Choices) == 0 || len(resp.Choices[0].Message.ToolCalls) == 0 {
 		log.Errorf("empty response resp=%+v", resp)
-		return "", "", errors.New("empty response")
+		return nil, errors.New("empty response")
 	}
-	call := resp.Choices[0].Message.ToolCalls[0].Function
-	return call.Name, call.Arguments, nil
-}
-
-type MockOpenAI struct {
+	return resp.Choices[0].Message.ToolCalls, nil
 }
 
-func NewMockOpenAI() *OpenAI {
-	return &OpenAI{}
-}
-
-func (a *MockOpenAI) Chat(ctx context.Context, prompt string, functions []openai.FunctionDefinition) (string, string, error) {
-	return "mock name", "{}", nil
+// NewToolCall wraps a function call produced without a hosted model.
+func NewToolCall(index int, name, args string) openai.ToolCall {
+	return openai.ToolCall{
+		ID:   fmt.Sprintf("call_%d", index),
+		Type: openai.ToolTypeFunction,
+		Function: openai.FunctionCall{
+			Name:      name,
+			Arguments: args,
+		},
+	}
 }
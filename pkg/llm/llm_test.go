@@ -56,8 +56,9 @@ func get_trade_to_earn_strategy(input input) {
 func TestChat(t *testing.T) {
 	client := NewOpenAI(cfg)
 	t.Run("high return", func(t *testing.T) {
-		name, args, err := client.Chat(ctx, prompt, "I want high return with MATIC", functions)
+		calls, err := client.Chat(ctx, BuildMessages(prompt, nil, "I want high return with MATIC"), functions)
 		assert.Nil(t, err)
+		name, args := calls[0].Function.Name, calls[0].Function.Arguments
 		assert.Equal(t, name, "get_trade_to_earn_strategy")
 		in := input{}
 		err = json.Unmarshal([]byte(args), &in)
@@ -65,8 +66,9 @@ func TestChat(t *testing.T) {
 		get_trade_to_earn_strategy(in)
 	})
 	t.Run("low return", func(t *testing.T) {
-		name, args, err := client.Chat(ctx, prompt, "I want low return with MATIC", functions)
+		calls, err := client.Chat(ctx, BuildMessages(prompt, nil, "I want low return with MATIC"), functions)
 		assert.Nil(t, err)
+		name, args := calls[0].Function.Name, calls[0].Function.Arguments
 		assert.Equal(t, name, "get_trade_to_earn_strategy")
 		in := input{}
 		err = json.Unmarshal([]byte(args), &in)
@@ -74,8 +76,9 @@ func TestChat(t *testing.T) {
 		get_trade_to_earn_strategy(in)
 	})
 	t.Run("irrelevant", func(t *testing.T) {
-		name, args, err := client.Chat(ctx, prompt, "what's the weather like today", functions)
+		calls, err := client.Chat(ctx, BuildMessages(prompt, nil, "what's the weather like today"), functions)
 		assert.Nil(t, err)
+		name, args := calls[0].Function.Name, calls[0].Function.Arguments
 		assert.Equal(t, name, "get_trade_to_earn_strategy")
 		in := input{}
 		err = json.Unmarshal([]byte(args), &in)
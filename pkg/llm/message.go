@@ -0,0 +1,63 @@
+package llm
+
+import (
+	"github.com/sashabaranov/go-openai"
+	"github.com/smarterwallet/demand-abstraction-serv/model"
+)
+
+// BuildMessages turns the strategy prompt, the stored conversation and the
+// current user content into an ordered chat message list.
+func BuildMessages(prompt string, history []model.Dialogue, content string) []openai.ChatCompletionMessage {
+	messages := make([]openai.ChatCompletionMessage, 0, len(history)+2)
+	if prompt != "" {
+		messages = append(messages, openai.ChatCompletionMessage{
+			Role:    openai.ChatMessageRoleSystem,
+			Content: prompt,
+		})
+	}
+	calls := make(map[string]struct{})
+	for _, dialogue := range history {
+		switch dialogue.Role {
+		case model.DialogueRoleUser:
+			messages = append(messages, openai.ChatCompletionMessage{
+				Role:    openai.ChatMessageRoleUser,
+				Content: dialogue.Content,
+			})
+		case model.DialogueRoleAI:
+			if dialogue.Type == model.DialogueToolCall {
+				calls[dialogue.ToolCallID] = struct{}{}
+				messages = append(messages, openai.ChatCompletionMessage{
+					Role: openai.ChatMessageRoleAssistant,
+					ToolCalls: []openai.ToolCall{{
+						ID:   dialogue.ToolCallID,
+						Type: openai.ToolTypeFunction,
+						Function: openai.FunctionCall{
+							Name:      dialogue.Name,
+							Arguments: dialogue.Content,
+						},
+					}},
+				})
+				continue
+			}
+			messages = append(messages, openai.ChatCompletionMessage{
+				Role:    openai.ChatMessageRoleAssistant,
+				Content: dialogue.Content,
+			})
+		case model.DialogueRoleTool:
+			// the history window may have cut off the call this result answers
+			if _, ok := calls[dialogue.ToolCallID]; !ok {
+				continue
+			}
+			messages = append(messages, openai.ChatCompletionMessage{
+				Role:       openai.ChatMessageRoleTool,
+				Content:    dialogue.Content,
+				ToolCallID: dialogue.ToolCallID,
+			})
+		}
+	}
+	messages = append(messages, openai.ChatCompletionMessage{
+		Role:    openai.ChatMessageRoleUser,
+		Content: content,
+	})
+	return messages
+}
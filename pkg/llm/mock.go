@@ -0,0 +1,155 @@
+package llm
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"os"
+	"regexp"
+	"strconv"
+
+	"github.com/pkg/errors"
+	"github.com/sashabaranov/go-openai"
+	"github.com/sashabaranov/go-openai/jsonschema"
+)
+
+//go:embed fixtures/mock.json
+var defaultMockFixtures []byte
+
+// ErrNoFixture is returned by Mock when no fixture matches the request.
+var ErrNoFixture = errors.New("no mock fixture matched")
+
+// MockFixture scripts the tool call returned when Function is offered and
+// Pattern (and Prompt, if set) match the latest user message (and system prompt).
+// String arguments may reference named groups of Pattern as ${name}; they are
+// converted to the number or boolean type declared by the function schema.
+// Calls scripts several tool calls at once and replaces Name and Arguments.
+type MockFixture struct {
+	Function  string                 `json:"function"`
+	Pattern   string                 `json:"pattern"`
+	Prompt    string                 `json:"prompt,omitempty"`
+	Name      string                 `json:"name,omitempty"`
+	Arguments map[string]interface{} `json:"arguments"`
+	Calls     []MockCall             `json:"calls,omitempty"`
+
+	pattern *regexp.Regexp
+	prompt  *regexp.Regexp
+}
+
+type MockCall struct {
+	Name      string                 `json:"name"`
+	Arguments map[string]interface{} `json:"arguments"`
+}
+
+// Mock is a deterministic backend replaying scripted tool calls, so the service
+// runs without an api key.
+type Mock struct {
+	fixtures []MockFixture
+}
+
+// NewMock loads fixtures from path, or the bundled fixtures when path is empty.
+func NewMock(path string) (*Mock, error) {
+	buf := defaultMockFixtures
+	if path != "" {
+		var err error
+		if buf, err = os.ReadFile(path); err != nil {
+			return nil, errors.Wrap(err, "read mock fixtures")
+		}
+	}
+	var fixtures []MockFixture
+	if err := json.Unmarshal(buf, &fixtures); err != nil {
+		return nil, errors.Wrap(err, "parse mock fixtures")
+	}
+	return NewMockWithFixtures(fixtures)
+}
+
+func NewMockWithFixtures(fixtures []MockFixture) (*Mock, error) {
+	for i := range fixtures {
+		f := &fixtures[i]
+		if f.Function == "" {
+			return nil, errors.Errorf("mock fixture %d: missing function", i)
+		}
+		var err error
+		if f.pattern, err = regexp.Compile(f.Pattern); err != nil {
+			return nil, errors.Wrapf(err, "mock fixture %d", i)
+		}
+		if f.Prompt != "" {
+			if f.prompt, err = regexp.Compile(f.Prompt); err != nil {
+				return nil, errors.Wrapf(err, "mock fixture %d", i)
+			}
+		}
+		if len(f.Calls) == 0 {
+			name := f.Name
+			if name == "" {
+				name = f.Function
+			}
+			f.Calls = []MockCall{{Name: name, Arguments: f.Arguments}}
+		}
+	}
+	return &Mock{fixtures: fixtures}, nil
+}
+
+func (m *Mock) Chat(ctx context.Context, messages []openai.ChatCompletionMessage, functions []openai.FunctionDefinition) ([]openai.ToolCall, error) {
+	offered := make(map[string]jsonschema.Definition, len(functions))
+	for _, function := range functions {
+		params, _ := function.Parameters.(jsonschema.Definition)
+		offered[function.Name] = params
+	}
+	demand := lastUserContent(messages)
+	prompt := systemContent(messages)
+	for _, f := range m.fixtures {
+		if _, ok := offered[f.Function]; !ok {
+			continue
+		}
+		if f.prompt != nil && !f.prompt.MatchString(prompt) {
+			continue
+		}
+		match := f.pattern.FindStringSubmatchIndex(demand)
+		if match == nil {
+			continue
+		}
+		calls := make([]openai.ToolCall, 0, len(f.Calls))
+		for i, call := range f.Calls {
+			args := make(map[string]interface{}, len(call.Arguments))
+			for key, value := range call.Arguments {
+				tmpl, ok := value.(string)
+				if !ok {
+					args[key] = value
+					continue
+				}
+				expanded := string(f.pattern.ExpandString(nil, tmpl, demand, match))
+				args[key] = typedArgument(offered[call.Name].Properties[key].Type, expanded)
+			}
+			buf, err := json.Marshal(args)
+			if err != nil {
+				return nil, err
+			}
+			calls = append(calls, NewToolCall(i, call.Name, string(buf)))
+		}
+		return calls, nil
+	}
+	return nil, ErrNoFixture
+}
+
+func typedArgument(t jsonschema.DataType, value string) interface{} {
+	switch t {
+	case jsonschema.Number, jsonschema.Integer:
+		if _, err := strconv.ParseFloat(value, 64); err == nil {
+			return json.Number(value)
+		}
+	case jsonschema.Boolean:
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+	}
+	return value
+}
+
+func systemContent(messages []openai.ChatCompletionMessage) string {
+	for _, message := range messages {
+		if message.Role == openai.ChatMessageRoleSystem {
+			return message.Content
+		}
+	}
+	return ""
+}
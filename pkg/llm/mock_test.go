@@ -0,0 +1,76 @@
+package llm
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/sashabaranov/go-openai"
+	"github.com/sashabaranov/go-openai/jsonschema"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMock(t *testing.T) {
+	mock, err := NewMock("")
+	assert.Nil(t, err)
+	selectFn := []openai.FunctionDefinition{{
+		Name: "select_strategy",
+		Parameters: jsonschema.Definition{
+			Type:       jsonschema.Object,
+			Properties: map[string]jsonschema.Definition{"strategy": {Type: jsonschema.String}},
+		},
+	}}
+	transferFn := []openai.FunctionDefinition{{
+		Name: "get_trade_strategy",
+		Parameters: jsonschema.Definition{
+			Type: jsonschema.Object,
+			Properties: map[string]jsonschema.Definition{
+				"token":        {Type: jsonschema.String},
+				"amount":       {Type: jsonschema.Number},
+				"receiver":     {Type: jsonschema.String},
+				"target_chain": {Type: jsonschema.String},
+				"is_usd":       {Type: jsonschema.Boolean},
+			},
+		},
+	}}
+	t.Run("select transfer", func(t *testing.T) {
+		calls, err := mock.Chat(ctx, BuildMessages("", nil, "I want to transfer 80 USDC to 0x5134F00C95b8e794db38E1eE39397d8086cee7Ed on mumbai"), selectFn)
+		assert.Nil(t, err)
+		assert.Equal(t, "select_strategy", calls[0].Function.Name)
+		assert.JSONEq(t, `{"strategy":"transfer"}`, calls[0].Function.Arguments)
+	})
+	t.Run("select trade2Earn", func(t *testing.T) {
+		calls, err := mock.Chat(ctx, BuildMessages("", nil, "I want High return and low risk"), selectFn)
+		assert.Nil(t, err)
+		assert.JSONEq(t, `{"strategy":"trade2Earn"}`, calls[0].Function.Arguments)
+	})
+	t.Run("transfer arguments", func(t *testing.T) {
+		calls, err := mock.Chat(ctx, BuildMessages("", nil, "I want to transfer 120 dollars to 0x5134F00C95b8e794db38E1eE39397d8086cee7Ed on target chain fuji"), transferFn)
+		assert.Nil(t, err)
+		assert.Equal(t, "get_trade_strategy", calls[0].Function.Name)
+		in := map[string]interface{}{}
+		assert.Nil(t, json.Unmarshal([]byte(calls[0].Function.Arguments), &in))
+		assert.Equal(t, 120.0, in["amount"])
+		assert.Equal(t, "USDC", in["token"])
+		assert.Equal(t, true, in["is_usd"])
+		assert.Equal(t, "fuji", in["target_chain"])
+	})
+	t.Run("trade to earn", func(t *testing.T) {
+		calls, err := mock.Chat(ctx, BuildMessages("", nil, "I want high return with MATIC"), functions)
+		assert.Nil(t, err)
+		assert.Equal(t, "get_trade_to_earn_strategy", calls[0].Function.Name)
+		in := input{}
+		assert.Nil(t, json.Unmarshal([]byte(calls[0].Function.Arguments), &in))
+		assert.Equal(t, "20%", in.Maximum)
+	})
+	t.Run("multi intent", func(t *testing.T) {
+		calls, err := mock.Chat(ctx, BuildMessages("", nil, "swap 50 DAI to USDC and send 20 USDC to 0x5134F00C95b8e794db38E1eE39397d8086cee7Ed on fuji"), transferFn)
+		assert.Nil(t, err)
+		assert.Len(t, calls, 2)
+		assert.Equal(t, "swap_token", calls[0].Function.Name)
+		assert.Equal(t, "get_trade_strategy", calls[1].Function.Name)
+	})
+	t.Run("no fixture", func(t *testing.T) {
+		_, err := mock.Chat(ctx, BuildMessages("", nil, "reset my password"), selectFn)
+		assert.ErrorIs(t, err, ErrNoFixture)
+	})
+}
@@ -0,0 +1,139 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/cihub/seelog"
+	"github.com/pkg/errors"
+	"github.com/sashabaranov/go-openai"
+	"github.com/smarterwallet/demand-abstraction-serv/config"
+)
+
+const (
+	ProviderOpenAI = "openai"
+	ProviderLocal  = "local"
+	ProviderRule   = "rule"
+	ProviderMock   = "mock"
+
+	defaultTimeout = 30 * time.Second
+)
+
+// Factory builds a llm backend from the ai config.
+type Factory func(cfg *config.AiConfig) (Llm, error)
+
+var (
+	providersMu sync.RWMutex
+	providers   = map[string]Factory{
+		ProviderOpenAI: func(cfg *config.AiConfig) (Llm, error) {
+			if cfg.APIKey == "" || cfg.Model == "" {
+				return nil, errors.New("missing apikey or model")
+			}
+			return newOpenAI(cfg.Endpoint, cfg.Model, cfg.APIKey), nil
+		},
+		ProviderLocal: func(cfg *config.AiConfig) (Llm, error) {
+			if cfg.Local == nil || cfg.Local.Endpoint == "" || cfg.Local.Model == "" {
+				return nil, errors.New("missing local endpoint or model")
+			}
+			return newOpenAI(cfg.Local.Endpoint, cfg.Local.Model, cfg.Local.APIKey), nil
+		},
+		ProviderRule: func(cfg *config.AiConfig) (Llm, error) {
+			return NewRule(), nil
+		},
+		ProviderMock: func(cfg *config.AiConfig) (Llm, error) {
+			return NewMock(cfg.MockFixture)
+		},
+	}
+)
+
+// Register makes a llm backend available under name, replacing any previous one.
+func Register(name string, factory Factory) {
+	providersMu.Lock()
+	defer providersMu.Unlock()
+	providers[strings.ToLower(name)] = factory
+}
+
+// Providers returns the registered backend names.
+func Providers() []string {
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+	names := make([]string, 0, len(providers))
+	for name := range providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// New builds the fallback chain configured in cfg.Providers. Without explicit
+// providers, the hosted and local backends are used when configured, or the
+// mock backend when neither is, followed by the rule backend.
+func New(cfg *config.AiConfig) (Llm, error) {
+	names := cfg.Providers
+	if len(names) == 0 {
+		if cfg.APIKey != "" {
+			names = append(names, ProviderOpenAI)
+		}
+		if cfg.Local != nil && cfg.Local.Endpoint != "" {
+			names = append(names, ProviderLocal)
+		}
+		if len(names) == 0 {
+			names = append(names, ProviderMock)
+		}
+		names = append(names, ProviderRule)
+	}
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	chain := &Fallback{timeout: timeout}
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSpace(name))
+		factory, ok := providers[name]
+		if !ok {
+			return nil, fmt.Errorf("llm provider %s not registered", name)
+		}
+		backend, err := factory(cfg)
+		if err != nil {
+			return nil, errors.Wrapf(err, "llm provider %s", name)
+		}
+		chain.names = append(chain.names, name)
+		chain.backends = append(chain.backends, backend)
+	}
+	if len(chain.backends) == 0 {
+		return nil, errors.New("no llm provider configured")
+	}
+	log.Infof("llm providers: %s", strings.Join(chain.names, ","))
+	return chain, nil
+}
+
+// Fallback asks each backend in order until one answers within the timeout.
+type Fallback struct {
+	names    []string
+	backends []Llm
+	timeout  time.Duration
+}
+
+func (f *Fallback) Chat(ctx context.Context, messages []openai.ChatCompletionMessage, functions []openai.FunctionDefinition) ([]openai.ToolCall, error) {
+	var lastErr error
+	for i, backend := range f.backends {
+		callCtx, cancel := context.WithTimeout(ctx, f.timeout)
+		calls, err := backend.Chat(callCtx, messages, functions)
+		cancel()
+		if err == nil {
+			return calls, nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		log.Warnf("llm provider %s failed: %v", f.names[i], err)
+		lastErr = errors.Wrap(err, f.names[i])
+	}
+	return nil, errors.Wrap(lastErr, "all llm providers failed")
+}
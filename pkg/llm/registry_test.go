@@ -0,0 +1,131 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+	"github.com/sashabaranov/go-openai/jsonschema"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/smarterwallet/demand-abstraction-serv/config"
+)
+
+type stubLlm struct {
+	delay time.Duration
+	err   error
+	calls int
+}
+
+func (s *stubLlm) Chat(ctx context.Context, messages []openai.ChatCompletionMessage, functions []openai.FunctionDefinition) ([]openai.ToolCall, error) {
+	s.calls++
+	select {
+	case <-time.After(s.delay):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	if s.err != nil {
+		return nil, s.err
+	}
+	return []openai.ToolCall{NewToolCall(0, "stub", "{}")}, nil
+}
+
+// registerStub registers backend under name for the duration of the test.
+func registerStub(t *testing.T, name string, backend Llm) {
+	Register(name, func(cfg *config.AiConfig) (Llm, error) { return backend, nil })
+	t.Cleanup(func() {
+		providersMu.Lock()
+		defer providersMu.Unlock()
+		delete(providers, name)
+	})
+}
+
+func TestFallback(t *testing.T) {
+	broken := &stubLlm{err: errors.New("provider down")}
+	slow := &stubLlm{delay: time.Second}
+	healthy := &stubLlm{}
+	registerStub(t, "broken", broken)
+	registerStub(t, "slow", slow)
+	registerStub(t, "healthy", healthy)
+
+	t.Run("falls through errors and timeouts", func(t *testing.T) {
+		client, err := New(&config.AiConfig{Providers: []string{"broken", "slow", "healthy"}, Timeout: 50 * time.Millisecond})
+		assert.Nil(t, err)
+		calls, err := client.Chat(context.Background(), BuildMessages("", nil, "hi"), nil)
+		assert.Nil(t, err)
+		assert.Equal(t, "stub", calls[0].Function.Name)
+		assert.Equal(t, 1, broken.calls)
+		assert.Equal(t, 1, slow.calls)
+		assert.Equal(t, 1, healthy.calls)
+	})
+	t.Run("all failed", func(t *testing.T) {
+		client, err := New(&config.AiConfig{Providers: []string{"broken"}})
+		assert.Nil(t, err)
+		_, err = client.Chat(context.Background(), BuildMessages("", nil, "hi"), nil)
+		assert.NotNil(t, err)
+	})
+	t.Run("unknown provider", func(t *testing.T) {
+		_, err := New(&config.AiConfig{Providers: []string{"missing"}})
+		assert.NotNil(t, err)
+	})
+	t.Run("default without api key", func(t *testing.T) {
+		client, err := New(&config.AiConfig{})
+		assert.Nil(t, err)
+		assert.Equal(t, []string{ProviderMock, ProviderRule}, client.(*Fallback).names)
+	})
+}
+
+func TestRule(t *testing.T) {
+	rule := NewRule()
+	transferFn := []openai.FunctionDefinition{{
+		Name: "get_trade_strategy",
+		Parameters: jsonschema.Definition{
+			Type: jsonschema.Object,
+			Properties: map[string]jsonschema.Definition{
+				"token":        {Type: jsonschema.String},
+				"amount":       {Type: jsonschema.Number},
+				"receiver":     {Type: jsonschema.String},
+				"target_chain": {Type: jsonschema.String},
+				"is_usd":       {Type: jsonschema.Boolean},
+			},
+		},
+	}}
+	calls, err := rule.Chat(ctx, BuildMessages("", nil, "I want to transfer 120USDC to 0x5134F00C95b8e794db38E1eE39397d8086cee7Ed on target chain fuji"), transferFn)
+	assert.Nil(t, err)
+	assert.Len(t, calls, 1)
+	assert.Equal(t, "get_trade_strategy", calls[0].Function.Name)
+	in := map[string]interface{}{}
+	assert.Nil(t, json.Unmarshal([]byte(calls[0].Function.Arguments), &in))
+	assert.Equal(t, 120.0, in["amount"])
+	assert.Equal(t, "USDC", in["token"])
+	assert.Equal(t, "0x5134F00C95b8e794db38E1eE39397d8086cee7Ed", in["receiver"])
+	assert.Equal(t, "fuji", in["target_chain"])
+	assert.Equal(t, false, in["is_usd"])
+
+	t.Run("multi intent", func(t *testing.T) {
+		swapFn := openai.FunctionDefinition{
+			Name: "swap_token",
+			Parameters: jsonschema.Definition{
+				Type: jsonschema.Object,
+				Properties: map[string]jsonschema.Definition{
+					"source_token": {Type: jsonschema.String},
+					"target_token": {Type: jsonschema.String},
+					"amount_in":    {Type: jsonschema.Number},
+				},
+			},
+		}
+		calls, err := rule.Chat(ctx, BuildMessages("", nil, "swap 50 DAI to USDC and send 20 USDC to 0x5134F00C95b8e794db38E1eE39397d8086cee7Ed on fuji"), append(transferFn, swapFn))
+		assert.Nil(t, err)
+		assert.Len(t, calls, 2)
+		assert.Equal(t, "swap_token", calls[0].Function.Name)
+		assert.JSONEq(t, `{"source_token":"DAI","target_token":"USDC","amount_in":50}`, calls[0].Function.Arguments)
+		assert.Equal(t, "get_trade_strategy", calls[1].Function.Name)
+		in := map[string]interface{}{}
+		assert.Nil(t, json.Unmarshal([]byte(calls[1].Function.Arguments), &in))
+		assert.Equal(t, 20.0, in["amount"])
+		assert.Equal(t, "fuji", in["target_chain"])
+	})
+}
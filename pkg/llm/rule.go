@@ -0,0 +1,200 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/sashabaranov/go-openai"
+	"github.com/sashabaranov/go-openai/jsonschema"
+)
+
+var (
+	addressRegexp     = regexp.MustCompile(`0x[0-9a-fA-F]{40}`)
+	verbAmountRegexp  = regexp.MustCompile(`(?i)\b(?:transfer|send|pay|swap|bridge)\s+(\d+(?:\.\d+)?)`)
+	amountRegexp      = regexp.MustCompile(`(\d+(?:\.\d+)?)`)
+	tokenRegexp       = regexp.MustCompile(`\b(?:\d+(?:\.\d+)?)?([A-Z][A-Z0-9]{1,5}(?:\.e)?)\b`)
+	fromChainRegexp   = regexp.MustCompile(`(?i)\bfrom\s+(?:chain\s+)?([a-z]+)`)
+	targetChainRegexp = regexp.MustCompile(`(?i)\b(?:on|to)\s+(?:target\s+chain\s+)?([a-z]+)\s*$`)
+	usdRegexp         = regexp.MustCompile(`(?i)\b(dollars?|usd)\b`)
+	transferRegexp    = regexp.MustCompile(`(?i)\b(transfer|send|bridge|swap|pay)\b`)
+	intentJoinRegexp  = regexp.MustCompile(`(?i)[\s,;]*(?:\band\s+then|\bthen|\band)?[\s,;]*$`)
+	swapRegexp        = regexp.MustCompile(`(?i)\bswap\s+(?:(\d+(?:\.\d+)?)\s*)?([a-z][a-z0-9.]{1,7})\s+(?:to|for|into)\s+(?:(\d+(?:\.\d+)?)\s*)?([a-z][a-z0-9.]{1,7})`)
+	onChainRegexp     = regexp.MustCompile(`(?i)\bon\s+([a-z]+)`)
+	highRegexp        = regexp.MustCompile(`(?i)\bhigh\b`)
+	lowRegexp         = regexp.MustCompile(`(?i)\blow\b`)
+)
+
+// Rule is a deterministic backend that fills function arguments from regular
+// expressions over the latest user message. It keeps demand parsing available
+// when every hosted model is down.
+type Rule struct{}
+
+func NewRule() *Rule {
+	return &Rule{}
+}
+
+func (r *Rule) Chat(ctx context.Context, messages []openai.ChatCompletionMessage, functions []openai.FunctionDefinition) ([]openai.ToolCall, error) {
+	if len(functions) == 0 {
+		return nil, errors.New("no function to call")
+	}
+	demand := lastUserContent(messages)
+	if demand == "" {
+		return nil, errors.New("empty demand")
+	}
+	clauses := []string{demand}
+	if _, ok := functionParameters(functions[0]).Properties["strategy"]; !ok {
+		clauses = splitIntents(demand)
+	}
+	calls := make([]openai.ToolCall, 0, len(clauses))
+	for i, clause := range clauses {
+		function := ruleFunction(functions, clause)
+		args := make(map[string]interface{})
+		for name, prop := range functionParameters(function).Properties {
+			if v, ok := ruleValue(name, prop, clause); ok {
+				args[name] = v
+			}
+		}
+		buf, err := json.Marshal(args)
+		if err != nil {
+			return nil, err
+		}
+		calls = append(calls, NewToolCall(i, function.Name, string(buf)))
+	}
+	return calls, nil
+}
+
+func functionParameters(function openai.FunctionDefinition) jsonschema.Definition {
+	params, _ := function.Parameters.(jsonschema.Definition)
+	return params
+}
+
+// splitIntents cuts a demand into one clause per operation verb, e.g.
+// "swap 50 DAI to USDC and send 20 USDC to ..." yields two clauses.
+func splitIntents(demand string) []string {
+	verbs := transferRegexp.FindAllStringIndex(demand, -1)
+	if len(verbs) < 2 {
+		return []string{demand}
+	}
+	clauses := make([]string, 0, len(verbs))
+	for i, verb := range verbs {
+		end := len(demand)
+		if i+1 < len(verbs) {
+			end = verbs[i+1][0]
+		}
+		start := verb[0]
+		if i == 0 {
+			start = 0
+		}
+		clause := strings.TrimSpace(demand[start:end])
+		clause = strings.TrimSpace(intentJoinRegexp.ReplaceAllString(clause, ""))
+		clauses = append(clauses, clause)
+	}
+	return clauses
+}
+
+func ruleFunction(functions []openai.FunctionDefinition, clause string) openai.FunctionDefinition {
+	isSwap := swapRegexp.MatchString(clause)
+	for _, function := range functions {
+		if strings.Contains(function.Name, "swap") == isSwap {
+			return function
+		}
+	}
+	return functions[0]
+}
+
+func lastUserContent(messages []openai.ChatCompletionMessage) string {
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == openai.ChatMessageRoleUser {
+			return messages[i].Content
+		}
+	}
+	return ""
+}
+
+func ruleValue(name string, prop jsonschema.Definition, demand string) (interface{}, bool) {
+	switch name {
+	case "strategy":
+		if transferRegexp.MatchString(demand) || addressRegexp.MatchString(demand) {
+			return "transfer", true
+		}
+		return "trade2Earn", true
+	case "receiver":
+		if m := addressRegexp.FindString(demand); m != "" {
+			return m, true
+		}
+	case "token":
+		if m := tokenRegexp.FindStringSubmatch(addressRegexp.ReplaceAllString(demand, "")); m != nil {
+			return strings.ToUpper(m[1]), true
+		}
+		if usdRegexp.MatchString(demand) {
+			return "USDC", true
+		}
+	case "amount", "transfer_amount":
+		text := addressRegexp.ReplaceAllString(demand, "")
+		m := verbAmountRegexp.FindStringSubmatch(text)
+		if m == nil {
+			m = amountRegexp.FindStringSubmatch(text)
+		}
+		if m != nil {
+			if _, err := strconv.ParseFloat(m[1], 64); err == nil {
+				return json.Number(m[1]), true
+			}
+		}
+	case "source_chain":
+		if m := fromChainRegexp.FindStringSubmatch(demand); m != nil {
+			return strings.ToLower(m[1]), true
+		}
+	case "target_chain":
+		if m := targetChainRegexp.FindStringSubmatch(demand); m != nil {
+			return strings.ToLower(m[1]), true
+		}
+	case "source_token", "target_token", "amount_in", "amount_out":
+		m := swapRegexp.FindStringSubmatch(demand)
+		if m == nil {
+			break
+		}
+		v := map[string]string{"amount_in": m[1], "source_token": m[2], "amount_out": m[3], "target_token": m[4]}[name]
+		if v == "" {
+			break
+		}
+		if prop.Type == jsonschema.Number {
+			if _, err := strconv.ParseFloat(v, 64); err == nil {
+				return json.Number(v), true
+			}
+			break
+		}
+		return strings.ToUpper(v), true
+	case "chain":
+		if m := onChainRegexp.FindStringSubmatch(demand); m != nil {
+			return strings.ToLower(m[1]), true
+		}
+	case "is_usd":
+		return usdRegexp.MatchString(demand), true
+	case "minimum":
+		switch {
+		case highRegexp.MatchString(demand):
+			return "10%", true
+		case lowRegexp.MatchString(demand):
+			return "3%", true
+		}
+		return "6%", true
+	case "maximum":
+		switch {
+		case highRegexp.MatchString(demand):
+			return "20%", true
+		case lowRegexp.MatchString(demand):
+			return "6%", true
+		}
+		return "10%", true
+	case "summary":
+		return demand, true
+	}
+	if prop.Type == jsonschema.Boolean {
+		return false, true
+	}
+	return nil, false
+}
@@ -0,0 +1,281 @@
+package strategy
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+
+	"github.com/smarterwallet/demand-abstraction-serv/data"
+	"github.com/smarterwallet/demand-abstraction-serv/model"
+	"github.com/smarterwallet/demand-abstraction-serv/pkg"
+)
+
+// checkCross asks the cross chain service whether token bridges between two
+// chains, returning its config. Tests replace it.
+var checkCross = func(sourceChainId, targetChainId int, token string) (bool, []byte) {
+	if pkg.Base == nil {
+		return false, nil
+	}
+	return pkg.Base.CheckCross(sourceChainId, targetChainId, token)
+}
+
+// crossChainConfig reports whether token bridges from sourceChain to
+// targetChain and returns the config of the bridge.
+func crossChainConfig(ctx context.Context, chains *data.Snapshot, sourceChain, targetChain, token string) (bool, []byte, error) {
+	sourceChainId, err := chains.ChainID(sourceChain)
+	if err != nil {
+		return false, nil, err
+	}
+	targetChainId, err := chains.ChainID(targetChain)
+	if err != nil {
+		return false, nil, err
+	}
+	ok, ret := checkCross(sourceChainId, targetChainId, strings.ToUpper(token))
+	Progress(ctx, model.StageCrossChainChecked, model.CrossChainCheckedEvent{
+		SourceChain: sourceChain,
+		TargetChain: targetChain,
+		Token:       token,
+		Supported:   ok,
+	})
+	return ok, ret, nil
+}
+
+// ableToCrossChain reports whether token bridges from sourceChain to
+// targetChain with the chains of a fresh asset config load.
+func ableToCrossChain(sourceChain, targetChain, token string) (bool, error) {
+	if pkg.Base == nil {
+		return false, errors.New("base service not initialized")
+	}
+	res, err := pkg.Base.LoadTokens()
+	if err != nil {
+		return false, err
+	}
+	aliases, err := data.LoadChainAliases("")
+	if err != nil {
+		return false, err
+	}
+	b := data.NewSnapshotBuilder(aliases, nil)
+	b.AddAssetConfig(res)
+	ok, _, err := crossChainConfig(context.Background(), b.Build(), sourceChain, targetChain, token)
+	return ok, err
+}
+
+// fundingLeg is the part of a transfer paid from one chain. Amount is what
+// arrives on target, the bridge fees left out. Config is the bridge config,
+// nil when the chain is the target chain itself.
+type fundingLeg struct {
+	Chain  string
+	Amount decimal.Decimal
+	Config []byte
+}
+
+// gatherFunds splits amount of token over the balances of every chain so that
+// it reaches target with the fewest ops. It reports false when the balances
+// fall short.
+func (t transfer) gatherFunds(ctx context.Context, token, target string, amount decimal.Decimal) ([]fundingLeg, bool) {
+	if balance := t.balance.GetTokenBalance(target, token); balance.Cmp(amount) >= 0 {
+		return []fundingLeg{{Chain: target, Amount: amount}}, true
+	}
+	return selectLegs(t.fundingSources(ctx, token, target, false), target, amount)
+}
+
+// fundingSources returns the balance of token on target and what the balance
+// of every chain it bridges from to target delivers there after the bridge
+// fees, sorted by chain. Chains without token are left out unless empty is
+// set.
+func (t transfer) fundingSources(ctx context.Context, token, target string, empty bool) []fundingLeg {
+	chains := make([]string, 0, len(t.balance.Balances))
+	for chain := range t.balance.Balances {
+		chains = append(chains, chain)
+	}
+	if _, ok := t.balance.Balances[target]; !ok {
+		chains = append(chains, target)
+	}
+	sort.Strings(chains)
+	var sources []fundingLeg
+	for _, chain := range chains {
+		balance := t.balance.GetTokenBalance(chain, token)
+		if !balance.IsPositive() && !empty {
+			continue
+		}
+		leg := fundingLeg{Chain: chain, Amount: balance}
+		if chain != target {
+			ok, config, err := crossChainConfig(ctx, t.chains, chain, target, token)
+			if err != nil || !ok {
+				continue
+			}
+			leg.Config = config
+			leg.Amount = delivered(t.chains, chain, token, config, balance)
+		}
+		sources = append(sources, leg)
+	}
+	return sources
+}
+
+// selectLegs picks the sources covering amount with the fewest legs: the
+// largest of what they deliver, the one already on target preferred when it
+// takes no more legs. The target leg comes first and the last leg only takes
+// what is left.
+func selectLegs(sources []fundingLeg, target string, amount decimal.Decimal) ([]fundingLeg, bool) {
+	var (
+		positive []fundingLeg
+		total    decimal.Decimal
+	)
+	for _, leg := range sources {
+		if leg.Amount.IsPositive() {
+			positive = append(positive, leg)
+			total = total.Add(leg.Amount)
+		}
+	}
+	if total.Cmp(amount) < 0 {
+		return nil, false
+	}
+	sources = positive
+	sort.SliceStable(sources, func(i, j int) bool {
+		if c := sources[i].Amount.Cmp(sources[j].Amount); c != 0 {
+			return c > 0
+		}
+		return sources[i].Chain == target
+	})
+	// the largest balances take the fewest legs
+	n, sum := 0, decimal.Zero
+	for sum.Cmp(amount) < 0 {
+		sum = sum.Add(sources[n].Amount)
+		n++
+	}
+	chosen := append([]fundingLeg(nil), sources[:n]...)
+	for _, leg := range sources[n:] {
+		if leg.Chain == target && sum.Sub(chosen[n-1].Amount).Add(leg.Amount).Cmp(amount) >= 0 {
+			chosen[n-1] = leg
+		}
+	}
+	sort.SliceStable(chosen, func(i, j int) bool {
+		return chosen[i].Chain == target && chosen[j].Chain != target
+	})
+	legs := make([]fundingLeg, 0, len(chosen))
+	remaining := amount
+	for _, leg := range chosen {
+		if !remaining.IsPositive() {
+			break
+		}
+		leg.Amount = decimal.Min(leg.Amount, remaining)
+		remaining = remaining.Sub(leg.Amount)
+		legs = append(legs, leg)
+	}
+	return legs, true
+}
+
+// swapAndGather covers what the balances of every chain lack of token by
+// swapping into it on the chain where that costs least, target first on a tie,
+// and gathers the funds with the swapped amount. A swap on a chain bridging to
+// target also buys the bridge fees of the whole leg.
+func (t transfer) swapAndGather(ctx context.Context, token, target string, amount decimal.Decimal) (swapPlan, []fundingLeg, bool) {
+	sources := t.fundingSources(ctx, token, target, true)
+	sort.SliceStable(sources, func(i, j int) bool {
+		return sources[i].Chain == target && sources[j].Chain != target
+	})
+	shortfall := amount
+	for _, leg := range sources {
+		shortfall = shortfall.Sub(leg.Amount)
+	}
+	if !shortfall.IsPositive() {
+		legs, ok := selectLegs(sources, target, amount)
+		return swapPlan{}, legs, ok
+	}
+	var (
+		plan swapPlan
+		best = -1
+	)
+	for i, leg := range sources {
+		need := shortfall
+		if leg.Config != nil {
+			need = grossAmount(t.chains, leg.Chain, token, leg.Config, leg.Amount.Add(shortfall)).Sub(t.balance.GetTokenBalance(leg.Chain, token))
+		}
+		if p, ok := t.planSwaps(leg.Chain, token, need); ok && (best < 0 || p.better(plan)) {
+			plan, best = p, i
+		}
+	}
+	if best < 0 {
+		return swapPlan{}, nil, false
+	}
+	for _, swap := range plan.Swaps {
+		Progress(ctx, model.StageSwapQuoted, swap)
+	}
+	sources[best].Amount = sources[best].Amount.Add(shortfall)
+	legs, ok := selectLegs(sources, target, amount)
+	return plan, legs, ok
+}
+
+// fundedTransfer sends in.Amount to the receiver on target from the legs of
+// gatherFunds, after the swaps of plan. Bridges send their fees on top of the
+// leg when the balance of its chain allows, and warn otherwise.
+func (t transfer) fundedTransfer(in transferArgs, target string, plan swapPlan, legs []fundingLeg, resp *model.DemandResponse) error {
+	targetChainId, err := t.chains.ChainID(target)
+	if err != nil {
+		return err
+	}
+	var (
+		ops        []model.Op
+		swapped    []string
+		swappedOut decimal.Decimal
+		bridged    []string
+	)
+	for i := range plan.Swaps {
+		ops = append(ops, model.NewOp(&plan.Swaps[i]))
+		swapped = append(swapped, fmt.Sprintf("%s %s", plan.Swaps[i].SwapIn, plan.Swaps[i].SourceToken))
+		if out, err := decimal.NewFromString(plan.Swaps[i].SwapOut); err == nil {
+			swappedOut = swappedOut.Add(out)
+		}
+	}
+	for _, leg := range legs {
+		if leg.Config == nil {
+			ops = append(ops, model.NewOp(&model.CrossChainResponse{
+				Type:            model.ChainInternalTransfer,
+				SourceChainId:   targetChainId,
+				SourceChainName: target,
+				Token:           in.Token,
+				Amount:          leg.Amount.String(),
+				Receiver:        in.Receiver,
+				TargetChainName: target,
+				TargetChainId:   targetChainId,
+			}))
+			continue
+		}
+		sourceChainId, err := t.chains.ChainID(leg.Chain)
+		if err != nil {
+			return err
+		}
+		available := t.balance.GetTokenBalance(leg.Chain, in.Token)
+		if leg.Chain == plan.Chain {
+			available = available.Add(swappedOut)
+		}
+		amount, warning := bridgeAmount(t.chains, leg.Chain, in.Token, leg.Config, leg.Amount, available)
+		ops = append(ops, model.NewOp(&model.CrossChainResponse{
+			RawResponse:     leg.Config,
+			Type:            model.CrossChainTransfer,
+			SourceChainId:   sourceChainId,
+			SourceChainName: leg.Chain,
+			Token:           in.Token,
+			Amount:          amount.String(),
+			Receiver:        in.Receiver,
+			TargetChainId:   targetChainId,
+			TargetChainName: target,
+			Warning:         warning,
+		}))
+		bridged = append(bridged, fmt.Sprintf("%s from %s", amount.String(), leg.Chain))
+	}
+	reply := fmt.Sprintf("Ok I will transfer %s %s to %s on %s", in.Amount.String(), in.Token, in.Receiver, target)
+	if len(swapped) > 0 {
+		reply += fmt.Sprintf(", swapping %s into %s on %s", strings.Join(swapped, " and "), in.Token, plan.Chain)
+	}
+	if len(bridged) > 0 {
+		reply += fmt.Sprintf(", bridging %s %s", strings.Join(bridged, ", "), in.Token)
+	}
+	reply += opWarnings(ops)
+	resp.Detail = model.DetailResp{Reply: reply, OPs: ops}
+	return nil
+}
@@ -0,0 +1,147 @@
+package strategy
+
+import (
+	"context"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/smarterwallet/demand-abstraction-serv/data"
+	"github.com/smarterwallet/demand-abstraction-serv/model"
+)
+
+func usdc(balance string) []model.Reserve {
+	return []model.Reserve{{Symbol: "USDC", Balance: decimal.RequireFromString(balance)}}
+}
+
+func TestGatherFunds(t *testing.T) {
+	aliases, err := data.LoadChainAliases("")
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+	b := data.NewSnapshotBuilder(aliases, nil)
+	b.AddChain("mumbai", 80001, 80001, "MATIC")
+	b.AddChain("fuji", 43113, 43113, "AVAX")
+	b.AddChain("goerli", 5, 5, "ETH")
+	for _, chain := range []string{"mumbai", "fuji", "goerli"} {
+		b.AddToken(chain, data.Token{Symbol: "USDC", Address: "0x" + chain, Decimal: 6})
+	}
+	chains := data.NewRegistry().Publish(b)
+
+	defer func(check func(int, int, string) (bool, []byte)) { checkCross = check }(checkCross)
+	checkCross = func(sourceChainId, targetChainId int, token string) (bool, []byte) {
+		return sourceChainId != 5, []byte(`{"code":200}`)
+	}
+	legs := func(balances map[string][]model.Reserve, amount string) map[string]string {
+		st := transfer{balance: &model.CtxRequest{BaseChain: "mumbai", Balances: balances}, chains: chains}
+		gathered, ok := st.gatherFunds(context.Background(), "USDC", "fuji", decimal.RequireFromString(amount))
+		if !ok {
+			return nil
+		}
+		m := make(map[string]string)
+		for _, leg := range gathered {
+			m[leg.Chain] = leg.Amount.String()
+		}
+		return m
+	}
+
+	balances := map[string][]model.Reserve{"mumbai": usdc("4"), "fuji": usdc("5"), "goerli": usdc("30")}
+	assert.Equal(t, map[string]string{"fuji": "4"}, legs(balances, "4"))
+	assert.Equal(t, map[string]string{"fuji": "5", "mumbai": "3"}, legs(balances, "8"))
+	// goerli doesn't bridge to fuji
+	assert.Nil(t, legs(balances, "10"))
+
+	// one bridge beats a transfer and a bridge
+	balances = map[string][]model.Reserve{"mumbai": usdc("20"), "fuji": usdc("2")}
+	assert.Equal(t, map[string]string{"mumbai": "10"}, legs(balances, "10"))
+	// the target balance replaces the smallest bridge when it covers as much
+	balances = map[string][]model.Reserve{"mumbai": usdc("6"), "fuji": usdc("5"), "goerli": usdc("0")}
+	assert.Equal(t, map[string]string{"fuji": "5", "mumbai": "5"}, legs(balances, "10"))
+
+	// 5.3 usdc bridged from mumbai deliver 4.7841 after the fees
+	checkCross = func(sourceChainId, targetChainId int, token string) (bool, []byte) {
+		return sourceChainId != 5, feeConfig
+	}
+	balances = map[string][]model.Reserve{"mumbai": usdc("5.3"), "fuji": usdc("5")}
+	assert.Nil(t, legs(balances, "10"))
+	assert.Equal(t, map[string]string{"fuji": "5", "mumbai": "4.5"}, legs(balances, "9.5"))
+
+	checkCross = func(sourceChainId, targetChainId int, token string) (bool, []byte) {
+		return true, []byte(`{"code":200}`)
+	}
+	balances = map[string][]model.Reserve{"mumbai": usdc("4"), "fuji": usdc("3"), "goerli": usdc("5")}
+	assert.Equal(t, map[string]string{"fuji": "3", "goerli": "5", "mumbai": "2"}, legs(balances, "10"))
+
+	st := transfer{balance: &model.CtxRequest{BaseChain: "mumbai", Balances: balances}, chains: chains}
+	resp := &model.DemandResponse{}
+	err = st.Render(context.Background(), resp, "get_trade_strategy",
+		`{"source_chain":"mumbai","token":"USDC","amount":10,"receiver":"0xd8da6bf26964af9d7eed9e03e53415d37aa96045","target_chain":"fuji"}`)
+	assert.Nil(t, err)
+	if assert.Len(t, resp.Detail.OPs, 3) {
+		assert.Equal(t, model.ChainInternalTransfer, resp.Detail.OPs[0].Body.(*model.CrossChainResponse).Type)
+		cross := resp.Detail.OPs[1].Body.(*model.CrossChainResponse)
+		assert.Equal(t, model.CrossChainTransfer, cross.Type)
+		assert.Equal(t, "goerli", cross.SourceChainName)
+		assert.Equal(t, "0xd8dA6BF26964aF9D7eEd9e03E53415D37aA96045", cross.Receiver)
+	}
+	assert.Contains(t, resp.Detail.Reply, "bridging 5 from goerli, 2 from mumbai USDC")
+
+	// the 5 usdc missing are swapped from dai on mumbai, then bridged
+	defer func(check func(model.SwapReq) (string, []byte, error)) { checkSwap = check }(checkSwap)
+	var calls int32
+	checkSwap = stubSwaps(&calls)
+	balances = map[string][]model.Reserve{
+		"mumbai": {{Symbol: "USDC", Balance: decimal.NewFromInt(2)}, {Symbol: "DAI", Address: "0xdai", Balance: decimal.NewFromInt(50)}},
+		"fuji":   usdc("3"),
+	}
+	st = transfer{balance: &model.CtxRequest{BaseChain: "mumbai", Balances: balances}, chains: chains}
+	resp = &model.DemandResponse{}
+	err = st.Render(context.Background(), resp, "get_trade_strategy",
+		`{"source_chain":"mumbai","token":"USDC","amount":10,"receiver":"0xd8da6bf26964af9d7eed9e03e53415d37aa96045","target_chain":"fuji"}`)
+	assert.Nil(t, err)
+	if assert.Len(t, resp.Detail.OPs, 3) {
+		swap := resp.Detail.OPs[0].Body.(*model.SwapResponse)
+		assert.Equal(t, []string{"mumbai", "DAI", "5.05", "5"}, []string{swap.ChainName, swap.SourceToken, swap.SwapIn, swap.SwapOut})
+		assert.Equal(t, "3", resp.Detail.OPs[1].Body.(*model.CrossChainResponse).Amount)
+		assert.Equal(t, "7", resp.Detail.OPs[2].Body.(*model.CrossChainResponse).Amount)
+	}
+	assert.Contains(t, resp.Detail.Reply, "swapping 5.05 DAI into USDC on mumbai, bridging 7 from mumbai USDC")
+
+	// bridges send their fees on top of the leg
+	checkCross = func(sourceChainId, targetChainId int, token string) (bool, []byte) {
+		return true, feeConfig
+	}
+	balances = map[string][]model.Reserve{"mumbai": usdc("20"), "fuji": usdc("3")}
+	st = transfer{balance: &model.CtxRequest{BaseChain: "mumbai", Balances: balances}, chains: chains}
+	resp = &model.DemandResponse{}
+	err = st.Render(context.Background(), resp, "get_trade_strategy",
+		`{"source_chain":"mumbai","token":"USDC","amount":10,"receiver":"0xd8da6bf26964af9d7eed9e03e53415d37aa96045","target_chain":"fuji"}`)
+	assert.Nil(t, err)
+	if assert.Len(t, resp.Detail.OPs, 1) {
+		assert.Equal(t, "10.531595", resp.Detail.OPs[0].Body.(*model.CrossChainResponse).Amount)
+	}
+	assert.Contains(t, resp.Detail.Reply, "bridging 10.531595 from mumbai USDC")
+
+	// the 2 usdc of mumbai deliver 1.494, the swap buys the 5.506 missing and
+	// the fees of bridging 7
+	balances = map[string][]model.Reserve{
+		"mumbai": {{Symbol: "USDC", Balance: decimal.NewFromInt(2)}, {Symbol: "DAI", Address: "0xdai", Balance: decimal.NewFromInt(50)}},
+		"fuji":   usdc("3"),
+	}
+	st = transfer{balance: &model.CtxRequest{BaseChain: "mumbai", Balances: balances}, chains: chains}
+	resp = &model.DemandResponse{}
+	err = st.Render(context.Background(), resp, "get_trade_strategy",
+		`{"source_chain":"mumbai","token":"USDC","amount":10,"receiver":"0xd8da6bf26964af9d7eed9e03e53415d37aa96045","target_chain":"fuji"}`)
+	assert.Nil(t, err)
+	if assert.Len(t, resp.Detail.OPs, 3) {
+		withFees(chains, resp.Detail.OPs)
+		swap := resp.Detail.OPs[0].Body.(*model.SwapResponse)
+		assert.Equal(t, []string{"mumbai", "DAI", "5.522568"}, []string{swap.ChainName, swap.SourceToken, swap.SwapOut})
+		assert.Equal(t, "3", resp.Detail.OPs[1].Body.(*model.CrossChainResponse).Amount)
+		cross := resp.Detail.OPs[2].Body.(*model.CrossChainResponse)
+		assert.Equal(t, []string{"7.522568", "7"}, []string{cross.Amount, cross.Received})
+		assert.Empty(t, cross.Warning)
+	}
+	assert.NotContains(t, resp.Detail.Reply, "Warning")
+}
@@ -0,0 +1,72 @@
+package strategy
+
+import (
+	log "github.com/cihub/seelog"
+	"github.com/shopspring/decimal"
+
+	"github.com/smarterwallet/demand-abstraction-serv/data"
+	"github.com/smarterwallet/demand-abstraction-serv/model"
+	"github.com/smarterwallet/demand-abstraction-serv/pkg/userop"
+	"github.com/smarterwallet/demand-abstraction-serv/utils"
+)
+
+// rawAmount converts a token amount on chain to base units using the loaded
+// token decimals. It is empty when the decimals of token are unknown.
+func rawAmount(chains *data.Snapshot, chain, token, amount string) string {
+	decimals, err := chains.TokenDecimal(chain, token)
+	if err != nil {
+		return ""
+	}
+	d, err := decimal.NewFromString(amount)
+	if err != nil {
+		return ""
+	}
+	return utils.ToBaseUnits(d, decimals).String()
+}
+
+// defaultPrecision is used for estimated amounts of tokens whose decimals
+// aren't loaded.
+const defaultPrecision = 6
+
+// tokenPrecision returns the number of decimals token on chain supports.
+func tokenPrecision(chains *data.Snapshot, chain, token string) int32 {
+	decimals, err := chains.TokenDecimal(chain, token)
+	if err != nil {
+		return defaultPrecision
+	}
+	return int32(decimals)
+}
+
+// withRawAmounts fills the base unit amounts of the transfer and swap ops.
+func withRawAmounts(chains *data.Snapshot, ops []model.Op) []model.Op {
+	for _, op := range ops {
+		switch o := op.Body.(type) {
+		case *model.CrossChainResponse:
+			o.AmountRaw = rawAmount(chains, o.SourceChainName, o.Token, o.Amount)
+		case *model.SwapResponse:
+			o.SwapInRaw = rawAmount(chains, o.ChainName, o.SourceToken, o.SwapIn)
+			o.SwapOutRaw = rawAmount(chains, o.ChainName, o.TargetToken, o.SwapOut)
+		}
+	}
+	return ops
+}
+
+// withCalldata attaches the calls of the account sender executing each op.
+// Ops that cannot be encoded offline, such as bridges without a known protocol
+// or swaps of an unknown sender, have none.
+func withCalldata(chains *data.Snapshot, sender string, ops []model.Op) []model.Op {
+	for _, op := range ops {
+		calls, err := userop.Calls(chains, op, sender)
+		if err != nil {
+			log.Debugf("no calldata for %s op: %v", op.Kind(), err)
+			calls = nil
+		}
+		switch o := op.Body.(type) {
+		case *model.CrossChainResponse:
+			o.Calldata = calls
+		case *model.SwapResponse:
+			o.Calldata = calls
+		}
+	}
+	return ops
+}
@@ -0,0 +1,120 @@
+package strategy
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/smarterwallet/demand-abstraction-serv/data"
+)
+
+// DefaultIntentThreshold is the confidence from which Classify decides the
+// strategy without asking the llm.
+const DefaultIntentThreshold = 0.7
+
+// intentSaturation is the score from which a category alone is fully trusted.
+const intentSaturation = 3.0
+
+// IntentRule adds Weight to the score of a strategy for demands matching
+// Pattern.
+type IntentRule struct {
+	Weight  float64
+	Pattern *regexp.Regexp
+}
+
+// IntentRules let Classify pick a strategy without the llm. ChainWeight is
+// added when a demand names a known chain and RouteWeight when it moves
+// from, to or on one.
+type IntentRules struct {
+	Rules       []IntentRule
+	ChainWeight float64
+	RouteWeight float64
+}
+
+// Classification is the local guess of the strategy a demand needs.
+type Classification struct {
+	Category   string
+	Confidence float64
+	Scores     map[string]float64
+}
+
+// Classify scores a demand with the intent rules of the registered strategies
+// and the chains of the snapshot. The confidence grows with the share of the
+// winning category and with its own score, so a single weak hint or a tie
+// never reaches DefaultIntentThreshold.
+func Classify(chains *data.Snapshot, demand string) Classification {
+	var (
+		scores    = make(map[string]float64)
+		words     = demandWords(demand)
+		mentioned = mentionsChain(chains, words)
+		routed    = routesChain(chains, words)
+		c         = Classification{Scores: scores}
+		total     float64
+	)
+	for _, def := range Registered() {
+		var score float64
+		for _, rule := range def.Intent.Rules {
+			if rule.Pattern.MatchString(demand) {
+				score += rule.Weight
+			}
+		}
+		if mentioned {
+			score += def.Intent.ChainWeight
+		}
+		if routed {
+			score += def.Intent.RouteWeight
+		}
+		if score == 0 {
+			continue
+		}
+		scores[def.Name] = score
+		total += score
+		if score > scores[c.Category] {
+			c.Category = def.Name
+		}
+	}
+	if total == 0 {
+		return c
+	}
+	top := scores[c.Category]
+	c.Confidence = top / total
+	if top < intentSaturation {
+		c.Confidence *= top / intentSaturation
+	}
+	return c
+}
+
+func demandWords(demand string) []string {
+	words := strings.Fields(strings.ToLower(demand))
+	for i, word := range words {
+		words[i] = strings.Trim(word, ".,;!?")
+	}
+	return words
+}
+
+func mentionsChain(chains *data.Snapshot, words []string) bool {
+	for _, word := range words {
+		if chains.KnownChain(word) {
+			return true
+		}
+	}
+	return false
+}
+
+// routesChain reports whether words move from, to or on a known chain, named
+// in one or two words.
+func routesChain(chains *data.Snapshot, words []string) bool {
+	for i, word := range words {
+		if word != "from" && word != "to" && word != "on" {
+			continue
+		}
+		j := i + 1
+		if j < len(words) && words[j] == "chain" {
+			j++
+		}
+		if j < len(words) && chains.KnownChain(words[j]) ||
+			j+1 < len(words) && chains.KnownChain(words[j]+" "+words[j+1]) {
+			return true
+		}
+	}
+	return false
+}
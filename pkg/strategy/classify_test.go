@@ -0,0 +1,40 @@
+package strategy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/smarterwallet/demand-abstraction-serv/data"
+)
+
+func TestClassify(t *testing.T) {
+	cases := []struct {
+		demand    string
+		category  string
+		confident bool
+	}{
+		{"transfer 10 USDC to 0xd8da6bf26964af9d7eed9e03e53415d37aa96045", "transfer", true},
+		{"bridge my USDC from mumbai to fuji", "transfer", true},
+		{"I want a high return investment with low risk", "trade2Earn", true},
+		{"expect 10% yield", "trade2Earn", true},
+		{"hello there", "", false},
+		{"low risk swap", "transfer", false},
+	}
+	chains := testChains(t)
+	for _, c := range cases {
+		t.Run(c.demand, func(t *testing.T) {
+			got := Classify(chains, c.demand)
+			assert.Equal(t, c.category, got.Category)
+			assert.Equal(t, c.confident, got.Confidence >= DefaultIntentThreshold, "confidence %.2f", got.Confidence)
+		})
+	}
+
+	// chains count once the snapshot knows them
+	assert.Less(t, Classify(chains, "10 USDC to scroll").Confidence, DefaultIntentThreshold)
+	b := data.NewSnapshotBuilder(nil, nil)
+	b.AddChain("scroll", 534352, 534352, "ETH")
+	got := Classify(b.Build(), "10 USDC to scroll")
+	assert.Equal(t, "transfer", got.Category)
+	assert.GreaterOrEqual(t, got.Confidence, DefaultIntentThreshold)
+}
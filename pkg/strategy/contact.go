@@ -0,0 +1,120 @@
+package strategy
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/sashabaranov/go-openai"
+
+	"github.com/smarterwallet/demand-abstraction-serv/model"
+	"github.com/smarterwallet/demand-abstraction-serv/utils"
+)
+
+// AddressBook is the contacts of one wallet.
+type AddressBook []model.Contact
+
+// Lookup finds the contact called name: the one named exactly so ignoring case,
+// or else the closest one within a few typos or starting with name. When
+// several are equally close they are returned as candidates instead.
+func (b AddressBook) Lookup(name string) (*model.Contact, []model.Contact) {
+	name = strings.TrimSpace(name)
+	for i := range b {
+		if strings.EqualFold(b[i].Name, name) {
+			return &b[i], nil
+		}
+	}
+	type match struct {
+		contact  model.Contact
+		distance int
+	}
+	var matches []match
+	for _, contact := range b {
+		distance := utils.EditDistance(contact.Name, name)
+		if len(name) >= 3 && strings.HasPrefix(strings.ToLower(contact.Name), strings.ToLower(name)) {
+			distance = 1
+		}
+		if distance <= utils.MaxTypos(len(name)) {
+			matches = append(matches, match{contact: contact, distance: distance})
+		}
+	}
+	if len(matches) == 0 {
+		return nil, nil
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].distance < matches[j].distance })
+	if len(matches) == 1 || matches[0].distance < matches[1].distance {
+		return &matches[0].contact, nil
+	}
+	var candidates []model.Contact
+	for _, m := range matches {
+		if m.distance == matches[0].distance {
+			candidates = append(candidates, m.contact)
+		}
+	}
+	return nil, candidates
+}
+
+// Resolution is a receiver the user named instead of giving its address.
+// Contact is nil when the name matched no contact or several Candidates.
+type Resolution struct {
+	Name       string
+	Contact    *model.Contact
+	Candidates []model.Contact
+}
+
+// Note tells the user which contact a name was resolved to, or why not.
+func (r Resolution) Note() string {
+	if r.Contact != nil {
+		return fmt.Sprintf("%s is your contact %s (%s).", r.Name, r.Contact.Name, r.Contact.Address)
+	}
+	if len(r.Candidates) > 0 {
+		names := make([]string, 0, len(r.Candidates))
+		for _, c := range r.Candidates {
+			names = append(names, fmt.Sprintf("%s (%s)", c.Name, c.Address))
+		}
+		return fmt.Sprintf("%s matches several contacts: %s.", r.Name, strings.Join(names, ", "))
+	}
+	return fmt.Sprintf("I couldn't find %s in your contacts.", r.Name)
+}
+
+// ResolveContacts replaces the receivers of calls given by name with the
+// address of the matching contact in book. Receivers are the address arguments
+// st traces; names without a single match are cleared so the user is asked for
+// the receiver again.
+func ResolveContacts(st IStrategy, calls []openai.ToolCall, book AddressBook) ([]openai.ToolCall, []Resolution) {
+	tracer, ok := st.(Tracer)
+	if !ok {
+		return calls, nil
+	}
+	resolved := make([]openai.ToolCall, 0, len(calls))
+	var resolutions []Resolution
+	for _, call := range calls {
+		args, err := DecodeArgs(call.Function.Arguments)
+		if err != nil {
+			resolved = append(resolved, call)
+			continue
+		}
+		changed := false
+		for arg, kind := range tracer.TracedArgs(call.Function.Name, args) {
+			name, _ := args[arg].(string)
+			name = strings.TrimSpace(name)
+			if kind != TraceAddress || name == "" || strings.HasPrefix(strings.ToLower(name), "0x") {
+				continue
+			}
+			contact, candidates := book.Lookup(name)
+			resolutions = append(resolutions, Resolution{Name: name, Contact: contact, Candidates: candidates})
+			args[arg] = ""
+			if contact != nil {
+				args[arg] = contact.Address
+			}
+			changed = true
+		}
+		if changed {
+			buf, _ := json.Marshal(args)
+			call.Function.Arguments = string(buf)
+		}
+		resolved = append(resolved, call)
+	}
+	return resolved, resolutions
+}
@@ -0,0 +1,70 @@
+package strategy
+
+import (
+	"testing"
+
+	"github.com/sashabaranov/go-openai"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/smarterwallet/demand-abstraction-serv/model"
+)
+
+func TestResolveContacts(t *testing.T) {
+	book := AddressBook{
+		{Name: "Alice", Address: "0xd8dA6BF26964aF9D7eEd9e03E53415D37aA96045"},
+		{Name: "Bob", Address: "0x5134F00C95b8e794db38E1eE39397d8086cee7Ed"},
+		{Name: "Rob", Address: "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed"},
+		{Name: "Mom's wallet", Address: "0xfB6916095ca1df60bB79Ce92cE3Ea74c37c5d359"},
+	}
+	cases := []struct {
+		name       string
+		contact    string
+		candidates []string
+	}{
+		{"alice", "Alice", nil},
+		{"Alise", "Alice", nil},
+		{"mom", "Mom's wallet", nil},
+		{"Bob", "Bob", nil},
+		{"Bop", "Bob", nil},
+		{"Cob", "", []string{"Bob", "Rob"}},
+		{"Carol", "", nil},
+		{"Al", "", nil},
+	}
+	for _, c := range cases {
+		contact, candidates := book.Lookup(c.name)
+		if c.contact == "" {
+			assert.Nil(t, contact, c.name)
+		} else if assert.NotNil(t, contact, c.name) {
+			assert.Equal(t, c.contact, contact.Name, c.name)
+		}
+		names := make([]string, 0, len(candidates))
+		for _, candidate := range candidates {
+			names = append(names, candidate.Name)
+		}
+		if c.candidates == nil {
+			assert.Empty(t, names, c.name)
+		} else {
+			assert.Equal(t, c.candidates, names, c.name)
+		}
+	}
+
+	st := transfer{balance: &model.CtxRequest{BaseChain: "mumbai"}}
+	calls, resolutions := ResolveContacts(st, []openai.ToolCall{
+		{Function: openai.FunctionCall{Name: "get_trade_strategy", Arguments: `{"amount":20,"token":"USDC","receiver":"alice"}`}},
+		{Function: openai.FunctionCall{Name: "get_trade_strategy", Arguments: `{"amount":1,"token":"USDC","receiver":"Carol"}`}},
+		{Function: openai.FunctionCall{Name: "get_trade_strategy", Arguments: `{"amount":1,"receiver":"0xd8da6bf26964af9d7eed9e03e53415d37aa96045"}`}},
+		{Function: openai.FunctionCall{Name: "swap_token", Arguments: `{"source_token":"DAI","target_token":"USDC"}`}},
+	}, book)
+	assert.Equal(t, `{"amount":20,"receiver":"0xd8dA6BF26964aF9D7eEd9e03E53415D37aA96045","token":"USDC"}`, calls[0].Function.Arguments)
+	assert.Equal(t, `{"amount":1,"receiver":"","token":"USDC"}`, calls[1].Function.Arguments)
+	assert.Equal(t, `{"amount":1,"receiver":"0xd8da6bf26964af9d7eed9e03e53415d37aa96045"}`, calls[2].Function.Arguments)
+	if assert.Len(t, resolutions, 2) {
+		assert.Equal(t, "alice is your contact Alice (0xd8dA6BF26964aF9D7eEd9e03E53415D37aA96045).", resolutions[0].Note())
+		assert.Equal(t, "I couldn't find Carol in your contacts.", resolutions[1].Note())
+	}
+	assert.Equal(t, "Cob matches several contacts: Bob (0x5134F00C95b8e794db38E1eE39397d8086cee7Ed), Rob (0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed).",
+		Resolution{Name: "Cob", Candidates: book[1:3]}.Note())
+
+	_, resolutions = ResolveContacts(trade2Earn{}, []openai.ToolCall{{Function: openai.FunctionCall{Name: "get_trade_to_earn_strategy", Arguments: `{"summary":"alice"}`}}}, book)
+	assert.Empty(t, resolutions)
+}
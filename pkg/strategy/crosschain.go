@@ -1,32 +1,50 @@
 package strategy
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"strings"
 
 	"github.com/shopspring/decimal"
 
 	"github.com/smarterwallet/demand-abstraction-serv/data"
 
-	"github.com/smarterwallet/demand-abstraction-serv/pkg"
-
 	log "github.com/cihub/seelog"
 	"github.com/sashabaranov/go-openai"
 	"github.com/sashabaranov/go-openai/jsonschema"
 	"github.com/smarterwallet/demand-abstraction-serv/model"
-	"github.com/smarterwallet/demand-abstraction-serv/utils"
 )
 
-type crossChain struct{}
+func init() {
+	Register(Definition{
+		Name:    "crossChain",
+		Factory: func(ctx *model.CtxRequest, chains *data.Snapshot) IStrategy { return crossChain{chains: chains} },
+	})
+	Register(Definition{
+		Name:    "crossChainAbstraction",
+		Factory: func(ctx *model.CtxRequest, chains *data.Snapshot) IStrategy { return chainAbstraction{chains: chains} },
+	})
+}
+
+type crossChain struct {
+	chains *data.Snapshot
+}
+
+var crossChainSlots = []Slot{
+	{Name: "token", Question: "Which token would you like to transfer?"},
+	{Name: "source_chain", Question: "Which chain should the tokens be sent from?"},
+	{Name: "receiver", Question: "Which address should receive the transfer?"},
+	{Name: "target_chain", Question: "Which chain should the tokens arrive on?"},
+	{Name: "amount", Question: "How much would you like to transfer?"},
+}
 
 type crossChainArgs struct {
-	SourceChain string  `json:"source_chain"`
-	Token       string  `json:"token"`
-	Amount      float64 `json:"amount"`
-	Receiver    string  `json:"receiver"`
-	TargetChain string  `json:"target_chain"`
-	Summary     string  `json:"summary"`
+	SourceChain string          `json:"source_chain"`
+	Token       string          `json:"token"`
+	Amount      decimal.Decimal `json:"amount"`
+	Receiver    string          `json:"receiver"`
+	TargetChain string          `json:"target_chain"`
+	Summary     string          `json:"summary"`
 }
 
 func (c crossChain) Prompt() string {
@@ -54,7 +72,7 @@ func (c crossChain) Functions() []openai.FunctionDefinition {
 				},
 				"receiver": {
 					Type:        jsonschema.String,
-					Description: "The receiver blockchain address, e.g. 0xd8da6bf26964af9d7eed9e03e53415d37aa96045",
+					Description: "The receiver blockchain address, or the contact name the user gave, e.g. 0xd8da6bf26964af9d7eed9e03e53415d37aa96045 or Alice",
 				},
 				"target_chain": {
 					Type:        jsonschema.String,
@@ -70,7 +88,33 @@ func (c crossChain) Functions() []openai.FunctionDefinition {
 	}}
 }
 
-func (c crossChain) Render(resp *model.DemandResponse, name, args string) error {
+func (c crossChain) MissingSlot(name string, args map[string]interface{}) (Slot, bool) {
+	if name != "cross_chain_analyze" {
+		return Slot{}, false
+	}
+	if slot, ok := firstMissing(crossChainSlots, args); ok {
+		return slot, true
+	}
+	if slot, ok := ambiguousToken(c.chains, args, chainArg(c.chains, args, "", "source_chain"), "token"); ok {
+		return slot, true
+	}
+	return invalidReceiver(c.chains, args, "target_chain")
+}
+
+func (c crossChain) CheckArgs(name string, args map[string]interface{}) []string {
+	problems := checkAmounts(args, "amount")
+	problems = append(problems, checkAddresses(args, "receiver")...)
+	return append(problems, checkChains(c.chains, args, "source_chain", "target_chain")...)
+}
+
+func (c crossChain) TracedArgs(name string, args map[string]interface{}) map[string]string {
+	if name != "cross_chain_analyze" {
+		return nil
+	}
+	return map[string]string{"amount": TraceNumber, "token": TraceSymbol, "receiver": TraceAddress}
+}
+
+func (c crossChain) Render(ctx context.Context, resp *model.DemandResponse, name, args string) error {
 	if name == "cross_chain_analyze" {
 		in := crossChainArgs{}
 		if err := json.Unmarshal([]byte(args), &in); err != nil {
@@ -78,27 +122,35 @@ func (c crossChain) Render(resp *model.DemandResponse, name, args string) error
 		}
 		resp.Summary = in.Summary
 		resp.Category = "crossChain"
-		sourceChainId, err := data.GetChainIdByName(in.SourceChain)
+		in.SourceChain = canonicalChain(c.chains, in.SourceChain)
+		in.TargetChain = canonicalChain(c.chains, in.TargetChain)
+		if !resolveToken(c.chains, resp, in.SourceChain, &in.Token) {
+			return nil
+		}
+		if !normalizeReceiver(c.chains, resp, in.TargetChain, &in.Receiver) {
+			return nil
+		}
+		sourceChainId, err := c.chains.ChainID(in.SourceChain)
 		if err != nil {
 			return err
 		}
-		targetChainId, err := data.GetChainIdByName(in.TargetChain)
+		targetChainId, err := c.chains.ChainID(in.TargetChain)
 		if err != nil {
 			return err
 		}
 		resp.Detail = model.DetailResp{
-			Reply: fmt.Sprintf("Ok I will transfer %s %s to %s from %s to %s", utils.Float2String(in.Amount), in.Token, in.Receiver, in.SourceChain, in.TargetChain),
-			OPs: []interface{}{
-				model.CrossChainResponse{
+			Reply: fmt.Sprintf("Ok I will transfer %s %s to %s from %s to %s", in.Amount.String(), in.Token, in.Receiver, in.SourceChain, in.TargetChain),
+			OPs: []model.Op{
+				model.NewOp(&model.CrossChainResponse{
 					Type:            model.CrossChainTransfer,
 					SourceChainId:   sourceChainId,
 					SourceChainName: in.SourceChain,
 					Token:           in.Token,
-					Amount:          utils.Float2String(in.Amount),
+					Amount:          in.Amount.String(),
 					Receiver:        in.Receiver,
 					TargetChainId:   targetChainId,
 					TargetChainName: in.TargetChain,
-				},
+				}),
 			},
 		}
 		return nil
@@ -107,20 +159,47 @@ func (c crossChain) Render(resp *model.DemandResponse, name, args string) error
 }
 
 type crossChainAbstractionArgs struct {
-	Token                          string  `json:"token"`
-	SourceChain                    string  `json:"source_chain"`
-	SourceChainTokenBalance        float64 `json:"source_chain_token_balance"`
-	SourceChainTokenBalanceDecimal decimal.Decimal
-	TargetChain                    string  `json:"target_chain"`
-	TargetChainTokenBalance        float64 `json:"target_chain_token_balance"`
-	TargetChainTokenBalanceDecimal decimal.Decimal
-	TransferAmount                 float64 `json:"transfer_amount"`
-	TransferAmountDecimal          decimal.Decimal
-	Receiver                       string `json:"receiver"`
-	Summary                        string `json:"summary"`
+	Token                   string          `json:"token"`
+	SourceChain             string          `json:"source_chain"`
+	SourceChainTokenBalance decimal.Decimal `json:"source_chain_token_balance"`
+	TargetChain             string          `json:"target_chain"`
+	TargetChainTokenBalance decimal.Decimal `json:"target_chain_token_balance"`
+	TransferAmount          decimal.Decimal `json:"transfer_amount"`
+	Receiver                string          `json:"receiver"`
+	Summary                 string          `json:"summary"`
+}
+
+type chainAbstraction struct {
+	chains *data.Snapshot
 }
 
-type chainAbstraction struct{}
+// chainAbstractionSlots follow the order crossChainAbstractionArgs.isEmpty checks them in.
+var chainAbstractionSlots = []Slot{
+	crossChainSlots[0],
+	crossChainSlots[1],
+	crossChainSlots[2],
+	crossChainSlots[3],
+	{Name: "transfer_amount", Question: crossChainSlots[4].Question},
+}
+
+func (c chainAbstraction) MissingSlot(name string, args map[string]interface{}) (Slot, bool) {
+	if name != "cross_chain_abstraction" {
+		return Slot{}, false
+	}
+	if slot, ok := firstMissing(chainAbstractionSlots, args); ok {
+		return slot, true
+	}
+	if slot, ok := ambiguousToken(c.chains, args, chainArg(c.chains, args, "", "source_chain"), "token"); ok {
+		return slot, true
+	}
+	return invalidReceiver(c.chains, args, "target_chain")
+}
+
+func (c chainAbstraction) CheckArgs(name string, args map[string]interface{}) []string {
+	problems := checkAmounts(args, "transfer_amount", "source_chain_token_balance", "target_chain_token_balance")
+	problems = append(problems, checkAddresses(args, "receiver")...)
+	return append(problems, checkChains(c.chains, args, "source_chain", "target_chain")...)
+}
 
 func (c chainAbstraction) Prompt() string {
 	return `I want you to become an experienced cryptocurrency researcher that allows the use of cross-chain protocols.
@@ -171,23 +250,16 @@ func (c chainAbstraction) Functions() []openai.FunctionDefinition {
 	}}
 }
 
-func (c chainAbstraction) Render(resp *model.DemandResponse, name, args string) error {
+func (c chainAbstraction) Render(ctx context.Context, resp *model.DemandResponse, name, args string) error {
 	if name == "cross_chain_abstraction" {
 		in := crossChainAbstractionArgs{}
 		if err := json.Unmarshal([]byte(args), &in); err != nil {
 			return err
 		}
-		if in.TargetChainTokenBalanceDecimal.IsZero() {
-			in.TargetChainTokenBalanceDecimal = decimal.NewFromFloat(in.TargetChainTokenBalance)
-		}
-		if in.SourceChainTokenBalanceDecimal.IsZero() {
-			in.SourceChainTokenBalanceDecimal = decimal.NewFromFloat(in.SourceChainTokenBalance)
-		}
-		if in.TransferAmountDecimal.IsZero() {
-			in.TransferAmountDecimal = decimal.NewFromFloat(in.TransferAmount)
-		}
 		resp.Summary = in.Summary
 		resp.Category = "crossChainAbstraction"
+		in.SourceChain = canonicalChain(c.chains, in.SourceChain)
+		in.TargetChain = canonicalChain(c.chains, in.TargetChain)
 		if reply, ok := in.isEmpty(); ok {
 			resp.Detail = model.DetailResp{
 				Reply: reply,
@@ -195,32 +267,38 @@ func (c chainAbstraction) Render(resp *model.DemandResponse, name, args string)
 			}
 			return nil
 		}
+		if !resolveToken(c.chains, resp, in.SourceChain, &in.Token) {
+			return nil
+		}
+		if !normalizeReceiver(c.chains, resp, in.TargetChain, &in.Receiver) {
+			return nil
+		}
 		// case 1: target chain enough
-		if in.TargetChainTokenBalanceDecimal.Cmp(in.TransferAmountDecimal) > 0 {
-			targetChainId, err := data.GetChainIdByName(in.TargetChain)
+		if in.TargetChainTokenBalance.Cmp(in.TransferAmount) > 0 {
+			targetChainId, err := c.chains.ChainID(in.TargetChain)
 			if err != nil {
 				return err
 			}
 			resp.Detail = model.DetailResp{
-				Reply: fmt.Sprintf("Ok I will transfer %s %s to %s on %s", in.TransferAmountDecimal.String(), in.Token, in.Receiver, in.TargetChain),
-				OPs: []interface{}{
-					model.CrossChainResponse{
+				Reply: fmt.Sprintf("Ok I will transfer %s %s to %s on %s", in.TransferAmount.String(), in.Token, in.Receiver, in.TargetChain),
+				OPs: []model.Op{
+					model.NewOp(&model.CrossChainResponse{
 						Type:            model.ChainInternalTransfer,
 						SourceChainId:   targetChainId,
 						SourceChainName: in.TargetChain,
 						Token:           in.Token,
-						Amount:          in.TransferAmountDecimal.String(),
+						Amount:          in.TransferAmount.String(),
 						Receiver:        in.Receiver,
 						TargetChainName: in.TargetChain,
 						TargetChainId:   targetChainId,
-					},
+					}),
 				},
 			}
 			return nil
 		}
 		// case 2: source chain + target chain
-		if in.SourceChainTokenBalanceDecimal.Add(in.TargetChainTokenBalanceDecimal).Cmp(in.TransferAmountDecimal) > 0 {
-			sourceChainId, err := data.GetChainIdByName(in.SourceChain)
+		if in.SourceChainTokenBalance.Add(in.TargetChainTokenBalance).Cmp(in.TransferAmount) > 0 {
+			sourceChainId, err := c.chains.ChainID(in.SourceChain)
 			if err != nil {
 				log.Errorf("get chain id error: %v", err)
 				resp.Detail = model.DetailResp{
@@ -229,7 +307,7 @@ func (c chainAbstraction) Render(resp *model.DemandResponse, name, args string)
 				}
 				return nil
 			}
-			targetChainId, err := data.GetChainIdByName(in.TargetChain)
+			targetChainId, err := c.chains.ChainID(in.TargetChain)
 			if err != nil {
 				log.Errorf("get chain id error: %v", err)
 				resp.Detail = model.DetailResp{
@@ -238,8 +316,8 @@ func (c chainAbstraction) Render(resp *model.DemandResponse, name, args string)
 				}
 				return nil
 			}
-			ok, ret := pkg.Base.CheckCross(sourceChainId, targetChainId, strings.ToUpper(in.Token))
-			if !ok {
+			ok, ret, err := crossChainConfig(ctx, c.chains, in.SourceChain, in.TargetChain, in.Token)
+			if err != nil || !ok {
 				log.Warnf("token:%s cannot cross chain from %s to %s", in.Token, in.SourceChain, in.TargetChain)
 				resp.Detail = model.DetailResp{
 					Reply: "cross chain failed",
@@ -247,12 +325,13 @@ func (c chainAbstraction) Render(resp *model.DemandResponse, name, args string)
 				}
 				return nil
 			}
-			crossChainBalance := in.TransferAmountDecimal.Sub(in.TargetChainTokenBalanceDecimal)
-			sourceChainId, err = data.GetChainIdByName(in.SourceChain)
+			crossChainBalance, warning := bridgeAmount(c.chains, in.SourceChain, in.Token, ret,
+				in.TransferAmount.Sub(in.TargetChainTokenBalance), in.SourceChainTokenBalance)
+			sourceChainId, err = c.chains.ChainID(in.SourceChain)
 			if err != nil {
 				return err
 			}
-			targetChainId, err = data.GetChainIdByName(in.TargetChain)
+			targetChainId, err = c.chains.ChainID(in.TargetChain)
 			if err != nil {
 				return err
 			}
@@ -261,7 +340,7 @@ func (c chainAbstraction) Render(resp *model.DemandResponse, name, args string)
 				SourceChainName: in.TargetChain,
 				SourceChainId:   targetChainId,
 				Token:           in.Token,
-				Amount:          in.TargetChainTokenBalanceDecimal.String(),
+				Amount:          in.TargetChainTokenBalance.String(),
 				Receiver:        in.Receiver,
 				TargetChainName: in.TargetChain,
 				TargetChainId:   targetChainId,
@@ -276,20 +355,22 @@ func (c chainAbstraction) Render(resp *model.DemandResponse, name, args string)
 				Receiver:        in.Receiver,
 				TargetChainId:   targetChainId,
 				TargetChainName: in.TargetChain,
+				Warning:         warning,
 			}
-			if in.TargetChainTokenBalanceDecimal.IsZero() {
+			if in.TargetChainTokenBalance.IsZero() {
 				resp.Detail = model.DetailResp{
 					Reply: fmt.Sprintf("Ok I will transfer %s %s to %s from %s to %s",
 						crossChainBalance.String(), in.Token, in.Receiver, in.SourceChain, in.TargetChain),
-					OPs: []interface{}{crossTransfer},
+					OPs: []model.Op{model.NewOp(crossTransfer)},
 				}
 			} else {
 				resp.Detail = model.DetailResp{
 					Reply: fmt.Sprintf("Ok I will transfer %s %s to %s from %s to %s, and transfer %s %s to %s on %s",
-						crossChainBalance.String(), in.Token, in.Receiver, in.SourceChain, in.TargetChain, in.TargetChainTokenBalanceDecimal.String(), in.Token, in.Receiver, in.TargetChain),
-					OPs: []interface{}{internalTransfer, crossTransfer},
+						crossChainBalance.String(), in.Token, in.Receiver, in.SourceChain, in.TargetChain, in.TargetChainTokenBalance.String(), in.Token, in.Receiver, in.TargetChain),
+					OPs: []model.Op{model.NewOp(internalTransfer), model.NewOp(crossTransfer)},
 				}
 			}
+			resp.Detail.Reply += opWarnings(resp.Detail.OPs)
 			return nil
 		}
 		// case 3: not enough
@@ -303,27 +384,17 @@ func (c chainAbstraction) Render(resp *model.DemandResponse, name, args string)
 }
 
 func (a *crossChainAbstractionArgs) isEmpty() (reply string, ok bool) {
-	ok = true
-	if a.Token == "" {
-		reply = "missing token"
-		return
-	}
-	if a.SourceChain == "" {
-		reply = "missing source chain"
-		return
-	}
-	if a.Receiver == "" {
-		reply = "missing receiver"
-		return
+	values := []bool{
+		a.Token != "",
+		a.SourceChain != "",
+		a.Receiver != "",
+		a.TargetChain != "",
+		!a.TransferAmount.IsZero(),
 	}
-	if a.TargetChain == "" {
-		reply = "missing target token"
-		return
-	}
-	if a.TransferAmountDecimal.IsZero() {
-		reply = "missing transfer amount"
-		return
+	for i, filled := range values {
+		if !filled {
+			return chainAbstractionSlots[i].Question, true
+		}
 	}
-	ok = false
-	return
+	return "", false
 }
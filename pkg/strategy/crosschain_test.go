@@ -1,11 +1,16 @@
 package strategy
 
 import (
+	"github.com/smarterwallet/demand-abstraction-serv/pkg"
 	"github.com/stretchr/testify/assert"
 	"testing"
 )
 
 func TestCrosschain(t *testing.T) {
+	// ableToCrossChain loads the asset config from the live base service
+	if pkg.Base == nil {
+		t.Skip("base service not initialized")
+	}
 	ok, err := ableToCrossChain("mumbai", "fuji", "USDC")
 	assert.Nil(t, err)
 	assert.Truef(t, ok, "")
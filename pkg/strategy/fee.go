@@ -0,0 +1,184 @@
+package strategy
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/shopspring/decimal"
+
+	"github.com/smarterwallet/demand-abstraction-serv/data"
+	"github.com/smarterwallet/demand-abstraction-serv/model"
+)
+
+// bridgeFees are the fees of a cross chain route: a flat Fee and FeeBps of the
+// amount, both taken from the bridged token, and a NativeFee paid in the
+// native currency of the source chain.
+type bridgeFees struct {
+	Fee       decimal.Decimal `json:"fee"`
+	FeeBps    int             `json:"feeBps"`
+	NativeFee decimal.Decimal `json:"nativeFee"`
+}
+
+// routeFees reads the fees of the first route of a cross chain config body,
+// the one of highest priority. Routes without fee info are free.
+func routeFees(body []byte) (bridgeFees, bool) {
+	var res model.CrossChainResp
+	if len(body) == 0 || json.Unmarshal(body, &res) != nil || len(res.Result) == 0 {
+		return bridgeFees{}, false
+	}
+	var fees bridgeFees
+	if config := res.Result[0].Config; len(config) > 0 && json.Unmarshal(config, &fees) != nil {
+		return bridgeFees{}, false
+	}
+	if fees.FeeBps < 0 || fees.FeeBps >= 10000 || fees.Fee.IsNegative() || fees.NativeFee.IsNegative() {
+		return bridgeFees{}, false
+	}
+	return fees, true
+}
+
+// charge returns the fee taken from amount, rounded up to precision.
+func (f bridgeFees) charge(amount decimal.Decimal, precision int32) decimal.Decimal {
+	return f.Fee.Add(amount.Mul(decimal.New(int64(f.FeeBps), -4))).RoundUp(precision)
+}
+
+// gross returns the least amount to send for net to arrive after the fees.
+func (f bridgeFees) gross(net decimal.Decimal, precision int32) decimal.Decimal {
+	rate := decimal.NewFromInt(1).Sub(decimal.New(int64(f.FeeBps), -4))
+	amount := net.Add(f.Fee).DivRound(rate, precision+1).RoundUp(precision)
+	if amount.Sub(f.charge(amount, precision)).Cmp(net) < 0 {
+		amount = amount.Add(decimal.New(1, -precision))
+	}
+	return amount
+}
+
+// bridgeAmount sizes a bridge of token from chain so that net arrives after
+// the fees of config, sending no more than available. The warning tells how
+// short the receiver falls when available doesn't cover the fees.
+func bridgeAmount(chains *data.Snapshot, chain, token string, config []byte, net, available decimal.Decimal) (decimal.Decimal, string) {
+	fees, ok := routeFees(config)
+	if !ok {
+		return net, ""
+	}
+	precision := tokenPrecision(chains, chain, token)
+	amount := fees.gross(net, precision)
+	if amount.Cmp(available) <= 0 {
+		return amount, ""
+	}
+	received := decimal.Max(available.Sub(fees.charge(available, precision)), decimal.Zero)
+	return available, fmt.Sprintf("bridge fees from %s leave the receiver %s %s short", chain, net.Sub(received), token)
+}
+
+// delivered returns what bridging all of balance from chain brings to the
+// receiver after the fees of config.
+func delivered(chains *data.Snapshot, chain, token string, config []byte, balance decimal.Decimal) decimal.Decimal {
+	fees, ok := routeFees(config)
+	if !ok {
+		return balance
+	}
+	return decimal.Max(balance.Sub(fees.charge(balance, tokenPrecision(chains, chain, token))), decimal.Zero)
+}
+
+// grossAmount returns the least amount to bridge from chain for net to arrive
+// after the fees of config.
+func grossAmount(chains *data.Snapshot, chain, token string, config []byte, net decimal.Decimal) decimal.Decimal {
+	fees, ok := routeFees(config)
+	if !ok {
+		return net
+	}
+	return fees.gross(net, tokenPrecision(chains, chain, token))
+}
+
+// withFees lists the fees of the ops: what bridges charge, read from their
+// cross chain config, and the gas swaps are quoted for.
+func withFees(chains *data.Snapshot, ops []model.Op) []model.Op {
+	for _, op := range ops {
+		switch o := op.Body.(type) {
+		case *model.CrossChainResponse:
+			o.Fees, o.Received = nil, ""
+			fees, ok := routeFees(o.RawResponse)
+			amount, err := decimal.NewFromString(o.Amount)
+			if o.Type != model.CrossChainTransfer || !ok || err != nil {
+				continue
+			}
+			charge := fees.charge(amount, tokenPrecision(chains, o.SourceChainName, o.Token))
+			if charge.IsPositive() {
+				o.Fees = append(o.Fees, model.Fee{Kind: model.FeeBridge, Token: o.Token, Amount: charge.String(),
+					USD: usdString(charge, chains.UsdStable(o.Token))})
+			}
+			if fees.NativeFee.IsPositive() {
+				o.Fees = append(o.Fees, model.Fee{Kind: model.FeeBridge, Token: nativeSymbol(chains, o.SourceChainName), Amount: fees.NativeFee.String()})
+			}
+			o.Received = decimal.Max(amount.Sub(charge), decimal.Zero).String()
+		case *model.SwapResponse:
+			o.Fees = nil
+			if o.GasCost != "" || o.GasUSD != "" {
+				o.Fees = []model.Fee{{Kind: model.FeeGas, Token: nativeSymbol(chains, o.ChainName), Amount: o.GasCost, USD: o.GasUSD}}
+			}
+		}
+	}
+	return ops
+}
+
+func nativeSymbol(chains *data.Snapshot, chain string) string {
+	c, err := chains.Chain(chain)
+	if err != nil {
+		return ""
+	}
+	return c.Native
+}
+
+// FeeSummary totals the fees of ops by kind and token, nil when they have
+// none.
+func FeeSummary(ops []model.Op) *model.FeeSummary {
+	var (
+		total    []model.Fee
+		index    = make(map[[2]string]int)
+		unpriced = make(map[int]bool)
+		usd      decimal.Decimal
+	)
+	for _, op := range ops {
+		var fees []model.Fee
+		switch o := op.Body.(type) {
+		case *model.CrossChainResponse:
+			fees = o.Fees
+		case *model.SwapResponse:
+			fees = o.Fees
+		}
+		for _, fee := range fees {
+			key := [2]string{fee.Kind, fee.Token}
+			i, ok := index[key]
+			if !ok {
+				i = len(total)
+				index[key] = i
+				total = append(total, model.Fee{Kind: fee.Kind, Token: fee.Token})
+			}
+			total[i].Amount = addAmounts(total[i].Amount, fee.Amount)
+			if feeUSD, err := decimal.NewFromString(fee.USD); err == nil {
+				usd = usd.Add(feeUSD)
+				total[i].USD = addAmounts(total[i].USD, fee.USD)
+			} else {
+				unpriced[i] = true
+			}
+		}
+	}
+	if len(total) == 0 {
+		return nil
+	}
+	for i := range unpriced {
+		total[i].USD = ""
+	}
+	return &model.FeeSummary{Total: total, USD: usdString(usd, len(unpriced) == 0)}
+}
+
+// addAmounts sums two decimal strings, empty ones counting as missing.
+func addAmounts(a, b string) string {
+	x, errA := decimal.NewFromString(a)
+	y, errB := decimal.NewFromString(b)
+	switch {
+	case errA != nil:
+		return b
+	case errB != nil:
+		return a
+	}
+	return x.Add(y).String()
+}
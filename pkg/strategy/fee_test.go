@@ -0,0 +1,88 @@
+package strategy
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sashabaranov/go-openai"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/smarterwallet/demand-abstraction-serv/data"
+	"github.com/smarterwallet/demand-abstraction-serv/model"
+)
+
+// feeConfig is a cross chain config charging 0.5 USDC and 0.3% of the amount,
+// plus 0.01 of the native currency.
+var feeConfig = []byte(`{"code":200,"result":[{"protocolName":"CCIP","config":{"fee":"0.5","feeBps":30,"nativeFee":0.01}}]}`)
+
+func TestFees(t *testing.T) {
+	b := data.NewSnapshotBuilder(nil, nil)
+	b.AddChain("mumbai", 80001, 80001, "MATIC")
+	b.AddChain("fuji", 43113, 43113, "AVAX")
+	for _, chain := range []string{"mumbai", "fuji"} {
+		b.AddToken(chain, data.Token{Symbol: "USDC", Address: "0x" + chain, Decimal: 6})
+	}
+	chains := b.Build()
+
+	fees, ok := routeFees(feeConfig)
+	assert.True(t, ok)
+	assert.Equal(t, "0.53", fees.charge(decimal.NewFromInt(10), 6).String())
+	_, ok = routeFees([]byte(`{"code":200,"result":[{"config":{"feeBps":10000}}]}`))
+	assert.False(t, ok)
+
+	amount, warning := bridgeAmount(chains, "mumbai", "USDC", feeConfig, decimal.NewFromInt(10), decimal.NewFromInt(20))
+	assert.Equal(t, "10.531595", amount.String())
+	assert.Empty(t, warning)
+	amount, warning = bridgeAmount(chains, "mumbai", "USDC", feeConfig, decimal.NewFromInt(10), decimal.RequireFromString("10.2"))
+	assert.Equal(t, "10.2", amount.String())
+	assert.Equal(t, "bridge fees from mumbai leave the receiver 0.3306 USDC short", warning)
+	// configs without fee info are free
+	amount, _ = bridgeAmount(chains, "mumbai", "USDC", []byte(`{"code":200,"result":[{"config":{}}]}`), decimal.NewFromInt(10), decimal.NewFromInt(10))
+	assert.Equal(t, "10", amount.String())
+
+	bridge := &model.CrossChainResponse{Type: model.CrossChainTransfer, SourceChainName: "mumbai", Token: "USDC", Amount: "10.531595", RawResponse: feeConfig}
+	swap := &model.SwapResponse{ChainName: "mumbai", SourceToken: "DAI", TargetToken: "USDC", GasCost: "0.002", GasUSD: "0.5"}
+	ops := withFees(chains, []model.Op{model.NewOp(swap), model.NewOp(bridge), model.NewOp(&model.CrossChainResponse{Type: model.ChainInternalTransfer, Amount: "1"})})
+	assert.Equal(t, "10", bridge.Received)
+	assert.Equal(t, []model.Fee{{Kind: model.FeeBridge, Token: "USDC", Amount: "0.531595", USD: "0.531595"}, {Kind: model.FeeBridge, Token: "MATIC", Amount: "0.01"}}, bridge.Fees)
+	assert.Equal(t, []model.Fee{{Kind: model.FeeGas, Token: "MATIC", Amount: "0.002", USD: "0.5"}}, swap.Fees)
+	summary := FeeSummary(append(ops, model.NewOp(&model.SwapResponse{Fees: []model.Fee{{Kind: model.FeeGas, Token: "MATIC", Amount: "0.001", USD: "0.25"}}})))
+	assert.Equal(t, &model.FeeSummary{Total: []model.Fee{
+		{Kind: model.FeeGas, Token: "MATIC", Amount: "0.003", USD: "0.75"},
+		{Kind: model.FeeBridge, Token: "USDC", Amount: "0.531595", USD: "0.531595"},
+		{Kind: model.FeeBridge, Token: "MATIC", Amount: "0.01"},
+	}}, summary)
+	bridge.Fees = bridge.Fees[:1]
+	assert.Equal(t, "1.031595", FeeSummary(ops).USD)
+	assert.Nil(t, FeeSummary(nil))
+
+	// the bridge sends its fees on top so the receiver gets the 10 asked
+	defer func(check func(int, int, string) (bool, []byte)) { checkCross = check }(checkCross)
+	checkCross = func(sourceChainId, targetChainId int, token string) (bool, []byte) {
+		return true, feeConfig
+	}
+	resp := &model.DemandResponse{}
+	_, err := RenderPlan(context.Background(), chainAbstraction{chains: chains}, chains, nil, resp, []openai.ToolCall{{Function: openai.FunctionCall{
+		Name: "cross_chain_abstraction",
+		Arguments: `{"token":"USDC","source_chain":"mumbai","source_chain_token_balance":20,"target_chain":"fuji","target_chain_token_balance":0,
+			"transfer_amount":10,"receiver":"0xd8da6bf26964af9d7eed9e03e53415d37aa96045"}`,
+	}}})
+	assert.Nil(t, err)
+	if assert.Len(t, resp.Detail.OPs, 1) {
+		cross := resp.Detail.OPs[0].Body.(*model.CrossChainResponse)
+		assert.Equal(t, []string{"10.531595", "10", "10531595"}, []string{cross.Amount, cross.Received, cross.AmountRaw})
+	}
+	if assert.NotNil(t, resp.Detail.Fees) {
+		assert.Equal(t, "0.531595", resp.Detail.Fees.Total[0].Amount)
+	}
+
+	resp = &model.DemandResponse{}
+	_, err = RenderPlan(context.Background(), chainAbstraction{chains: chains}, chains, nil, resp, []openai.ToolCall{{Function: openai.FunctionCall{
+		Name: "cross_chain_abstraction",
+		Arguments: `{"token":"USDC","source_chain":"mumbai","source_chain_token_balance":10.2,"target_chain":"fuji","target_chain_token_balance":0,
+			"transfer_amount":10,"receiver":"0xd8da6bf26964af9d7eed9e03e53415d37aa96045"}`,
+	}}})
+	assert.Nil(t, err)
+	assert.Contains(t, resp.Detail.Reply, ". Warning: bridge fees from mumbai leave the receiver 0.3306 USDC short")
+}
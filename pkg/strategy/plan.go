@@ -0,0 +1,139 @@
+package strategy
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/sashabaranov/go-openai"
+	"github.com/shopspring/decimal"
+
+	"github.com/smarterwallet/demand-abstraction-serv/data"
+	"github.com/smarterwallet/demand-abstraction-serv/model"
+)
+
+type planToken struct {
+	chain string
+	token string
+}
+
+// RenderPlan renders every tool call through st in order and merges the results
+// into resp as one plan. Ops are numbered by step and depend on the earlier
+// steps whose output they spend; balances in demandCtx are adjusted after each
+// call so later calls are planned against what earlier ones leave behind. When
+// any call of a multi-call plan renders no op, the whole plan is dropped.
+// Swaps are bounded by the QuotePolicy of ctx and encoded to pay the wallet
+// of demandCtx, and the fees of every op are totalled in the detail. The per-call responses are returned in order.
+func RenderPlan(ctx context.Context, st IStrategy, chains *data.Snapshot, demandCtx *model.CtxRequest, resp *model.DemandResponse, calls []openai.ToolCall) ([]*model.DemandResponse, error) {
+	p := &planner{demandCtx: demandCtx, producers: make(map[planToken]int)}
+	policy := quotePolicy(ctx)
+	var sender string
+	if demandCtx != nil {
+		sender = demandCtx.Address
+	}
+	steps := make([]*model.DemandResponse, 0, len(calls))
+	for _, call := range calls {
+		step := &model.DemandResponse{}
+		if err := st.Render(ctx, step, call.Function.Name, call.Function.Arguments); err != nil {
+			return steps, err
+		}
+		ops := p.link(withFees(chains, step.Detail.OPs))
+		step.Detail.OPs = withCalldata(chains, sender, withQuoteBounds(chains, withRawAmounts(chains, ops), policy))
+		step.Detail.Fees = FeeSummary(step.Detail.OPs)
+		steps = append(steps, step)
+	}
+	if len(steps) == 1 {
+		*resp = *steps[0]
+		return steps, nil
+	}
+	var (
+		summaries = make([]string, 0, len(steps))
+		replies   = make([]string, 0, len(steps))
+		ops       = make([]model.Op, 0)
+		failed    = false
+	)
+	for i, step := range steps {
+		if resp.Category == "" {
+			resp.Category = step.Category
+		}
+		if step.Summary != "" {
+			summaries = append(summaries, step.Summary)
+		}
+		if len(step.Detail.OPs) == 0 {
+			failed = true
+			replies = append(replies, fmt.Sprintf("Step %d failed: %s", i+1, step.Detail.Reply))
+			continue
+		}
+		if step.Detail.Reply != "" {
+			replies = append(replies, step.Detail.Reply)
+		}
+		ops = append(ops, step.Detail.OPs...)
+	}
+	resp.Summary = strings.Join(summaries, "; ")
+	resp.Detail = model.DetailResp{Reply: strings.Join(replies, ". Then ")}
+	if !failed {
+		resp.Detail.OPs = ops
+		resp.Detail.Fees = FeeSummary(ops)
+	}
+	return steps, nil
+}
+
+type planner struct {
+	demandCtx *model.CtxRequest
+	producers map[planToken]int
+	step      int
+}
+
+// link numbers ops after those already planned, records which earlier op
+// produced the token each op spends and applies every op to the balances.
+func (p *planner) link(ops []model.Op) []model.Op {
+	for _, op := range ops {
+		p.step++
+		switch o := op.Body.(type) {
+		case *model.SwapResponse:
+			o.Step = p.step
+			o.DependsOn = p.dependsOn(o.ChainName, o.SourceToken)
+			p.producers[newPlanToken(o.ChainName, o.TargetToken)] = p.step
+			p.adjustBalance(o.ChainName, o.SourceToken, o.SwapIn, true)
+			p.adjustBalance(o.ChainName, o.TargetToken, o.SwapOut, false)
+		case *model.CrossChainResponse:
+			o.Step = p.step
+			o.DependsOn = p.dependsOn(o.SourceChainName, o.Token)
+			p.adjustBalance(o.SourceChainName, o.Token, o.Amount, true)
+			if p.demandCtx != nil && strings.EqualFold(o.Receiver, p.demandCtx.Address) {
+				p.producers[newPlanToken(o.TargetChainName, o.Token)] = p.step
+				received := o.Amount
+				if o.Received != "" {
+					received = o.Received
+				}
+				p.adjustBalance(o.TargetChainName, o.Token, received, false)
+			}
+		}
+	}
+	return ops
+}
+
+func newPlanToken(chain, token string) planToken {
+	return planToken{chain: strings.ToLower(chain), token: strings.ToUpper(token)}
+}
+
+func (p *planner) dependsOn(chain, token string) []int {
+	if step, ok := p.producers[newPlanToken(chain, token)]; ok {
+		return []int{step}
+	}
+	return nil
+}
+
+func (p *planner) adjustBalance(chain, token, amount string, spend bool) {
+	if p.demandCtx == nil {
+		return
+	}
+	delta, err := decimal.NewFromString(amount)
+	if err != nil {
+		return
+	}
+	if spend {
+		delta = delta.Neg()
+	}
+	p.demandCtx.AdjustBalance(strings.ToLower(chain), strings.ToUpper(token), delta)
+}
@@ -0,0 +1,20 @@
+package strategy
+
+import "context"
+
+// ProgressFunc receives the intermediate stages of rendering a demand.
+type ProgressFunc func(stage string, data interface{})
+
+type progressKey struct{}
+
+// WithProgress returns a context that reports rendering stages to fn.
+func WithProgress(ctx context.Context, fn ProgressFunc) context.Context {
+	return context.WithValue(ctx, progressKey{}, fn)
+}
+
+// Progress reports a stage to the ProgressFunc carried by ctx, if any.
+func Progress(ctx context.Context, stage string, data interface{}) {
+	if fn, ok := ctx.Value(progressKey{}).(ProgressFunc); ok && fn != nil {
+		fn(stage, data)
+	}
+}
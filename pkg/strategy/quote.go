@@ -0,0 +1,215 @@
+package strategy
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	log "github.com/cihub/seelog"
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+
+	"github.com/smarterwallet/demand-abstraction-serv/data"
+	"github.com/smarterwallet/demand-abstraction-serv/model"
+	"github.com/smarterwallet/demand-abstraction-serv/pkg"
+)
+
+// checkSwap quotes the input a swap output takes. Tests replace it.
+var checkSwap = func(req model.SwapReq) (string, []byte, error) {
+	if pkg.Base == nil {
+		return "", nil, errors.New("base service not initialized")
+	}
+	return pkg.Base.CheckSwap(req)
+}
+
+// swapQuote is the price of buying a fixed output with one reserve.
+type swapQuote struct {
+	Token    string
+	Address  string
+	Balance  decimal.Decimal
+	AmountIn decimal.Decimal
+	GasUSD   decimal.Decimal
+	// CostUSD is the input plus gas in USD, set when Priced.
+	CostUSD decimal.Decimal
+	Priced  bool
+	Body    []byte
+}
+
+// quoteSwaps quotes amountOut of outToken on chain from every reserve of pairs
+// at once. It returns the quotes best first, whether the balance covers them or
+// not: priced quotes by cost in USD, then the others by symbol.
+func (t transfer) quoteSwaps(chainId int, pairs []model.Reserve, chain, outToken string, amountOut decimal.Decimal) []swapQuote {
+	pairs = append([]model.Reserve(nil), pairs...)
+	sort.SliceStable(pairs, func(i, j int) bool {
+		return pairs[i].Symbol < pairs[j].Symbol
+	})
+	held := make([]model.Reserve, 0, len(pairs))
+	amounts := make([]decimal.Decimal, 0, len(pairs))
+	for _, reserve := range pairs {
+		if reserve.Balance.IsPositive() {
+			held = append(held, reserve)
+			amounts = append(amounts, amountOut)
+		}
+	}
+	quotes := make([]swapQuote, 0, len(held))
+	for _, q := range t.quoteEach(chainId, held, chain, outToken, amounts) {
+		if q != nil {
+			quotes = append(quotes, *q)
+		}
+	}
+	sort.SliceStable(quotes, func(i, j int) bool {
+		if quotes[i].Priced != quotes[j].Priced {
+			return quotes[i].Priced
+		}
+		return quotes[i].Priced && quotes[i].CostUSD.Cmp(quotes[j].CostUSD) < 0
+	})
+	return quotes
+}
+
+// quoteEach quotes amounts[i] of outToken from reserves[i] at once, keeping
+// nil for the swaps that fail.
+func (t transfer) quoteEach(chainId int, reserves []model.Reserve, chain, outToken string, amounts []decimal.Decimal) []*swapQuote {
+	results := make([]*swapQuote, len(reserves))
+	var wg sync.WaitGroup
+	for i, reserve := range reserves {
+		wg.Add(1)
+		go func(i int, reserve model.Reserve) {
+			defer wg.Done()
+			results[i] = t.quoteSwap(chainId, reserve, chain, outToken, amounts[i])
+		}(i, reserve)
+	}
+	wg.Wait()
+	return results
+}
+
+// quoteSwap quotes amountOut of outToken from reserve, nil when the swap fails.
+func (t transfer) quoteSwap(chainId int, reserve model.Reserve, chain, outToken string, amountOut decimal.Decimal) *swapQuote {
+	minIn, body, err := checkSwap(model.SwapReq{
+		ChainId:         chainId,
+		TokenInAddress:  reserve.Address,
+		TokenOutAddress: t.balance.GetTokenAddress(chain, outToken),
+		AmountOut:       json.Number(amountOut.String()),
+	})
+	if err != nil {
+		return nil
+	}
+	amountIn, err := decimal.NewFromString(minIn)
+	if err != nil || !amountIn.IsPositive() {
+		return nil
+	}
+	q := &swapQuote{Token: reserve.Symbol, Address: reserve.Address, Balance: reserve.Balance, AmountIn: amountIn, Body: body}
+	q.GasUSD, q.CostUSD, q.Priced = priceQuote(t.chains, reserve.Symbol, amountIn, body)
+	return q
+}
+
+// priceQuote returns the gas of a quote body spending amountIn of token and
+// the cost of the swap in USD, when it can be priced. Stablecoins are worth a
+// dollar; other inputs are priced by the gas the quote gives both in USD and in
+// the input.
+func priceQuote(chains *data.Snapshot, token string, amountIn decimal.Decimal, body []byte) (gasUSD, costUSD decimal.Decimal, priced bool) {
+	res, err := model.DecodeSwapResult(body)
+	if err != nil {
+		return
+	}
+	gasUSD = res.EstimatedGasUsedUSD.Decimal()
+	gasIn := res.EstimatedGasUsedQuoteToken.Decimal()
+	switch {
+	case chains.UsdStable(token):
+		costUSD, priced = amountIn.Add(gasUSD), true
+	case gasIn.IsPositive() && gasUSD.IsPositive():
+		costUSD, priced = amountIn.Mul(gasUSD).Div(gasIn).Add(gasUSD), true
+	}
+	return
+}
+
+// Reserve returns the holding the quote swaps from.
+func (q swapQuote) Reserve() model.Reserve {
+	return model.Reserve{Symbol: q.Token, Address: q.Address, Balance: q.Balance}
+}
+
+// Covered reports whether the balance pays for the quote.
+func (q swapQuote) Covered() bool {
+	return q.Balance.Cmp(q.AmountIn) >= 0
+}
+
+// covered returns the quotes the balances pay for, keeping their order.
+func covered(quotes []swapQuote) []swapQuote {
+	var res []swapQuote
+	for _, q := range quotes {
+		if q.Covered() {
+			res = append(res, q)
+		} else {
+			log.Debugf("swap from %s needs %s, holding %s", q.Token, q.AmountIn, q.Balance)
+		}
+	}
+	return res
+}
+
+// usdString formats a USD amount of a quote, empty when it isn't known.
+func usdString(amount decimal.Decimal, known bool) string {
+	if !known {
+		return ""
+	}
+	return amount.Round(6).String()
+}
+
+// swapOp is the swap of a quote buying amountOut of outToken, listing others
+// as its alternatives.
+func swapOp(chainId int, chain, outToken string, amountOut decimal.Decimal, q swapQuote, others []swapQuote) model.SwapResponse {
+	op := model.SwapResponse{
+		Type:         "swap",
+		ChainId:      chainId,
+		ChainName:    chain,
+		RawResponse:  q.Body,
+		SourceToken:  q.Token,
+		TargetToken:  outToken,
+		SwapIn:       q.AmountIn.String(),
+		SwapOut:      amountOut.String(),
+		Dex:          "uniswap",
+		GasUSD:       usdString(q.GasUSD, q.GasUSD.IsPositive()),
+		CostUSD:      usdString(q.CostUSD, q.Priced),
+		Alternatives: alternatives(others),
+	}
+	stampQuote(&op, q.Body)
+	return op
+}
+
+// opWarnings tells of the warnings of ops, empty when they have none.
+func opWarnings(ops []model.Op) string {
+	var warnings []string
+	for _, op := range ops {
+		switch o := op.Body.(type) {
+		case *model.SwapResponse:
+			if o.Warning != "" {
+				warnings = append(warnings, fmt.Sprintf("swapping %s has a %s", o.SourceToken, o.Warning))
+			}
+			if o.EstimatedOut {
+				warnings = append(warnings, fmt.Sprintf("the %s bought is an estimate", o.TargetToken))
+			}
+		case *model.CrossChainResponse:
+			if o.Warning != "" {
+				warnings = append(warnings, o.Warning)
+			}
+		}
+	}
+	if len(warnings) == 0 {
+		return ""
+	}
+	return ". Warning: " + strings.Join(warnings, ", ")
+}
+
+// alternatives lists the runner-up quotes of a swap.
+func alternatives(quotes []swapQuote) []model.SwapAlternative {
+	var alts []model.SwapAlternative
+	for _, q := range quotes {
+		alts = append(alts, model.SwapAlternative{
+			SourceToken: q.Token,
+			SwapIn:      q.AmountIn.String(),
+			GasUSD:      usdString(q.GasUSD, q.GasUSD.IsPositive()),
+			CostUSD:     usdString(q.CostUSD, q.Priced),
+		})
+	}
+	return alts
+}
@@ -0,0 +1,86 @@
+package strategy
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/smarterwallet/demand-abstraction-serv/model"
+)
+
+// swapBody is a quote of the swap router with its gas in USD and in the input,
+// both with 6 decimals.
+func swapBody(minIn string, gasUSD, gasIn int) []byte {
+	return []byte(fmt.Sprintf(`{"code":200,"result":{"minInAmount":%q,
+		"estimatedGasUsedUSD":{"numerator":[%d],"denominator":[1],"decimalScale":[1000000]},
+		"estimatedGasUsedQuoteToken":{"numerator":[%d],"denominator":[1],"decimalScale":[1000000]}}}`, minIn, gasUSD, gasIn))
+}
+
+// stubSwaps quotes dai at 1.01, weth at 0.0004 and wmatic at 2 per usdc, with
+// gas of 0.5, 0.3 and 0.1 USD.
+func stubSwaps(calls *int32) func(model.SwapReq) (string, []byte, error) {
+	return func(req model.SwapReq) (string, []byte, error) {
+		atomic.AddInt32(calls, 1)
+		out := decimal.RequireFromString(string(req.AmountOut))
+		var minIn string
+		switch req.TokenInAddress {
+		case "0xdai":
+			minIn = out.Mul(decimal.RequireFromString("1.01")).String()
+			return minIn, swapBody(minIn, 500000, 500000), nil
+		case "0xweth":
+			// gas of 0.3 USD is 0.00015 WETH, a WETH is worth 2000 USD
+			minIn = out.Mul(decimal.RequireFromString("0.0004")).String()
+			return minIn, swapBody(minIn, 300000, 150), nil
+		case "0xwmatic":
+			minIn = out.Mul(decimal.NewFromInt(2)).String()
+			return minIn, swapBody(minIn, 100000, 100000), nil
+		}
+		return "", nil, errors.New("no route")
+	}
+}
+
+func TestPlanSwaps(t *testing.T) {
+	chains := testChains(t)
+	defer func(check func(model.SwapReq) (string, []byte, error)) { checkSwap = check }(checkSwap)
+	var calls int32
+	checkSwap = stubSwaps(&calls)
+	balance := &model.CtxRequest{BaseChain: "mumbai", Balances: map[string][]model.Reserve{"mumbai": {
+		{Symbol: "USDC", Address: "0x9999f7fea5938fd3b1e26a12c3f2fdd5c2e0f9a1", Balance: decimal.NewFromInt(2)},
+		{Symbol: "WMATIC", Address: "0xwmatic", Balance: decimal.NewFromInt(5)},
+		{Symbol: "WETH", Address: "0xweth", Balance: decimal.NewFromInt(1)},
+		{Symbol: "LINK", Address: "0xlink", Balance: decimal.NewFromInt(9)},
+		{Symbol: "DAI", Address: "0xdai", Balance: decimal.NewFromInt(50)},
+		{Symbol: "UNI", Address: "0xuni", Balance: decimal.Zero},
+	}}}
+	st := transfer{balance: balance, chains: chains}
+
+	plan, ok := st.planSwaps("mumbai", "USDC", decimal.NewFromInt(10))
+	assert.True(t, ok)
+	op := plan.Swaps[0]
+	assert.EqualValues(t, 4, calls)
+	assert.Equal(t, "WETH", op.SourceToken)
+	assert.Equal(t, "0.004", op.SwapIn)
+	assert.Equal(t, "10", op.SwapOut)
+	assert.Equal(t, "0.3", op.GasUSD)
+	assert.Equal(t, "8.3", op.CostUSD)
+	// wmatic takes more than the balance, link has no route
+	assert.Equal(t, []model.SwapAlternative{{SourceToken: "DAI", SwapIn: "10.1", GasUSD: "0.5", CostUSD: "10.6"}}, op.Alternatives)
+
+	balance.Balances["mumbai"][2].Balance = decimal.RequireFromString("0.001")
+	// 2.5 usdc of weth and 7.5 of dai cost less than 10 of dai
+	plan, ok = st.planSwaps("mumbai", "USDC", decimal.NewFromInt(10))
+	assert.True(t, ok)
+	if assert.Len(t, plan.Swaps, 2) {
+		assert.Equal(t, []string{"WETH", "0.001", "2.5"}, []string{plan.Swaps[0].SourceToken, plan.Swaps[0].SwapIn, plan.Swaps[0].SwapOut})
+		assert.Equal(t, []string{"DAI", "7.575", "7.5"}, []string{plan.Swaps[1].SourceToken, plan.Swaps[1].SwapIn, plan.Swaps[1].SwapOut})
+	}
+	assert.Equal(t, "10.375", plan.CostUSD.String())
+
+	balance.Balances["mumbai"][4].Balance = decimal.NewFromInt(1)
+	_, ok = st.planSwaps("mumbai", "USDC", decimal.NewFromInt(10))
+	assert.False(t, ok)
+}
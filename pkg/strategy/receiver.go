@@ -0,0 +1,43 @@
+package strategy
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/smarterwallet/demand-abstraction-serv/data"
+	"github.com/smarterwallet/demand-abstraction-serv/model"
+	"github.com/smarterwallet/demand-abstraction-serv/pkg/address"
+)
+
+// receiverQuestion asks for the receiver again, explaining what is wrong with
+// the one given.
+func receiverQuestion(err error) string {
+	var invalid *address.InvalidError
+	if errors.As(err, &invalid) && invalid.Address != "" {
+		return fmt.Sprintf("The receiver %s is not a valid address: %s. Which address should receive the transfer?", invalid.Address, invalid.Reason)
+	}
+	return "Which address should receive the transfer?"
+}
+
+// invalidReceiver reports the receiver slot again when the receiver of args is
+// not a valid address on the chain it is sent to, the first of names given.
+func invalidReceiver(chains *data.Snapshot, args map[string]interface{}, names ...string) (Slot, bool) {
+	receiver, _ := args["receiver"].(string)
+	chain := chainArg(chains, args, "", names...)
+	if _, err := address.Normalize(chains, chain, receiver); err != nil {
+		return Slot{Name: "receiver", Question: receiverQuestion(err)}, true
+	}
+	return Slot{}, false
+}
+
+// normalizeReceiver replaces receiver with its canonical form on chain. When it
+// is invalid resp gets a question for a valid one and false is returned.
+func normalizeReceiver(chains *data.Snapshot, resp *model.DemandResponse, chain string, receiver *string) bool {
+	normalized, err := address.Normalize(chains, chain, *receiver)
+	if err != nil {
+		resp.Detail = model.DetailResp{Reply: receiverQuestion(err)}
+		return false
+	}
+	*receiver = normalized
+	return true
+}
@@ -0,0 +1,42 @@
+package strategy
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/smarterwallet/demand-abstraction-serv/model"
+)
+
+func TestReceiver(t *testing.T) {
+	chains := testChains(t)
+	st := transfer{balance: &model.CtxRequest{BaseChain: "mumbai"}, chains: chains}
+	args := `{"token":"USDC","amount":10,"receiver":"0xd8dA6BF26964aF9D7eEd9e03E53415D37aA96046","target_chain":"fuji"}`
+	slot, _, missing := MissingSlot(st, "get_trade_strategy", args)
+	assert.True(t, missing)
+	assert.Equal(t, "receiver", slot.Name)
+	assert.Contains(t, slot.Question, "checksum")
+
+	_, _, missing = MissingSlot(st, "get_trade_strategy", `{"token":"USDC","amount":10,"receiver":"0xd8da6bf26964af9d7eed9e03e53415d37aa96045"}`)
+	assert.False(t, missing)
+	// a bad checksum leaves the receiver to fill
+	assert.False(t, SlotFilled(st, "get_trade_strategy", args, "receiver"))
+	assert.False(t, SlotFilled(st, "get_trade_strategy", `{"token":"USDC","amount":10}`, "receiver"))
+	assert.True(t, SlotFilled(st, "get_trade_strategy", `{"token":"USDC","amount":10,"receiver":"0xd8da6bf26964af9d7eed9e03e53415d37aa96045"}`, "receiver"))
+
+	resp := &model.DemandResponse{}
+	err := crossChain{chains: chains}.Render(context.Background(), resp, "cross_chain_analyze",
+		`{"source_chain":"mumbai","target_chain":"fuji","token":"USDC","amount":1,"receiver":"0x0000000000000000000000000000000000000000"}`)
+	assert.Nil(t, err)
+	assert.Empty(t, resp.Detail.OPs)
+	assert.Contains(t, resp.Detail.Reply, "zero address")
+
+	resp = &model.DemandResponse{}
+	err = crossChain{chains: chains}.Render(context.Background(), resp, "cross_chain_analyze",
+		`{"source_chain":"mumbai","target_chain":"fuji","token":"USDC","amount":1,"receiver":"0xd8da6bf26964af9d7eed9e03e53415d37aa96045"}`)
+	assert.Nil(t, err)
+	if assert.Len(t, resp.Detail.OPs, 1) {
+		assert.Equal(t, "0xd8dA6BF26964aF9D7eEd9e03E53415D37aA96045", resp.Detail.OPs[0].Body.(*model.CrossChainResponse).Receiver)
+	}
+}
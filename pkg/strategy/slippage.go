@@ -0,0 +1,248 @@
+package strategy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	log "github.com/cihub/seelog"
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+
+	"github.com/smarterwallet/demand-abstraction-serv/data"
+	"github.com/smarterwallet/demand-abstraction-serv/model"
+)
+
+// QuotePolicy is the slippage swaps of a plan are bounded by and how long their
+// quotes hold.
+type QuotePolicy struct {
+	SlippageBps int
+	TTL         time.Duration
+}
+
+// DefaultQuotePolicy applies to plans rendered without a policy in context.
+var DefaultQuotePolicy = QuotePolicy{SlippageBps: 50, TTL: 2 * time.Minute}
+
+type quotePolicyKey struct{}
+
+// WithQuotePolicy returns a context whose plans apply p to their swaps.
+func WithQuotePolicy(ctx context.Context, p QuotePolicy) context.Context {
+	return context.WithValue(ctx, quotePolicyKey{}, p)
+}
+
+func quotePolicy(ctx context.Context) QuotePolicy {
+	if p, ok := ctx.Value(quotePolicyKey{}).(QuotePolicy); ok {
+		return p
+	}
+	return DefaultQuotePolicy
+}
+
+// highPriceImpact is the price impact past which a swap carries a warning.
+var highPriceImpact = decimal.New(3, -2)
+
+// stampQuote records when the quote of o was made, at which block, and what the
+// router says of the trade: its price, price impact, routes and gas.
+func stampQuote(o *model.SwapResponse, body []byte) {
+	o.QuotedAt = time.Now().Unix()
+	res, err := model.DecodeSwapResult(body)
+	if err != nil {
+		log.Debugf("decode swap quote error: %v", err)
+		res = &model.SwapResult{}
+	}
+	o.BlockNumber = res.BlockNumber.Uint64()
+	o.ExecutionPrice, o.PriceImpact, o.Warning = "", "", ""
+	if price, ok := res.ExecutionPrice(); ok {
+		o.ExecutionPrice = model.RatDecimal(price).String()
+	}
+	if impact, ok := res.PriceImpact(); ok {
+		share := model.RatDecimal(impact)
+		o.PriceImpact = share.Round(6).String()
+		if share.Cmp(highPriceImpact) >= 0 {
+			o.Warning = fmt.Sprintf("price impact of %s%%", share.Shift(2).Round(2))
+		}
+	}
+	o.Route = res.Routes()
+	o.GasUsed, o.GasCost = 0, ""
+	if gas, cost := res.GasCost(); gas > 0 {
+		o.GasUsed, o.GasCost = gas, cost.String()
+	}
+}
+
+// quoteExactIn estimates what amountIn of tokenIn buys of tokenOut. The
+// router quotes exact outputs only, so the output is first priced, one to one
+// between dollar stablecoins and by a probe otherwise. The output amountIn buys
+// at that price is quoted, then the output at the rate of that quote, near the
+// size of the trade, gives the estimate. The body is the one of the last
+// quote.
+func quoteExactIn(chains *data.Snapshot, req model.SwapReq, chain, tokenIn, tokenOut string, amountIn decimal.Decimal) (decimal.Decimal, []byte, error) {
+	precision := tokenPrecision(chains, chain, tokenOut)
+	out := amountIn
+	if !chains.UsdStable(tokenIn) || !chains.UsdStable(tokenOut) {
+		price, err := probePrice(req, precision)
+		if err != nil {
+			return decimal.Zero, nil, err
+		}
+		out = amountIn.Mul(price).Truncate(precision)
+	}
+	var body []byte
+	for i := 0; i < 2; i++ {
+		if !out.IsPositive() {
+			return decimal.Zero, nil, errors.New("swap not support")
+		}
+		req.AmountOut = json.Number(out.String())
+		minIn, quoted, err := checkSwap(req)
+		if err != nil {
+			return decimal.Zero, nil, err
+		}
+		quotedIn, err := decimal.NewFromString(minIn)
+		if err != nil || !quotedIn.IsPositive() {
+			return decimal.Zero, nil, errors.New("swap not support")
+		}
+		out = amountIn.Mul(out).DivRound(quotedIn, precision+1).Truncate(precision)
+		body = quoted
+	}
+	if !out.IsPositive() {
+		return decimal.Zero, nil, errors.New("swap not support")
+	}
+	return out, body, nil
+}
+
+// probePrice returns the output per input of a swap from a quote of a small
+// output, a thousandth of a token or less: the mid price of the pools it goes
+// through, or the price of the quote itself without them.
+func probePrice(req model.SwapReq, precision int32) (decimal.Decimal, error) {
+	exp := precision / 2
+	if exp < 3 {
+		exp = 3
+	}
+	probe := decimal.New(1, -exp)
+	req.AmountOut = json.Number(probe.String())
+	minIn, body, err := checkSwap(req)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	if res, err := model.DecodeSwapResult(body); err == nil {
+		if mid, ok := res.MidPrice(); ok {
+			return model.RatDecimal(mid), nil
+		}
+	}
+	quotedIn, err := decimal.NewFromString(minIn)
+	if err != nil || !quotedIn.IsPositive() {
+		return decimal.Zero, errors.New("swap not support")
+	}
+	return probe.DivRound(quotedIn, 18), nil
+}
+
+// withQuoteBounds bounds the swap ops by the slippage of p, the input of exact
+// output swaps rounded up and the output of exact input swaps down, and sets
+// when their quotes expire.
+func withQuoteBounds(chains *data.Snapshot, ops []model.Op, p QuotePolicy) []model.Op {
+	slippage := decimal.New(int64(p.SlippageBps), -4)
+	for _, op := range ops {
+		o, ok := op.Body.(*model.SwapResponse)
+		if !ok {
+			continue
+		}
+		swapIn, errIn := decimal.NewFromString(o.SwapIn)
+		swapOut, errOut := decimal.NewFromString(o.SwapOut)
+		if errIn != nil || errOut != nil {
+			continue
+		}
+		o.SlippageBps = p.SlippageBps
+		if o.ExactIn {
+			o.MaxAmountIn = o.SwapIn
+			precision := tokenPrecision(chains, o.ChainName, o.TargetToken)
+			o.MinAmountOut = swapOut.Mul(decimal.NewFromInt(1).Sub(slippage)).Truncate(precision).String()
+		} else {
+			precision := tokenPrecision(chains, o.ChainName, o.SourceToken)
+			o.MaxAmountIn = swapIn.Mul(decimal.NewFromInt(1).Add(slippage)).RoundUp(precision).String()
+			o.MinAmountOut = o.SwapOut
+		}
+		o.MaxAmountInRaw = rawAmount(chains, o.ChainName, o.SourceToken, o.MaxAmountIn)
+		o.MinAmountOutRaw = rawAmount(chains, o.ChainName, o.TargetToken, o.MinAmountOut)
+		if o.QuotedAt != 0 && p.TTL > 0 {
+			o.ExpiresAt = o.QuotedAt + int64(p.TTL/time.Second)
+		}
+	}
+	return ops
+}
+
+// Requote quotes again the swaps of ops whose quote expired, or all of them
+// with force, bounds them with the policy of ctx, encodes them for sender and
+// lists the fees of ops. Ops are updated in place; the steps requoted are
+// returned.
+func Requote(ctx context.Context, chains *data.Snapshot, sender string, ops []model.Op, force bool) ([]int, error) {
+	now := time.Now().Unix()
+	requoted := make([]int, 0)
+	for i, op := range ops {
+		o, ok := op.Body.(*model.SwapResponse)
+		if !ok || !force && o.ExpiresAt > now {
+			continue
+		}
+		step := o.Step
+		if step == 0 {
+			step = i + 1
+		}
+		if err := requoteSwap(ctx, chains, o); err != nil {
+			return nil, errors.Wrapf(err, "requote step %d", step)
+		}
+		requoted = append(requoted, step)
+	}
+	withCalldata(chains, sender, withQuoteBounds(chains, withRawAmounts(chains, withFees(chains, ops)), quotePolicy(ctx)))
+	return requoted, nil
+}
+
+// requoteSwap quotes o again for the same output, or for an exact input the
+// same input with a new estimate of the output, dropping the alternatives of
+// the old quote.
+func requoteSwap(ctx context.Context, chains *data.Snapshot, o *model.SwapResponse) error {
+	id, err := chains.ChainID(o.ChainName)
+	if err != nil {
+		return err
+	}
+	in, err := chains.Token(o.ChainName, o.SourceToken)
+	if err != nil {
+		return err
+	}
+	out, err := chains.Token(o.ChainName, o.TargetToken)
+	if err != nil {
+		return err
+	}
+	req := model.SwapReq{ChainId: id, TokenInAddress: in.Address, TokenOutAddress: out.Address}
+	var (
+		swapIn decimal.Decimal
+		body   []byte
+	)
+	if o.ExactIn {
+		if swapIn, err = decimal.NewFromString(o.SwapIn); err != nil {
+			return errors.Wrap(err, "invalid swap amount")
+		}
+		swapOut, quoted, err := quoteExactIn(chains, req, o.ChainName, o.SourceToken, o.TargetToken, swapIn)
+		if err != nil {
+			return err
+		}
+		o.SwapOut, o.EstimatedOut, body = swapOut.String(), true, quoted
+	} else {
+		if _, err = decimal.NewFromString(o.SwapOut); err != nil {
+			return errors.Wrap(err, "invalid swap amount")
+		}
+		req.AmountOut = json.Number(o.SwapOut)
+		minIn, quoted, err := checkSwap(req)
+		if err != nil {
+			return err
+		}
+		if swapIn, err = decimal.NewFromString(minIn); err != nil || !swapIn.IsPositive() {
+			return errors.New("swap not support")
+		}
+		o.SwapIn, body = swapIn.String(), quoted
+	}
+	gasUSD, costUSD, priced := priceQuote(chains, o.SourceToken, swapIn, body)
+	o.RawResponse = body
+	o.GasUSD = usdString(gasUSD, gasUSD.IsPositive())
+	o.CostUSD = usdString(costUSD, priced)
+	o.Alternatives = nil
+	stampQuote(o, body)
+	Progress(ctx, model.StageSwapQuoted, *o)
+	return nil
+}
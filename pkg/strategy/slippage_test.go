@@ -0,0 +1,138 @@
+package strategy
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/smarterwallet/demand-abstraction-serv/data"
+	"github.com/smarterwallet/demand-abstraction-serv/model"
+)
+
+func TestQuoteBounds(t *testing.T) {
+	b := data.NewSnapshotBuilder(nil, nil)
+	b.AddChain("mumbai", 80001, 80001, "MATIC")
+	b.AddToken("mumbai", data.Token{Symbol: "USDC", Address: "0xusdc", Decimal: 6})
+	b.AddToken("mumbai", data.Token{Symbol: "DAI", Address: "0xdai", Decimal: 18})
+	chains := b.Build()
+
+	exactOut := &model.SwapResponse{ChainName: "mumbai", SourceToken: "DAI", TargetToken: "USDC", SwapIn: "10.1", SwapOut: "10", QuotedAt: 1000}
+	exactIn := &model.SwapResponse{ChainName: "mumbai", SourceToken: "USDC", TargetToken: "DAI", SwapIn: "3", SwapOut: "2.97", ExactIn: true}
+	withQuoteBounds(chains, []model.Op{model.NewOp(exactOut), model.NewOp(exactIn)}, QuotePolicy{SlippageBps: 50, TTL: time.Minute})
+	assert.Equal(t, []string{"10.1505", "10"}, []string{exactOut.MaxAmountIn, exactOut.MinAmountOut})
+	assert.Equal(t, []string{"10150500000000000000", "10000000"}, []string{exactOut.MaxAmountInRaw, exactOut.MinAmountOutRaw})
+	assert.Equal(t, int64(1060), exactOut.ExpiresAt)
+	assert.Equal(t, []string{"3", "2.95515"}, []string{exactIn.MaxAmountIn, exactIn.MinAmountOut})
+	assert.Equal(t, 50, exactIn.SlippageBps)
+	assert.Zero(t, exactIn.ExpiresAt)
+
+	defer func(check func(model.SwapReq) (string, []byte, error)) { checkSwap = check }(checkSwap)
+	checkSwap = func(req model.SwapReq) (string, []byte, error) {
+		return "10.2", []byte(`{"code":200,"result":{"minInAmount":"10.2","blockNumber":{"type":"BigNumber","hex":"0x2a"}}}`), nil
+	}
+	fresh := &model.SwapResponse{Step: 1, ChainName: "mumbai", SourceToken: "DAI", TargetToken: "USDC", SwapIn: "10.1", SwapOut: "10",
+		ExpiresAt: time.Now().Add(time.Minute).Unix()}
+	expired := &model.SwapResponse{Step: 3, ChainName: "mumbai", SourceToken: "DAI", TargetToken: "USDC", SwapIn: "10.1", SwapOut: "10",
+		ExpiresAt: time.Now().Add(-time.Minute).Unix(), Alternatives: []model.SwapAlternative{{SourceToken: "WETH"}}}
+	ops := []model.Op{model.NewOp(fresh), model.NewOp(&model.CrossChainResponse{Type: model.ChainInternalTransfer, Step: 2}), model.NewOp(expired)}
+	ctx := WithQuotePolicy(context.Background(), QuotePolicy{SlippageBps: 100, TTL: time.Minute})
+	requoted, err := Requote(ctx, chains, "", ops, false)
+	assert.Nil(t, err)
+	assert.Equal(t, []int{3}, requoted)
+	assert.Equal(t, "10.1", fresh.SwapIn)
+	assert.Equal(t, []string{"10.2", "10.302"}, []string{expired.SwapIn, expired.MaxAmountIn})
+	assert.Equal(t, uint64(42), expired.BlockNumber)
+	assert.Equal(t, expired.QuotedAt+60, expired.ExpiresAt)
+	assert.Empty(t, expired.Alternatives)
+
+	requoted, err = Requote(ctx, chains, "", ops, true)
+	assert.Nil(t, err)
+	assert.Equal(t, []int{1, 3}, requoted)
+	assert.Equal(t, "10.2", fresh.SwapIn)
+
+	_, err = Requote(ctx, chains, "", []model.Op{model.NewOp(&model.SwapResponse{ChainName: "fuji", SourceToken: "DAI", TargetToken: "USDC", SwapOut: "1"})}, false)
+	assert.ErrorContains(t, err, "requote step 1")
+}
+
+func TestStampQuote(t *testing.T) {
+	// 2600 USDC buy 1 WETH against 1.04 at the mid price of the pool
+	body := []byte(`{"code":200,"result":{"minInAmount":"2600",
+		"estimatedGasUsed":{"type":"BigNumber","hex":"0x030d40"},"gasPriceWei":{"type":"BigNumber","hex":"0x3b9aca00"},
+		"route":[{"protocol":"V3","percent":100,"tradeType":1,
+			"amount":{"numerator":[660865024,931322574],"decimalScale":[660865024,931322574]},
+			"quote":{"numerator":[452516352,2],"decimalScale":[1000000]},
+			"route":{"pools":[{"token0":{"decimals":6,"address":"0xa"},"token1":{"decimals":18,"address":"0xb"},"sqrtRatioX96":[0,0,0,1280000]}],
+				"tokenPath":[{"decimals":6,"address":"0xa"},{"decimals":18,"address":"0xb"}]},
+			"tokenPath":[{"symbol":"USDC","address":"0xa"},{"symbol":"WETH","address":"0xb"}]}]}}`)
+	o := &model.SwapResponse{SourceToken: "USDC", TargetToken: "WETH", Warning: "stale"}
+	stampQuote(o, body)
+	assert.Equal(t, []string{"0.000384615384615385", "0.038462", "price impact of 3.85%"}, []string{o.ExecutionPrice, o.PriceImpact, o.Warning})
+	assert.Equal(t, []model.SwapRoute{{Protocol: "V3", Percent: 100, Path: []string{"USDC", "WETH"}}}, o.Route)
+	assert.Equal(t, uint64(200000), o.GasUsed)
+	assert.Equal(t, "0.0002", o.GasCost)
+	assert.Equal(t, ". Warning: swapping USDC has a price impact of 3.85%", opWarnings([]model.Op{model.NewOp(o)}))
+
+	stampQuote(o, []byte(`{"code":200,"result":{"minInAmount":"2600"}}`))
+	assert.Empty(t, o.Warning)
+	assert.Empty(t, o.Route)
+	assert.Empty(t, opWarnings([]model.Op{model.NewOp(o)}))
+}
+
+func TestQuoteExactIn(t *testing.T) {
+	b := data.NewSnapshotBuilder(nil, nil)
+	b.AddChain("mumbai", 80001, 80001, "MATIC")
+	b.AddToken("mumbai", data.Token{Symbol: "USDC", Address: "0xusdc", Decimal: 6})
+	b.AddToken("mumbai", data.Token{Symbol: "DAI", Address: "0xdai", Decimal: 18})
+	b.AddToken("mumbai", data.Token{Symbol: "WETH", Address: "0xweth", Decimal: 18})
+	chains := b.Build()
+
+	// the price of usdc grows with the size of the trade
+	defer func(check func(model.SwapReq) (string, []byte, error)) { checkSwap = check }(checkSwap)
+	var quoted []string
+	checkSwap = func(req model.SwapReq) (string, []byte, error) {
+		quoted = append(quoted, string(req.AmountOut))
+		out := decimal.RequireFromString(string(req.AmountOut))
+		if req.TokenInAddress == "0xweth" {
+			// a WETH is worth 2500 USDC at the mid price of the pool
+			minIn := out.Add(out.Mul(out).Div(decimal.NewFromInt(100))).Div(decimal.NewFromInt(2500)).String()
+			return minIn, []byte(`{"code":200,"result":{"minInAmount":"` + minIn + `","route":[{"percent":100,"route":{
+				"pools":[{"token0":{"decimals":6,"address":"0xusdc"},"token1":{"decimals":18,"address":"0xweth"},"sqrtRatioX96":[0,0,0,1280000]}],
+				"tokenPath":[{"decimals":18,"address":"0xweth"},{"decimals":6,"address":"0xusdc"}]}}]}}`), nil
+		}
+		minIn := out.Add(out.Mul(out).Div(decimal.NewFromInt(100))).String()
+		return minIn, []byte(`{"code":200,"result":{"minInAmount":"` + minIn + `"}}`), nil
+	}
+	st := transfer{balance: &model.CtxRequest{BaseChain: "mumbai", Balances: map[string][]model.Reserve{"mumbai": {
+		{Symbol: "DAI", Address: "0xdai", Balance: decimal.NewFromInt(20)},
+		{Symbol: "USDC", Address: "0xusdc"},
+	}}}, chains: chains}
+	resp := &model.DemandResponse{}
+	assert.Nil(t, st.Render(context.Background(), resp, "swap_token", `{"source_token":"DAI","target_token":"USDC","amount_in":10}`))
+	if assert.Len(t, resp.Detail.OPs, 1) {
+		swap := resp.Detail.OPs[0].Body.(*model.SwapResponse)
+		// 10 DAI buy 9.1608 USDC, a single quote of 10 USDC would say 9.0909
+		assert.Equal(t, []string{"10", "9.090909"}, quoted)
+		assert.Equal(t, []string{"10", "9.166666"}, []string{swap.SwapIn, swap.SwapOut})
+		assert.True(t, swap.EstimatedOut)
+	}
+	assert.Contains(t, resp.Detail.Reply, "Warning: the USDC bought is an estimate")
+
+	quoted = nil
+	_, err := Requote(context.Background(), chains, "", resp.Detail.OPs, true)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"10", "9.090909"}, quoted)
+	assert.Equal(t, "10", resp.Detail.OPs[0].Body.(*model.SwapResponse).SwapIn)
+
+	// other pairs are priced by a probe first, not by a quote of 0.004 USDC
+	st.balance.Balances["mumbai"] = append(st.balance.Balances["mumbai"], model.Reserve{Symbol: "WETH", Address: "0xweth", Balance: decimal.NewFromInt(1)})
+	quoted = nil
+	resp = &model.DemandResponse{}
+	assert.Nil(t, st.Render(context.Background(), resp, "swap_token", `{"source_token":"WETH","target_token":"USDC","amount_in":0.004}`))
+	assert.Equal(t, []string{"0.001", "10", "9.090909"}, quoted)
+	if assert.Len(t, resp.Detail.OPs, 1) {
+		assert.Equal(t, "9.166666", resp.Detail.OPs[0].Body.(*model.SwapResponse).SwapOut)
+	}
+}
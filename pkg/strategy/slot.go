@@ -0,0 +1,136 @@
+package strategy
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+
+	"github.com/sashabaranov/go-openai/jsonschema"
+	"github.com/shopspring/decimal"
+)
+
+var numberRegexp = regexp.MustCompile(`\d+(?:\.\d+)?`)
+
+// Slot is an argument the user has to provide before a function is rendered.
+type Slot struct {
+	Name     string
+	Question string
+}
+
+// SlotFiller is implemented by strategies that ask for missing arguments
+// instead of letting the llm guess them.
+type SlotFiller interface {
+	MissingSlot(name string, args map[string]interface{}) (Slot, bool)
+}
+
+// MissingSlot decodes the arguments of a call and reports the first slot the
+// user still has to fill.
+func MissingSlot(st IStrategy, name, args string) (Slot, map[string]interface{}, bool) {
+	in, err := DecodeArgs(args)
+	if err != nil {
+		return Slot{}, in, false
+	}
+	filler, ok := st.(SlotFiller)
+	if !ok {
+		return Slot{}, in, false
+	}
+	slot, missing := filler.MissingSlot(name, in)
+	return slot, in, missing
+}
+
+// FillSlot stores a bare answer such as "0x..." or "30" as the value of slot,
+// converted to the type the function schema declares.
+func FillSlot(st IStrategy, name string, args map[string]interface{}, slot, answer string) bool {
+	answer = strings.TrimSpace(strings.TrimRight(strings.TrimSpace(answer), ".!"))
+	if answer == "" {
+		return false
+	}
+	switch slotType(st, name, slot) {
+	case jsonschema.Number, jsonschema.Integer:
+		m := numberRegexp.FindString(answer)
+		if m == "" {
+			return false
+		}
+		args[slot] = json.Number(m)
+	case jsonschema.String:
+		if len(strings.Fields(answer)) > 3 {
+			return false
+		}
+		args[slot] = answer
+	default:
+		return false
+	}
+	return true
+}
+
+// SlotFilled reports whether the arguments of a call hold a value for slot
+// that the strategy doesn't ask for again.
+func SlotFilled(st IStrategy, name, args, slot string) bool {
+	missingSlot, in, missing := MissingSlot(st, name, args)
+	return hasValue(in[slot]) && !(missing && missingSlot.Name == slot)
+}
+
+// MergeArgs overlays the non-empty values of update onto base.
+func MergeArgs(base, update map[string]interface{}) map[string]interface{} {
+	merged := make(map[string]interface{}, len(base)+len(update))
+	for k, v := range base {
+		merged[k] = v
+	}
+	for k, v := range update {
+		if hasValue(v) {
+			merged[k] = v
+		}
+	}
+	return merged
+}
+
+func firstMissing(slots []Slot, args map[string]interface{}) (Slot, bool) {
+	for _, slot := range slots {
+		if !hasValue(args[slot.Name]) {
+			return slot, true
+		}
+	}
+	return Slot{}, false
+}
+
+func hasValue(v interface{}) bool {
+	switch value := v.(type) {
+	case nil:
+		return false
+	case string:
+		return strings.TrimSpace(value) != ""
+	case float64:
+		return value != 0
+	case json.Number:
+		d, err := decimal.NewFromString(value.String())
+		return err == nil && !d.IsZero()
+	}
+	return true
+}
+
+// DecodeArgs decodes call arguments keeping numbers as json.Number, so token
+// amounts keep every decimal they were given with.
+func DecodeArgs(args string) (map[string]interface{}, error) {
+	in := make(map[string]interface{})
+	if strings.TrimSpace(args) == "" {
+		return in, nil
+	}
+	dec := json.NewDecoder(strings.NewReader(args))
+	dec.UseNumber()
+	if err := dec.Decode(&in); err != nil {
+		return make(map[string]interface{}), err
+	}
+	return in, nil
+}
+
+func slotType(st IStrategy, name, slot string) jsonschema.DataType {
+	for _, function := range st.Functions() {
+		if function.Name != name {
+			continue
+		}
+		if params, ok := function.Parameters.(jsonschema.Definition); ok {
+			return params.Properties[slot].Type
+		}
+	}
+	return ""
+}
@@ -0,0 +1,106 @@
+package strategy
+
+import (
+	"sort"
+
+	log "github.com/cihub/seelog"
+	"github.com/shopspring/decimal"
+
+	"github.com/smarterwallet/demand-abstraction-serv/model"
+)
+
+// swapPlan is the swaps buying an amount of a token on one chain.
+type swapPlan struct {
+	Chain string
+	Swaps []model.SwapResponse
+	// CostUSD sums the cost of the swaps, known when Priced.
+	CostUSD decimal.Decimal
+	Priced  bool
+}
+
+// better reports whether p costs less than o: priced plans by cost, then the
+// plan with fewer swaps.
+func (p swapPlan) better(o swapPlan) bool {
+	if p.Priced != o.Priced {
+		return p.Priced
+	}
+	if p.Priced {
+		if c := p.CostUSD.Cmp(o.CostUSD); c != 0 {
+			return c < 0
+		}
+	}
+	return len(p.Swaps) < len(o.Swaps)
+}
+
+// planSwaps buys amountOut of outToken on chain with the other reserves held
+// there: the cheapest one covering it alone or a split over several, whichever
+// costs less.
+func (t transfer) planSwaps(chain, outToken string, amountOut decimal.Decimal) (swapPlan, bool) {
+	id, err := t.chains.ChainID(chain)
+	if err != nil {
+		log.Errorf("get chain id error: %v", err)
+		return swapPlan{}, false
+	}
+	quotes := t.quoteSwaps(id, t.swapPairs(chain, outToken), chain, outToken, amountOut)
+	var (
+		plan swapPlan
+		ok   bool
+	)
+	if single := covered(quotes); len(single) > 0 {
+		plan = swapPlan{
+			Chain:   chain,
+			Swaps:   []model.SwapResponse{swapOp(id, chain, outToken, amountOut, single[0], single[1:])},
+			CostUSD: single[0].CostUSD,
+			Priced:  single[0].Priced,
+		}
+		ok = true
+	}
+	if split, splitOk := t.splitSwaps(id, chain, outToken, amountOut, quotes); splitOk && (!ok || split.better(plan)) {
+		plan, ok = split, true
+	}
+	return plan, ok
+}
+
+// splitSwaps spreads amountOut over several reserves, those paying least per
+// output first. Each reserve buys what its balance affords at the rate of its
+// quote for the whole amount; the parts are quoted again before use.
+func (t transfer) splitSwaps(chainId int, chain, outToken string, amountOut decimal.Decimal, quotes []swapQuote) (swapPlan, bool) {
+	order := append([]swapQuote(nil), quotes...)
+	sort.SliceStable(order, func(i, j int) bool {
+		if order[i].Priced != order[j].Priced {
+			return order[i].Priced
+		}
+		return order[i].Priced && order[i].CostUSD.Sub(order[i].GasUSD).Cmp(order[j].CostUSD.Sub(order[j].GasUSD)) < 0
+	})
+	precision := tokenPrecision(t.chains, chain, outToken)
+	var (
+		reserves  []model.Reserve
+		amounts   []decimal.Decimal
+		remaining = amountOut
+	)
+	for _, q := range order {
+		if !remaining.IsPositive() {
+			break
+		}
+		out := decimal.Min(amountOut.Mul(q.Balance).DivRound(q.AmountIn, precision+1).Truncate(precision), remaining)
+		if !out.IsPositive() {
+			continue
+		}
+		reserves = append(reserves, q.Reserve())
+		amounts = append(amounts, out)
+		remaining = remaining.Sub(out)
+	}
+	if remaining.IsPositive() || len(reserves) < 2 {
+		return swapPlan{}, false
+	}
+	plan := swapPlan{Chain: chain, Priced: true}
+	for i, q := range t.quoteEach(chainId, reserves, chain, outToken, amounts) {
+		if q == nil || !q.Covered() {
+			return swapPlan{}, false
+		}
+		plan.Swaps = append(plan.Swaps, swapOp(chainId, chain, outToken, amounts[i], *q, nil))
+		plan.CostUSD = plan.CostUSD.Add(q.CostUSD)
+		plan.Priced = plan.Priced && q.Priced
+	}
+	return plan, true
+}
@@ -1,10 +1,14 @@
 package strategy
 
 import (
+	"context"
 	"fmt"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 
+	"github.com/smarterwallet/demand-abstraction-serv/data"
 	"github.com/smarterwallet/demand-abstraction-serv/model"
 
 	"github.com/pkg/errors"
@@ -15,7 +19,7 @@ import (
 type IStrategy interface {
 	Prompt() string
 	Functions() []openai.FunctionDefinition
-	Render(resp *model.DemandResponse, name, args string) error
+	Render(ctx context.Context, resp *model.DemandResponse, name, args string) error
 }
 
 var (
@@ -25,24 +29,68 @@ var (
 	_                     IStrategy = &crossChain{}
 	_                     IStrategy = &chainAbstraction{}
 	_                     IStrategy = &selectStrategy{}
-	strategy                        = map[string]IStrategy{
-		"trade2Earn":            trade2Earn{},
-		"transfer":              transfer{},
-		"crossChain":            crossChain{},
-		"crossChainAbstraction": chainAbstraction{},
-		"selectStrategy":        selectStrategy{},
-	}
 )
 
-func MatchStrategy(category string, ctx *model.CtxRequest) (IStrategy, error) {
-	if category == "transfer" {
-		return transfer{balance: ctx}, nil
+// Factory builds a strategy for the conversation context of one demand, looking
+// chains and tokens up in chains.
+type Factory func(ctx *model.CtxRequest, chains *data.Snapshot) IStrategy
+
+// Definition describes a registered strategy. Strategies with a description
+// are offered by the strategy selector; the others are only used internally.
+// Strategies with intent rules may also be picked by Classify.
+type Definition struct {
+	Name        string
+	Description string
+	Factory     Factory
+	Intent      IntentRules
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]Definition)
+)
+
+// Register makes a strategy available under def.Name, replacing any previous one.
+func Register(def Definition) {
+	if def.Name == "" || def.Factory == nil {
+		panic("strategy: Register with empty name or nil factory")
+	}
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[def.Name] = def
+}
+
+// Registered returns the registered strategies sorted by name.
+func Registered() []Definition {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	defs := make([]Definition, 0, len(registry))
+	for _, def := range registry {
+		defs = append(defs, def)
 	}
-	st, ok := strategy[category]
+	sort.Slice(defs, func(i, j int) bool { return defs[i].Name < defs[j].Name })
+	return defs
+}
+
+// selectable returns the strategies offered by the strategy selector.
+func selectable() []Definition {
+	var defs []Definition
+	for _, def := range Registered() {
+		if def.Description != "" {
+			defs = append(defs, def)
+		}
+	}
+	return defs
+}
+
+func MatchStrategy(category string, ctx *model.CtxRequest, chains *data.Snapshot) (IStrategy, error) {
+	registryMu.RLock()
+	def, ok := registry[category]
+	registryMu.RUnlock()
 	if !ok {
 		return nil, errors.New("strategy not support")
 	}
-	return st, nil
+	return def.Factory(ctx, chains), nil
 }
 
 func percentStr2Decimal(percentageStr string) string {
@@ -55,16 +103,33 @@ func percentStr2Decimal(percentageStr string) string {
 	return fmt.Sprintf("%.2f", decimal)
 }
 
+func init() {
+	Register(Definition{
+		Name:    "selectStrategy",
+		Factory: func(ctx *model.CtxRequest, chains *data.Snapshot) IStrategy { return selectStrategy{} },
+	})
+}
+
+// selectStrategy chooses among the strategies registered with a description.
 type selectStrategy struct{}
 
 func (s selectStrategy) Prompt() string {
-	return `As an experienced cryptocurrency investor, I'd like you to analyze user's operations. 
-	Based on these, choose the most suitable strategy from the two available options: trade2Earn or transfer.
-	If blockchain chains are detected in user's demand, such as token transfers among Ethereum, Goerli, Fuji etc, the strategy is transfer.	
-	trade2Earn focuses on user's financial investments such as High/Low Return expectations.`
+	defs := selectable()
+	options := make([]string, 0, len(defs))
+	for _, def := range defs {
+		options = append(options, fmt.Sprintf("- %s: %s", def.Name, def.Description))
+	}
+	return fmt.Sprintf(`As an experienced cryptocurrency investor, I'd like you to analyze user's operations. 
+	Based on these, choose the most suitable strategy from the %d available options:
+%s`, len(defs), strings.Join(options, "\n"))
 }
 
 func (s selectStrategy) Functions() []openai.FunctionDefinition {
+	defs := selectable()
+	names := make([]string, 0, len(defs))
+	for _, def := range defs {
+		names = append(names, def.Name)
+	}
 	return []openai.FunctionDefinition{{
 		Name: "select_strategy",
 		Parameters: jsonschema.Definition{
@@ -72,7 +137,8 @@ func (s selectStrategy) Functions() []openai.FunctionDefinition {
 			Properties: map[string]jsonschema.Definition{
 				"strategy": {
 					Type:        jsonschema.String,
-					Description: "The matched strategy, e.g. transfer, trade2Earn",
+					Description: "The matched strategy, e.g. " + strings.Join(names, ", "),
+					Enum:        names,
 				},
 			},
 			Required: []string{"strategy"},
@@ -80,6 +146,6 @@ func (s selectStrategy) Functions() []openai.FunctionDefinition {
 	}}
 }
 
-func (s selectStrategy) Render(resp *model.DemandResponse, name, args string) error {
+func (s selectStrategy) Render(ctx context.Context, resp *model.DemandResponse, name, args string) error {
 	return nil
 }
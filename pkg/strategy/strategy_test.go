@@ -0,0 +1,80 @@
+package strategy
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/sashabaranov/go-openai"
+	"github.com/sashabaranov/go-openai/jsonschema"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/smarterwallet/demand-abstraction-serv/data"
+	"github.com/smarterwallet/demand-abstraction-serv/model"
+)
+
+// testChains returns a snapshot of mumbai and fuji with their usdc.
+func testChains(t *testing.T) *data.Snapshot {
+	aliases, err := data.LoadChainAliases("")
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+	tokens, err := data.LoadTokenAliases("")
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+	b := data.NewSnapshotBuilder(aliases, tokens)
+	b.AddChain("mumbai", 80001, 80001, "MATIC")
+	b.AddChain("fuji", 43113, 43113, "AVAX")
+	b.AddToken("mumbai", data.Token{Symbol: "USDC", Address: "0x9999f7fea5938fd3b1e26a12c3f2fdd5c2e0f9a1", Decimal: 6})
+	b.AddToken("fuji", data.Token{Symbol: "USDC", Address: "0x5425890298aed601595a70ab815c96711a31bc65", Decimal: 6})
+	return data.NewRegistry().Publish(b)
+}
+
+type staking struct {
+	address string
+}
+
+func (s staking) Prompt() string                         { return "stake for " + s.address }
+func (s staking) Functions() []openai.FunctionDefinition { return nil }
+func (s staking) Render(ctx context.Context, resp *model.DemandResponse, name, args string) error {
+	return nil
+}
+
+func TestRegister(t *testing.T) {
+	Register(Definition{
+		Name:        "staking",
+		Description: "staking tokens for rewards",
+		Factory:     func(ctx *model.CtxRequest, chains *data.Snapshot) IStrategy { return staking{address: ctx.Address} },
+		Intent:      IntentRules{Rules: []IntentRule{{3, regexp.MustCompile(`(?i)\bstak(e|ing)\b`)}}},
+	})
+	defer func() {
+		registryMu.Lock()
+		delete(registry, "staking")
+		registryMu.Unlock()
+	}()
+
+	st, err := MatchStrategy("staking", &model.CtxRequest{Address: "0xabc"}, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "stake for 0xabc", st.Prompt())
+
+	selector, err := MatchStrategy("selectStrategy", nil, nil)
+	assert.Nil(t, err)
+	assert.Contains(t, selector.Prompt(), "- staking: staking tokens for rewards")
+	assert.Contains(t, selector.Prompt(), "- transfer: ")
+	assert.NotContains(t, selector.Prompt(), "crossChain")
+	params := selector.Functions()[0].Parameters.(jsonschema.Definition)
+	assert.Equal(t, []string{"staking", "trade2Earn", "transfer"}, params.Properties["strategy"].Enum)
+
+	chains := testChains(t)
+	st, err = MatchStrategy("transfer", &model.CtxRequest{BaseChain: "mumbai"}, chains)
+	assert.Nil(t, err)
+	assert.Equal(t, transfer{balance: &model.CtxRequest{BaseChain: "mumbai"}, chains: chains}, st)
+
+	guess := Classify(chains, "stake my tokens")
+	assert.Equal(t, "staking", guess.Category)
+	assert.GreaterOrEqual(t, guess.Confidence, DefaultIntentThreshold)
+
+	_, err = MatchStrategy("unknown", nil, nil)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,61 @@
+package strategy
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/smarterwallet/demand-abstraction-serv/data"
+	"github.com/smarterwallet/demand-abstraction-serv/model"
+)
+
+// chainArg returns the loaded name of the first chain of args given by names, or
+// fallback when none is.
+func chainArg(chains *data.Snapshot, args map[string]interface{}, fallback string, names ...string) string {
+	for _, name := range names {
+		if s, _ := args[name].(string); s != "" {
+			return canonicalChain(chains, s)
+		}
+	}
+	return fallback
+}
+
+// tokenQuestion asks which of the tokens an ambiguous symbol stands for is meant.
+func tokenQuestion(err *data.AmbiguousTokenError) string {
+	return fmt.Sprintf("%s could be several tokens on %s: %s. Which one do you mean?", err.Symbol, err.Chain, err.List())
+}
+
+// ambiguousToken reports the slot of the first token of args, given by names,
+// standing for several tokens on chain.
+func ambiguousToken(chains *data.Snapshot, args map[string]interface{}, chain string, names ...string) (Slot, bool) {
+	for _, name := range names {
+		symbol, _ := args[name].(string)
+		if symbol == "" {
+			continue
+		}
+		var ambiguous *data.AmbiguousTokenError
+		if _, err := chains.ResolveToken(chain, symbol); errors.As(err, &ambiguous) {
+			return Slot{Name: name, Question: tokenQuestion(ambiguous)}, true
+		}
+	}
+	return Slot{}, false
+}
+
+// resolveToken replaces token with the symbol of the loaded token it stands for
+// on chain, uppercased like the balances. Unknown tokens are only uppercased;
+// for an ambiguous one resp gets a question listing the candidates and false is
+// returned.
+func resolveToken(chains *data.Snapshot, resp *model.DemandResponse, chain string, token *string) bool {
+	resolved, err := chains.ResolveToken(chain, *token)
+	var ambiguous *data.AmbiguousTokenError
+	switch {
+	case errors.As(err, &ambiguous):
+		resp.Detail = model.DetailResp{Reply: tokenQuestion(ambiguous)}
+		return false
+	case err == nil:
+		*token = strings.ToUpper(resolved.Symbol)
+	default:
+		*token = strings.ToUpper(strings.TrimSpace(*token))
+	}
+	return true
+}
@@ -0,0 +1,52 @@
+package strategy
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/smarterwallet/demand-abstraction-serv/data"
+	"github.com/smarterwallet/demand-abstraction-serv/model"
+)
+
+func TestAmbiguousToken(t *testing.T) {
+	aliases, _ := data.LoadChainAliases("")
+	tokens, _ := data.LoadTokenAliases("")
+	b := data.NewSnapshotBuilder(aliases, tokens)
+	b.AddChain("mumbai", 80001, 80001, "MATIC")
+	b.AddChain("fuji", 43113, 43113, "AVAX")
+	b.AddToken("mumbai", data.Token{Symbol: "USDC.e", Address: "0x9999f7fea5938fd3b1e26a12c3f2fdd5c2e0f9a1", Decimal: 6})
+	b.AddToken("mumbai", data.Token{Symbol: "axlUSDC", Address: "0x8888f7fea5938fd3b1e26a12c3f2fdd5c2e0f9a1", Decimal: 6})
+	b.AddToken("mumbai", data.Token{Symbol: "WETH", Address: "0x7777f7fea5938fd3b1e26a12c3f2fdd5c2e0f9a1", Decimal: 18})
+	chains := data.NewRegistry().Publish(b)
+	st := crossChain{chains: chains}
+
+	args := `{"source_chain":"mumbai","target_chain":"fuji","token":"usdc","amount":1,"receiver":"0xd8da6bf26964af9d7eed9e03e53415d37aa96045"}`
+	slot, _, missing := MissingSlot(st, "cross_chain_analyze", args)
+	assert.True(t, missing)
+	assert.Equal(t, "token", slot.Name)
+	assert.Contains(t, slot.Question, "USDC.e (0x9999f7fea5938fd3b1e26a12c3f2fdd5c2e0f9a1)")
+	assert.Contains(t, slot.Question, "axlUSDC (0x8888f7fea5938fd3b1e26a12c3f2fdd5c2e0f9a1)")
+
+	resp := &model.DemandResponse{}
+	assert.Nil(t, st.Render(context.Background(), resp, "cross_chain_analyze", args))
+	assert.Empty(t, resp.Detail.OPs)
+	assert.Contains(t, resp.Detail.Reply, "Which one do you mean?")
+
+	args = `{"source_chain":"Polygon Mumbai","target_chain":"fuji","token":"usdc.e","amount":1,"receiver":"0xd8da6bf26964af9d7eed9e03e53415d37aa96045"}`
+	_, _, missing = MissingSlot(st, "cross_chain_analyze", args)
+	assert.False(t, missing)
+	resp = &model.DemandResponse{}
+	assert.Nil(t, st.Render(context.Background(), resp, "cross_chain_analyze", args))
+	if assert.Len(t, resp.Detail.OPs, 1) {
+		op := resp.Detail.OPs[0].Body.(*model.CrossChainResponse)
+		assert.Equal(t, "USDC.E", op.Token)
+		assert.Equal(t, "mumbai", op.SourceChainName)
+	}
+
+	swap := transfer{balance: &model.CtxRequest{BaseChain: "mumbai"}, chains: chains}
+	slot, _, missing = MissingSlot(swap, "swap_token", `{"source_token":"ETH","target_token":"USDC","amount_in":1}`)
+	assert.True(t, missing)
+	assert.Equal(t, "target_token", slot.Name)
+}
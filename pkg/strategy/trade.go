@@ -1,12 +1,30 @@
 package strategy
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
+	"regexp"
+
 	"github.com/sashabaranov/go-openai"
 	"github.com/sashabaranov/go-openai/jsonschema"
+	"github.com/smarterwallet/demand-abstraction-serv/data"
 	"github.com/smarterwallet/demand-abstraction-serv/model"
 )
 
+func init() {
+	Register(Definition{
+		Name:        "trade2Earn",
+		Description: "financial investments such as High/Low return expectations",
+		Factory:     func(ctx *model.CtxRequest, chains *data.Snapshot) IStrategy { return trade2Earn{} },
+		Intent: IntentRules{Rules: []IntentRule{
+			{3, regexp.MustCompile(`(?i)\b(invest(ment|ing)?|earn(ing)?|returns?|yield|profit|apy|apr|grid|bot|portfolio)\b`)},
+			{2, regexp.MustCompile(`(?i)\b(risk|high|low|conservative|aggressive|passive income)\b`)},
+			{2, regexp.MustCompile(`\d+(\.\d+)?\s*%`)},
+		}},
+	})
+}
+
 type trade2Earn struct{}
 
 type trade2EarnArgs struct {
@@ -44,7 +62,17 @@ func (t trade2Earn) Functions() []openai.FunctionDefinition {
 	}}
 }
 
-func (t trade2Earn) Render(resp *model.DemandResponse, name, args string) error {
+func (t trade2Earn) CheckArgs(name string, args map[string]interface{}) []string {
+	var problems []string
+	for _, key := range []string{"minimum", "maximum"} {
+		if v, ok := args[key].(string); ok && percentStr2Decimal(v) == "" {
+			problems = append(problems, fmt.Sprintf("%s must be a percentage such as 6%%, got %q", key, v))
+		}
+	}
+	return problems
+}
+
+func (t trade2Earn) Render(ctx context.Context, resp *model.DemandResponse, name, args string) error {
 	if name == "get_trade_to_earn_strategy" {
 		in := trade2EarnArgs{}
 		if err := json.Unmarshal([]byte(args), &in); err != nil {
@@ -54,8 +82,8 @@ func (t trade2Earn) Render(resp *model.DemandResponse, name, args string) error
 		resp.Category = "trade2Earn"
 		resp.Detail = model.DetailResp{
 			Reply: "",
-			OPs: []interface{}{
-				model.TradeStrategyResponse{
+			OPs: []model.Op{
+				model.NewOp(&model.TradeStrategyResponse{
 					BotName:   "Recommended strategy One-time decentralized automated trading botStrategy",
 					Strategy:  "Simple spot grid",
 					MinReturn: percentStr2Decimal(in.Minimum),
@@ -92,7 +120,7 @@ func (t trade2Earn) Render(resp *model.DemandResponse, name, args string) error
 							},
 						},
 					},
-				},
+				}),
 			},
 		}
 		return nil
@@ -1,42 +1,137 @@
 package strategy
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"regexp"
 	"strings"
 
 	"github.com/shopspring/decimal"
 
 	"github.com/smarterwallet/demand-abstraction-serv/data"
 
-	"github.com/smarterwallet/demand-abstraction-serv/pkg"
-
 	log "github.com/cihub/seelog"
 
 	"github.com/sashabaranov/go-openai"
 	"github.com/sashabaranov/go-openai/jsonschema"
 	"github.com/smarterwallet/demand-abstraction-serv/model"
-	"github.com/smarterwallet/demand-abstraction-serv/utils"
 )
 
 type transferArgs struct {
-	SourceChain string  `json:"source_chain"`
-	Token       string  `json:"token"`
-	Amount      float64 `json:"amount"`
-	AmtDecimal  decimal.Decimal
-	Receiver    string `json:"receiver"`
-	TargetChain string `json:"target_chain"`
-	IsUsd       bool   `json:"is_usd"`
+	SourceChain string          `json:"source_chain"`
+	Token       string          `json:"token"`
+	Amount      decimal.Decimal `json:"amount"`
+	Receiver    string          `json:"receiver"`
+	TargetChain string          `json:"target_chain"`
+	IsUsd       bool            `json:"is_usd"`
+}
+
+type swapArgs struct {
+	Chain       string          `json:"chain"`
+	SourceToken string          `json:"source_token"`
+	TargetToken string          `json:"target_token"`
+	AmountIn    decimal.Decimal `json:"amount_in"`
+	AmountOut   decimal.Decimal `json:"amount_out"`
+}
+
+func init() {
+	Register(Definition{
+		Name:        "transfer",
+		Description: "token transfers, swaps and bridging, e.g. when blockchain chains such as Ethereum, Goerli or Fuji are mentioned",
+		Factory: func(ctx *model.CtxRequest, chains *data.Snapshot) IStrategy {
+			return transfer{balance: ctx, chains: chains}
+		},
+		Intent: IntentRules{
+			Rules: []IntentRule{
+				{3, regexp.MustCompile(`(?i)\b(transfer|send|pay|bridge|swap|exchange|convert|move|withdraw|deposit)\b`)},
+				{3, regexp.MustCompile(`0x[0-9a-fA-F]{40}`)},
+				{1, regexp.MustCompile(`\b\d+(\.\d+)?\s*[A-Z]{2,6}(\.e)?\b`)},
+			},
+			ChainWeight: 1,
+			RouteWeight: 2,
+		},
+	})
 }
 
 type transfer struct {
 	balance *model.CtxRequest
+	chains  *data.Snapshot
+}
+
+var (
+	transferSlots = []Slot{
+		{Name: "amount", Question: "How much would you like to transfer?"},
+		{Name: "token", Question: "Which token would you like to transfer?"},
+		{Name: "receiver", Question: "Which address should receive the transfer?"},
+	}
+	swapSlots = []Slot{
+		{Name: "source_token", Question: "Which token would you like to swap from?"},
+		{Name: "target_token", Question: "Which token would you like to swap to?"},
+	}
+	swapAmountSlot = Slot{Name: "amount_in", Question: "How much would you like to swap?"}
+)
+
+func (t transfer) MissingSlot(name string, args map[string]interface{}) (Slot, bool) {
+	switch name {
+	case "get_trade_strategy":
+		slots := transferSlots
+		if isUsd, _ := args["is_usd"].(bool); isUsd {
+			slots = []Slot{transferSlots[0], transferSlots[2]}
+		}
+		if slot, ok := firstMissing(slots, args); ok {
+			return slot, true
+		}
+		if isUsd, _ := args["is_usd"].(bool); !isUsd {
+			if slot, ok := ambiguousToken(t.chains, args, t.balance.BaseChain, "token"); ok {
+				return slot, true
+			}
+		}
+		return invalidReceiver(t.chains, args, "target_chain", "source_chain")
+	case "swap_token":
+		if slot, ok := firstMissing(swapSlots, args); ok {
+			return slot, true
+		}
+		if !hasValue(args["amount_in"]) && !hasValue(args["amount_out"]) {
+			return swapAmountSlot, true
+		}
+		return ambiguousToken(t.chains, args, chainArg(t.chains, args, t.balance.BaseChain, "chain"), "source_token", "target_token")
+	}
+	return Slot{}, false
+}
+
+func (t transfer) CheckArgs(name string, args map[string]interface{}) []string {
+	switch name {
+	case "get_trade_strategy":
+		problems := checkAmounts(args, "amount")
+		problems = append(problems, checkAddresses(args, "receiver")...)
+		return append(problems, checkChains(t.chains, args, "source_chain", "target_chain")...)
+	case "swap_token":
+		problems := checkAmounts(args, "amount_in", "amount_out")
+		if source, _ := args["source_token"].(string); source != "" && strings.EqualFold(source, fmt.Sprint(args["target_token"])) {
+			problems = append(problems, "source_token and target_token must differ")
+		}
+		return append(problems, checkChains(t.chains, args, "chain")...)
+	}
+	return nil
+}
+
+func (t transfer) TracedArgs(name string, args map[string]interface{}) map[string]string {
+	if name != "get_trade_strategy" {
+		return nil
+	}
+	traced := map[string]string{"amount": TraceNumber, "receiver": TraceAddress}
+	if isUsd, _ := args["is_usd"].(bool); !isUsd {
+		traced["token"] = TraceSymbol
+	}
+	return traced
 }
 
 func (t transfer) Prompt() string {
 	return fmt.Sprintf(`As a seasoned cryptocurrency researcher, your task is to analyze cross-chain transfer demands. transfer token from chain:%s.
 Focus on identifying key elements in the transactions, particularly noting the source and target chains involved.
-If the user explicitly mentions that the transfer is in US dollars but not stable coins, set is_usd true`, t.balance.BaseChain)
+If the user explicitly mentions that the transfer is in US dollars but not stable coins, set is_usd true.
+If the user asks for several operations, such as a swap followed by a transfer, call one function per operation in the order given.`, t.balance.BaseChain)
 }
 
 func (t transfer) Functions() []openai.FunctionDefinition {
@@ -60,7 +155,7 @@ func (t transfer) Functions() []openai.FunctionDefinition {
 					},
 					"receiver": {
 						Type:        jsonschema.String,
-						Description: "The receiver address, e.g. 0xd8da6bf26964af9d7eed9e03e53415d37aa96045",
+						Description: "The receiver address, or the contact name the user gave, e.g. 0xd8da6bf26964af9d7eed9e03e53415d37aa96045 or Alice",
 					},
 					"target_chain": {
 						Type:        jsonschema.String,
@@ -74,10 +169,47 @@ func (t transfer) Functions() []openai.FunctionDefinition {
 				Required: []string{"source_chain", "token", "amount", "receiver", "target_chain", "is_usd"},
 			},
 		},
+		{
+			Name: "swap_token",
+			Parameters: jsonschema.Definition{
+				Type: jsonschema.Object,
+				Properties: map[string]jsonschema.Definition{
+					"chain": {
+						Type:        jsonschema.String,
+						Description: "The blockchain name to swap on, e.g. Ethereum Mainnet",
+					},
+					"source_token": {
+						Type:        jsonschema.String,
+						Description: "The token to swap from, e.g. DAI",
+					},
+					"target_token": {
+						Type:        jsonschema.String,
+						Description: "The token to swap to, e.g. USDC",
+					},
+					"amount_in": {
+						Type:        jsonschema.Number,
+						Description: "The amount of source token to spend, e.g. 50",
+					},
+					"amount_out": {
+						Type:        jsonschema.Number,
+						Description: "The amount of target token to receive, only when the user asks for an exact output, e.g. 20",
+					},
+				},
+				Required: []string{"source_token", "target_token"},
+			},
+		},
 	}
 }
 
-func (t transfer) Render(resp *model.DemandResponse, name, args string) error {
+func (t transfer) Render(ctx context.Context, resp *model.DemandResponse, name, args string) error {
+	if name == "swap_token" {
+		in := swapArgs{}
+		if err := json.Unmarshal([]byte(args), &in); err != nil {
+			return err
+		}
+		t.swap(ctx, in, resp)
+		return nil
+	}
 	if name != "get_trade_strategy" {
 		return ErrFunctionNotDefined
 	}
@@ -85,191 +217,123 @@ func (t transfer) Render(resp *model.DemandResponse, name, args string) error {
 	if err := json.Unmarshal([]byte(args), &in); err != nil {
 		return err
 	}
-	in.AmtDecimal = decimal.NewFromFloat(in.Amount)
 	// 1. is usd
 	if in.IsUsd {
 		// todo choose stable usd coin
 		in.Token = "USDC"
 	}
-	in.SourceChain = strings.ToLower(in.SourceChain)
-	in.TargetChain = strings.ToLower(in.TargetChain)
-	in.Token = strings.ToUpper(in.Token)
+	in.SourceChain = canonicalChain(t.chains, in.SourceChain)
+	in.TargetChain = canonicalChain(t.chains, in.TargetChain)
 	if in.SourceChain != t.balance.BaseChain {
 		log.Warnf("unexpected source chain: %s", in.SourceChain)
 		in.SourceChain = t.balance.BaseChain
 	}
-	// 1. internal
-	if in.SourceChain == in.TargetChain || in.TargetChain == "" {
-		t.internalTransfer(in, resp)
+	if !resolveToken(t.chains, resp, in.SourceChain, &in.Token) {
 		return nil
 	}
-	// 2. cross chain: swap on source chain first
-	potentialSwapPairs := make(map[model.Reserve]struct{})
-	tokens := t.balance.GetTokens(in.SourceChain)
-	for _, token := range tokens {
-		if token.Symbol == in.Token {
-			continue
-		}
-		potentialSwapPairs[token] = struct{}{}
-	}
-	currTokenBalance := t.balance.GetTokenBalance(in.SourceChain, in.Token)
-	targetTokenBalance := t.balance.GetTokenBalance(in.TargetChain, in.Token)
-	var (
-		swapOp model.SwapResponse
-		ok     bool
-	)
-	if currTokenBalance.Add(targetTokenBalance).Cmp(in.AmtDecimal) < 0 {
-		// need to swap
-		swapOp, ok = t.potentialSwap(potentialSwapPairs, in.SourceChain, in.Token, in.AmtDecimal.Sub(targetTokenBalance))
-		if !ok {
-			resp.Detail = model.DetailResp{
-				Reply: "swap not support",
-				OPs:   nil,
-			}
-			return nil
-		}
+	receiverChain := in.TargetChain
+	if receiverChain == "" {
+		receiverChain = in.SourceChain
 	}
-	crossArgs := crossChainAbstractionArgs{
-		Token:                          in.Token,
-		SourceChain:                    in.SourceChain,
-		SourceChainTokenBalanceDecimal: t.balance.GetTokenBalance(in.SourceChain, in.Token).Add(in.AmtDecimal),
-		TargetChain:                    in.TargetChain,
-		TargetChainTokenBalanceDecimal: targetTokenBalance,
-		TransferAmountDecimal:          in.AmtDecimal,
-		Receiver:                       in.Receiver,
-		Summary:                        "",
+	if !normalizeReceiver(t.chains, resp, receiverChain, &in.Receiver) {
+		return nil
 	}
-	msg, _ := json.Marshal(crossArgs)
-	_ = chainAbstraction{}.Render(resp, "cross_chain_abstraction", string(msg))
-	if swapOp.Dex != "" {
-		newOps := []interface{}{swapOp}
-		newOps = append(newOps, resp.Detail.OPs...)
-		resp.Detail.OPs = newOps
+	// 1. gather the token of every chain
+	if legs, ok := t.gatherFunds(ctx, in.Token, receiverChain, in.Amount); ok {
+		return t.fundedTransfer(in, receiverChain, swapPlan{}, legs, resp)
 	}
-	return nil
-}
-
-func (t transfer) internalTransfer(in transferArgs, resp *model.DemandResponse) {
-	tokenBalance := t.balance.GetTokenBalance(in.SourceChain, in.Token)
-	// 2.1 no need to swap
-	if tokenBalance.Cmp(in.AmtDecimal) > 0 {
-		sourceChainId, err := data.GetChainIdByName(in.SourceChain)
-		if err != nil {
-			log.Errorf("get chain id error: %v", err)
-			return
-		}
-		targetChainId, err := data.GetChainIdByName(in.TargetChain)
-		if err != nil {
-			log.Errorf("get chain id error: %v", err)
-			return
-		}
+	// 2. swap into the token where it costs least first
+	plan, legs, ok := t.swapAndGather(ctx, in.Token, receiverChain, in.Amount)
+	if !ok {
 		resp.Detail = model.DetailResp{
-			Reply: fmt.Sprintf("Ok I will transfer %s %s to %s on %s", utils.Float2String(in.Amount), in.Token, in.Receiver, in.SourceChain),
-			OPs: []interface{}{
-				model.CrossChainResponse{
-					Type:            model.ChainInternalTransfer,
-					SourceChainId:   targetChainId,
-					SourceChainName: in.TargetChain,
-					Token:           in.Token,
-					Amount:          utils.Float2String(in.Amount),
-					Receiver:        in.Receiver,
-					TargetChainName: in.SourceChain,
-					TargetChainId:   sourceChainId,
-				},
-			},
+			Reply: "swap not support",
+			OPs:   nil,
 		}
-		return
+		return nil
 	}
-	// 2.2 need to swap
-	potentialSwapPairs := make(map[model.Reserve]struct{})
-	tokens := t.balance.GetTokens(in.SourceChain)
-	for _, token := range tokens {
-		if token.Symbol == in.Token {
+	return t.fundedTransfer(in, receiverChain, plan, legs, resp)
+}
+
+// swapPairs lists the reserves on chain that could be swapped into token.
+func (t transfer) swapPairs(chain, token string) []model.Reserve {
+	pairs := make([]model.Reserve, 0)
+	for _, reserve := range t.balance.GetTokens(chain) {
+		if reserve.Symbol == token {
 			continue
 		}
-		potentialSwapPairs[token] = struct{}{}
+		pairs = append(pairs, reserve)
 	}
-	swapOp, ok := t.potentialSwap(potentialSwapPairs, in.SourceChain, in.Token, in.AmtDecimal)
-	if !ok {
-		resp.Detail = model.DetailResp{
-			Reply: "swap not support",
-			OPs:   nil,
-		}
+	return pairs
+}
+
+// swap quotes an explicit swap. Exact output amounts are quoted directly; for an
+// exact input the output is estimated from exact output quotes.
+func (t transfer) swap(ctx context.Context, in swapArgs, resp *model.DemandResponse) {
+	in.Chain = canonicalChain(t.chains, in.Chain)
+	if in.Chain == "" {
+		in.Chain = t.balance.BaseChain
+	}
+	resp.Category = "swap"
+	if !resolveToken(t.chains, resp, in.Chain, &in.SourceToken) || !resolveToken(t.chains, resp, in.Chain, &in.TargetToken) {
 		return
 	}
-	sourceChainId, err := data.GetChainIdByName(in.SourceChain)
-	if err != nil {
-		log.Errorf("get chain id error: %v", err)
+	if !in.AmountIn.IsPositive() && !in.AmountOut.IsPositive() {
+		resp.Detail = model.DetailResp{Reply: "missing swap amount"}
 		return
 	}
-	targetChainId, err := data.GetChainIdByName(in.TargetChain)
+	id, err := t.chains.ChainID(in.Chain)
 	if err != nil {
 		log.Errorf("get chain id error: %v", err)
+		resp.Detail = model.DetailResp{Reply: "chain not support"}
 		return
 	}
-	resp.Detail = model.DetailResp{
-		Reply: fmt.Sprintf("Ok I will transfer %s %s to %s on %s", utils.Float2String(in.Amount), in.Token, in.Receiver, in.SourceChain),
-		OPs: []interface{}{swapOp, model.CrossChainResponse{
-			Type:            model.ChainInternalTransfer,
-			SourceChainId:   targetChainId,
-			SourceChainName: in.TargetChain,
-			Token:           in.Token,
-			Amount:          utils.Float2String(in.Amount),
-			Receiver:        in.Receiver,
-			TargetChainName: in.SourceChain,
-			TargetChainId:   sourceChainId,
-		},
-		},
+	req := model.SwapReq{
+		ChainId:         id,
+		TokenInAddress:  t.balance.GetTokenAddress(in.Chain, in.SourceToken),
+		TokenOutAddress: t.balance.GetTokenAddress(in.Chain, in.TargetToken),
 	}
-}
-
-func (t transfer) potentialSwap(pairs map[model.Reserve]struct{}, chain, outToken string, minOut decimal.Decimal) (model.SwapResponse, bool) {
-	id, err := data.GetChainIdByName(chain)
-	if err != nil {
-		log.Errorf("get chain id error: %v", err)
-		return model.SwapResponse{}, false
-	}
-	currBalance := t.balance.GetTokenBalance(chain, outToken)
-	swapOutAmt := minOut.Sub(currBalance)
-	var (
-		bestSwapToken = ""
-		swapIn        = ""
-		swapOut       = swapOutAmt.String()
-		dex           = "uniswap"
-		rawResp       json.RawMessage
-	)
-	for reserve := range pairs {
-		if reserve.Balance <= 0 {
-			continue
-		}
-		out, _ := swapOutAmt.Float64()
-		req := model.SwapReq{
-			ChainId:         id,
-			TokenInAddress:  reserve.Address,
-			TokenOutAddress: t.balance.GetTokenAddress(chain, outToken),
-			AmountOut:       out,
-		}
-		minIn, body, err := pkg.Base.CheckSwap(req)
-		if err != nil {
-			continue
+	swapIn, swapOut := in.AmountIn, in.AmountOut
+	var body []byte
+	if in.AmountOut.IsPositive() {
+		req.AmountOut = json.Number(in.AmountOut.String())
+		var minIn string
+		minIn, body, err = checkSwap(req)
+		if err == nil {
+			swapIn, err = decimal.NewFromString(minIn)
 		}
-		bestSwapToken = reserve.Symbol
-		swapIn = minIn
-		rawResp = body
-		break
+	} else {
+		swapOut, body, err = quoteExactIn(t.chains, req, in.Chain, in.SourceToken, in.TargetToken, in.AmountIn)
 	}
-	if bestSwapToken == "" {
-		return model.SwapResponse{}, false
+	if err != nil || !swapIn.IsPositive() {
+		resp.Detail = model.DetailResp{Reply: "swap not support"}
+		return
+	}
+	if t.balance.GetTokenBalance(in.Chain, in.SourceToken).Cmp(swapIn) < 0 {
+		resp.Detail = model.DetailResp{Reply: "Insufficient Balance"}
+		return
+	}
+	swapOp := model.SwapResponse{
+		Type:         "swap",
+		ChainId:      id,
+		ChainName:    in.Chain,
+		RawResponse:  body,
+		SourceToken:  in.SourceToken,
+		TargetToken:  in.TargetToken,
+		SwapIn:       swapIn.String(),
+		SwapOut:      swapOut.String(),
+		Dex:          "uniswap",
+		ExactIn:      !in.AmountOut.IsPositive(),
+		EstimatedOut: !in.AmountOut.IsPositive(),
+	}
+	gasUSD, costUSD, priced := priceQuote(t.chains, in.SourceToken, swapIn, body)
+	swapOp.GasUSD = usdString(gasUSD, gasUSD.IsPositive())
+	swapOp.CostUSD = usdString(costUSD, priced)
+	stampQuote(&swapOp, body)
+	Progress(ctx, model.StageSwapQuoted, swapOp)
+	resp.Summary = fmt.Sprintf("Swap %s %s to %s on %s", swapOp.SwapIn, in.SourceToken, in.TargetToken, in.Chain)
+	ops := []model.Op{model.NewOp(&swapOp)}
+	resp.Detail = model.DetailResp{
+		Reply: fmt.Sprintf("Ok I will swap %s %s to about %s %s on %s", swapOp.SwapIn, in.SourceToken, swapOp.SwapOut, in.TargetToken, in.Chain) + opWarnings(ops),
+		OPs:   ops,
 	}
-	return model.SwapResponse{
-		Type:        "swap",
-		ChainId:     id,
-		ChainName:   chain,
-		RawResponse: rawResp,
-		SourceToken: bestSwapToken,
-		TargetToken: outToken,
-		SwapIn:      swapIn,
-		SwapOut:     swapOut,
-		Dex:         dex,
-	}, true
 }
@@ -0,0 +1,199 @@
+package strategy
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/sashabaranov/go-openai/jsonschema"
+	"github.com/shopspring/decimal"
+
+	"github.com/smarterwallet/demand-abstraction-serv/data"
+)
+
+var hexAddressRegexp = regexp.MustCompile(`^0x[0-9a-fA-F]{40}$`)
+
+// ValidationError lists every problem found in the arguments of one call, so
+// the llm can fix them all in a single retry.
+type ValidationError struct {
+	Function string
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("invalid arguments for %s: %s", e.Function, strings.Join(e.Problems, "; "))
+}
+
+// ArgumentChecker is implemented by strategies with domain rules beyond the
+// function schema, e.g. positive amounts or known chains.
+type ArgumentChecker interface {
+	CheckArgs(name string, args map[string]interface{}) []string
+}
+
+// Validate checks the arguments of a call against the function schema of st
+// and its domain rules. Required arguments are left to slot filling when st
+// asks the user for them.
+func Validate(st IStrategy, name, args string) error {
+	params, ok := functionSchema(st, name)
+	if !ok {
+		return ErrFunctionNotDefined
+	}
+	in, err := DecodeArgs(args)
+	if err != nil {
+		return &ValidationError{Function: name, Problems: []string{"arguments are not a JSON object: " + err.Error()}}
+	}
+	problems := checkSchema("", params, in)
+	if _, ok := st.(SlotFiller); !ok {
+		for _, required := range params.Required {
+			if _, ok := in[required]; !ok {
+				problems = append(problems, fmt.Sprintf("%s is required", required))
+			}
+		}
+	}
+	if checker, ok := st.(ArgumentChecker); ok && len(problems) == 0 {
+		problems = append(problems, checker.CheckArgs(name, in)...)
+	}
+	if len(problems) > 0 {
+		return &ValidationError{Function: name, Problems: problems}
+	}
+	return nil
+}
+
+func functionSchema(st IStrategy, name string) (jsonschema.Definition, bool) {
+	for _, function := range st.Functions() {
+		if function.Name == name {
+			params, _ := function.Parameters.(jsonschema.Definition)
+			return params, true
+		}
+	}
+	return jsonschema.Definition{}, false
+}
+
+// checkSchema reports the properties of in whose values don't match their
+// declared type or enum. Unknown properties and null values are ignored.
+func checkSchema(prefix string, schema jsonschema.Definition, in map[string]interface{}) []string {
+	names := make([]string, 0, len(in))
+	for name := range in {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var problems []string
+	for _, name := range names {
+		prop, ok := schema.Properties[name]
+		if !ok || in[name] == nil {
+			continue
+		}
+		problems = append(problems, checkValue(prefix+name, prop, in[name])...)
+	}
+	return problems
+}
+
+func checkValue(name string, prop jsonschema.Definition, value interface{}) []string {
+	switch prop.Type {
+	case jsonschema.String:
+		s, ok := value.(string)
+		if !ok {
+			return []string{fmt.Sprintf("%s must be a string", name)}
+		}
+		if len(prop.Enum) > 0 && !containsFold(prop.Enum, s) {
+			return []string{fmt.Sprintf("%s must be one of %s", name, strings.Join(prop.Enum, ", "))}
+		}
+	case jsonschema.Number:
+		if _, ok := value.(json.Number); !ok {
+			return []string{fmt.Sprintf("%s must be a number", name)}
+		}
+	case jsonschema.Integer:
+		if n, ok := value.(json.Number); !ok || strings.ContainsAny(n.String(), ".eE") {
+			return []string{fmt.Sprintf("%s must be an integer", name)}
+		}
+	case jsonschema.Boolean:
+		if _, ok := value.(bool); !ok {
+			return []string{fmt.Sprintf("%s must be a boolean", name)}
+		}
+	case jsonschema.Object:
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return []string{fmt.Sprintf("%s must be an object", name)}
+		}
+		return checkSchema(name+".", prop, obj)
+	case jsonschema.Array:
+		items, ok := value.([]interface{})
+		if !ok {
+			return []string{fmt.Sprintf("%s must be an array", name)}
+		}
+		if prop.Items == nil {
+			return nil
+		}
+		var problems []string
+		for i, item := range items {
+			problems = append(problems, checkValue(fmt.Sprintf("%s[%d]", name, i), *prop.Items, item)...)
+		}
+		return problems
+	}
+	return nil
+}
+
+func containsFold(values []string, s string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkAmounts rejects negative amounts; zero is treated as not given.
+func checkAmounts(args map[string]interface{}, names ...string) []string {
+	var problems []string
+	for _, name := range names {
+		n, ok := args[name].(json.Number)
+		if !ok {
+			continue
+		}
+		if d, err := decimal.NewFromString(n.String()); err == nil && d.IsNegative() {
+			problems = append(problems, fmt.Sprintf("%s must be positive, got %s", name, n))
+		}
+	}
+	return problems
+}
+
+func checkAddresses(args map[string]interface{}, names ...string) []string {
+	var problems []string
+	for _, name := range names {
+		if s, ok := args[name].(string); ok && s != "" && !hexAddressRegexp.MatchString(s) {
+			problems = append(problems, fmt.Sprintf("%s must be a 0x prefixed hex address of 40 characters, got %q", name, s))
+		}
+	}
+	return problems
+}
+
+// checkChains rejects chain names chains can't resolve. Nothing is rejected
+// before the chains are loaded.
+func checkChains(chains *data.Snapshot, args map[string]interface{}, names ...string) []string {
+	loaded := chains.ChainNames()
+	if len(loaded) == 0 {
+		return nil
+	}
+	var problems []string
+	for _, name := range names {
+		s, ok := args[name].(string)
+		if !ok || s == "" {
+			continue
+		}
+		if _, err := chains.Chain(s); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %s, use one of %s", name, err, strings.Join(loaded, ", ")))
+		}
+	}
+	return problems
+}
+
+// canonicalChain returns the loaded name of the chain called name, or name
+// lowercased when chains can't resolve it.
+func canonicalChain(chains *data.Snapshot, name string) string {
+	if chain, err := chains.Chain(name); err == nil {
+		return chain.Name
+	}
+	return strings.ToLower(strings.TrimSpace(name))
+}
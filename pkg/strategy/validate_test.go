@@ -0,0 +1,42 @@
+package strategy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/smarterwallet/demand-abstraction-serv/model"
+)
+
+func TestValidate(t *testing.T) {
+	st := transfer{balance: &model.CtxRequest{BaseChain: "mumbai"}}
+	cases := []struct {
+		name    string
+		fn      string
+		args    string
+		invalid string
+	}{
+		{"valid transfer", "get_trade_strategy", `{"token":"USDC","amount":10,"receiver":"0xd8da6bf26964af9d7eed9e03e53415d37aa96045","is_usd":false}`, ""},
+		{"18 decimal amount", "get_trade_strategy", `{"token":"WETH","amount":0.000000000000000001}`, ""},
+		{"missing slots are left to slot filling", "get_trade_strategy", `{"token":"USDC"}`, ""},
+		{"negative amount", "get_trade_strategy", `{"amount":-5}`, "amount must be positive"},
+		{"amount as string", "get_trade_strategy", `{"amount":"ten"}`, "amount must be a number"},
+		{"non hex receiver", "get_trade_strategy", `{"receiver":"vitalik"}`, "receiver must be a 0x prefixed hex address"},
+		{"same swap tokens", "swap_token", `{"source_token":"USDC","target_token":"usdc","amount_in":1}`, "must differ"},
+		{"not json", "swap_token", `{amount_in:1`, "not a JSON object"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := Validate(st, c.fn, c.args)
+			if c.invalid == "" {
+				assert.Nil(t, err)
+				return
+			}
+			assert.ErrorContains(t, err, c.invalid)
+		})
+	}
+
+	assert.ErrorIs(t, Validate(st, "unknown", "{}"), ErrFunctionNotDefined)
+	assert.ErrorContains(t, Validate(trade2Earn{}, "get_trade_to_earn_strategy", `{"minimum":"low","maximum":"10%"}`), "summary is required")
+	assert.ErrorContains(t, Validate(trade2Earn{}, "get_trade_to_earn_strategy", `{"minimum":"low","maximum":"10%","summary":"s"}`), "minimum must be a percentage")
+}
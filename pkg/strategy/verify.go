@@ -0,0 +1,135 @@
+package strategy
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/shopspring/decimal"
+)
+
+// Kinds of values a Tracer asks to trace back to the user's input.
+const (
+	TraceNumber  = "number"
+	TraceSymbol  = "symbol"
+	TraceAddress = "address"
+)
+
+// Tracer is implemented by strategies whose arguments must have been typed by
+// the user rather than made up by the llm. TracedArgs maps the names of those
+// arguments of a call to their trace kind.
+type Tracer interface {
+	TracedArgs(name string, args map[string]interface{}) map[string]string
+}
+
+var (
+	evidenceAddressRegexp = regexp.MustCompile(`0x[0-9a-fA-F]{40}`)
+	evidenceNumberRegexp  = regexp.MustCompile(`(\d[\d,]*(?:\.\d+)?|\.\d+)\s*([kKmM]\b)?`)
+	evidenceWordRegexp    = regexp.MustCompile(`[A-Za-z][A-Za-z0-9.]*`)
+)
+
+// Evidence is the numbers, words and addresses found in what the user typed.
+type Evidence struct {
+	numbers   []decimal.Decimal
+	words     map[string]bool
+	addresses map[string]bool
+}
+
+// CollectEvidence parses the values of texts, such as the demand and the
+// user's recent messages.
+func CollectEvidence(texts ...string) Evidence {
+	ev := Evidence{words: make(map[string]bool), addresses: make(map[string]bool)}
+	for _, text := range texts {
+		for _, address := range evidenceAddressRegexp.FindAllString(text, -1) {
+			ev.addresses[strings.ToLower(address)] = true
+		}
+		text = evidenceAddressRegexp.ReplaceAllString(text, " ")
+		for _, m := range evidenceNumberRegexp.FindAllStringSubmatch(text, -1) {
+			n, err := decimal.NewFromString(strings.ReplaceAll(m[1], ",", ""))
+			if err != nil {
+				continue
+			}
+			ev.numbers = append(ev.numbers, n)
+			switch strings.ToLower(m[2]) {
+			case "k":
+				ev.numbers = append(ev.numbers, n.Shift(3))
+			case "m":
+				ev.numbers = append(ev.numbers, n.Shift(6))
+			}
+		}
+		for _, word := range evidenceWordRegexp.FindAllString(text, -1) {
+			ev.words[strings.ToUpper(strings.TrimRight(word, "."))] = true
+		}
+	}
+	return ev
+}
+
+func (ev Evidence) has(kind, value string) bool {
+	switch kind {
+	case TraceNumber:
+		n, err := decimal.NewFromString(value)
+		if err != nil {
+			return false
+		}
+		for _, m := range ev.numbers {
+			if m.Equal(n) {
+				return true
+			}
+		}
+		return false
+	case TraceSymbol:
+		return ev.words[strings.ToUpper(value)]
+	case TraceAddress:
+		return ev.addresses[strings.ToLower(value)]
+	}
+	return true
+}
+
+// Untraced is an argument whose value can't be found in the user's input.
+type Untraced struct {
+	Arg   string
+	Kind  string
+	Value string
+}
+
+func (u Untraced) String() string {
+	return fmt.Sprintf("%s %s", strings.ReplaceAll(u.Arg, "_", " "), u.Value)
+}
+
+// Verify lists the traced arguments of a call of st missing from ev, sorted by
+// name. Arguments without a value are left to slot filling.
+func Verify(st IStrategy, name string, args map[string]interface{}, ev Evidence) []Untraced {
+	tracer, ok := st.(Tracer)
+	if !ok {
+		return nil
+	}
+	traced := tracer.TracedArgs(name, args)
+	names := make([]string, 0, len(traced))
+	for arg := range traced {
+		names = append(names, arg)
+	}
+	sort.Strings(names)
+	var untraced []Untraced
+	for _, arg := range names {
+		if !hasValue(args[arg]) {
+			continue
+		}
+		value := argString(args[arg])
+		if !ev.has(traced[arg], value) {
+			untraced = append(untraced, Untraced{Arg: arg, Kind: traced[arg], Value: value})
+		}
+	}
+	return untraced
+}
+
+func argString(v interface{}) string {
+	switch value := v.(type) {
+	case string:
+		return strings.TrimSpace(value)
+	case json.Number:
+		return value.String()
+	}
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,44 @@
+package strategy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/smarterwallet/demand-abstraction-serv/model"
+)
+
+func TestVerify(t *testing.T) {
+	st := transfer{balance: &model.CtxRequest{BaseChain: "mumbai"}}
+	ev := CollectEvidence(
+		"send 1,000 usdc and 2.5k dai to 0xD8DA6BF26964AF9D7EED9E03E53415D37AA96045 on fuji",
+		"then 0.5 more USDC.e",
+	)
+	cases := []struct {
+		name     string
+		args     string
+		untraced []Untraced
+	}{
+		{"traced", `{"amount":1000,"token":"USDC","receiver":"0xd8da6bf26964af9d7eed9e03e53415d37aa96045"}`, nil},
+		{"thousands suffix", `{"amount":2500,"token":"dai"}`, nil},
+		{"history", `{"amount":0.5,"token":"USDC.e"}`, nil},
+		{"missing values are not checked", `{"amount":0,"receiver":""}`, nil},
+		{"usd transfers need no token", `{"amount":1000,"token":"USDT","is_usd":true}`, nil},
+		{"made up", `{"amount":1005,"token":"USDT","receiver":"0x5134f00c95b8e794db38e1ee39397d8086cee7ed"}`, []Untraced{
+			{Arg: "amount", Kind: TraceNumber, Value: "1005"},
+			{Arg: "receiver", Kind: TraceAddress, Value: "0x5134f00c95b8e794db38e1ee39397d8086cee7ed"},
+			{Arg: "token", Kind: TraceSymbol, Value: "USDT"},
+		}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			args, err := DecodeArgs(c.args)
+			assert.Nil(t, err)
+			assert.Equal(t, c.untraced, Verify(st, "get_trade_strategy", args, ev))
+		})
+	}
+
+	assert.Nil(t, Verify(st, "swap_token", map[string]interface{}{"amount_in": "7"}, ev))
+	assert.Nil(t, Verify(trade2Earn{}, "get_trade_to_earn_strategy", map[string]interface{}{"minimum": "6%"}, ev))
+	assert.Equal(t, "amount 1005", Untraced{Arg: "amount", Value: "1005"}.String())
+}
@@ -0,0 +1,612 @@
+// Package userop turns a rendered plan into unsigned ERC-4337 UserOperations
+// for a SimpleAccount wallet. Everything is built offline from the ops and the
+// loaded asset config; nonces and gas are left for the wallet or bundler to
+// refine before signing.
+package userop
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+
+	"github.com/smarterwallet/demand-abstraction-serv/data"
+	"github.com/smarterwallet/demand-abstraction-serv/model"
+	"github.com/smarterwallet/demand-abstraction-serv/pkg/abi"
+	"github.com/smarterwallet/demand-abstraction-serv/utils"
+)
+
+// Default gas values of an unsigned UserOperation.
+var (
+	DefaultCallGasLimit         = big.NewInt(500000)
+	DefaultVerificationGasLimit = big.NewInt(500000)
+	DefaultPreVerificationGas   = big.NewInt(100000)
+)
+
+// UserOperation is an ERC-4337 (EntryPoint v0.6) user operation with every
+// field hex encoded as expected by bundler RPCs. PaymasterAndData names the
+// token paymaster of the chain when the asset config has one.
+type UserOperation struct {
+	Sender               string `json:"sender"`
+	Nonce                string `json:"nonce"`
+	InitCode             string `json:"initCode"`
+	CallData             string `json:"callData"`
+	CallGasLimit         string `json:"callGasLimit"`
+	VerificationGasLimit string `json:"verificationGasLimit"`
+	PreVerificationGas   string `json:"preVerificationGas"`
+	MaxFeePerGas         string `json:"maxFeePerGas"`
+	MaxPriorityFeePerGas string `json:"maxPriorityFeePerGas"`
+	PaymasterAndData     string `json:"paymasterAndData"`
+	Signature            string `json:"signature"`
+}
+
+// ChainUserOperation is a UserOperation with the chain it is sent on and the
+// plan steps it executes.
+type ChainUserOperation struct {
+	ChainName     string        `json:"chain_name"`
+	NetworkId     int           `json:"network_id"`
+	EntryPoint    string        `json:"entry_point"`
+	Steps         []int         `json:"steps"`
+	UserOperation UserOperation `json:"user_operation"`
+}
+
+// Skipped is a plan step the builder could not encode.
+type Skipped struct {
+	Step   int    `json:"step"`
+	Kind   string `json:"kind"`
+	Reason string `json:"reason"`
+}
+
+// Result is the UserOperations built from a plan.
+type Result struct {
+	UserOperations []ChainUserOperation `json:"user_operations"`
+	Skipped        []Skipped            `json:"skipped,omitempty"`
+}
+
+// Options overrides the defaults of the built UserOperations.
+type Options struct {
+	// Nonces by chain name, "0x0" when missing.
+	Nonces map[string]string `json:"nonces"`
+	// InitCodes by chain name for senders not deployed yet.
+	InitCodes            map[string]string `json:"init_codes"`
+	MaxFeePerGas         string            `json:"max_fee_per_gas"`
+	MaxPriorityFeePerGas string            `json:"max_priority_fee_per_gas"`
+	// PaymasterData is appended to the token paymaster of the chain in
+	// PaymasterAndData, such as the token price a TokenPaymaster accepts.
+	PaymasterData string `json:"paymaster_data"`
+	// Chains holds the tokens and contracts the calls are encoded with.
+	Chains *data.Snapshot `json:"-"`
+}
+
+const zeroAddress = "0x0000000000000000000000000000000000000000"
+
+type call struct {
+	step  int
+	to    string
+	value *big.Int
+	data  []byte
+}
+
+type chainCalls struct {
+	name  string
+	calls []call
+}
+
+// Build encodes ops as one UserOperation per chain, in the order the chains
+// first appear. Calls on a chain are batched with executeBatch unless one of
+// them carries native value, which executeBatch cannot send; those chains get
+// one UserOperation per call. Ops that cannot be encoded offline are skipped.
+func Build(sender string, ops []model.Op, opts Options) (*Result, error) {
+	if b, err := abi.DecodeHex(sender); err != nil || len(b) != 20 {
+		return nil, errors.Errorf("sender: invalid address %q", sender)
+	}
+	var (
+		chains = make([]*chainCalls, 0)
+		byName = make(map[string]*chainCalls)
+		result = &Result{UserOperations: make([]ChainUserOperation, 0)}
+	)
+	for i, op := range ops {
+		step := opStep(op, i+1)
+		chain, calls, err := opCalls(opts.Chains, op, step, sender)
+		if err != nil {
+			result.Skipped = append(result.Skipped, Skipped{Step: step, Kind: op.Kind(), Reason: err.Error()})
+			continue
+		}
+		cc, ok := byName[chain]
+		if !ok {
+			cc = &chainCalls{name: chain}
+			byName[chain] = cc
+			chains = append(chains, cc)
+		}
+		cc.calls = append(cc.calls, calls...)
+	}
+	for _, cc := range chains {
+		built, err := buildChain(sender, cc, opts)
+		if err != nil {
+			return nil, errors.Wrapf(err, "chain %s", cc.name)
+		}
+		result.UserOperations = append(result.UserOperations, built...)
+	}
+	return result, nil
+}
+
+func buildChain(sender string, cc *chainCalls, opts Options) ([]ChainUserOperation, error) {
+	contracts, err := opts.Chains.Erc4337Contracts(cc.name)
+	if err != nil {
+		return nil, err
+	}
+	paymasterAndData, err := paymasterAndData(contracts.TokenPaymaster, opts.PaymasterData)
+	if err != nil {
+		return nil, err
+	}
+	batches := [][]call{cc.calls}
+	for _, c := range cc.calls {
+		if c.value.Sign() > 0 {
+			batches = make([][]call, 0, len(cc.calls))
+			for _, c := range cc.calls {
+				batches = append(batches, []call{c})
+			}
+			break
+		}
+	}
+	userOps := make([]ChainUserOperation, 0, len(batches))
+	for i, batch := range batches {
+		var callData []byte
+		if len(batch) == 1 {
+			callData, err = abi.Execute(batch[0].to, batch[0].value, batch[0].data)
+		} else {
+			dests := make([]string, len(batch))
+			funcs := make([][]byte, len(batch))
+			for j, c := range batch {
+				dests[j], funcs[j] = c.to, c.data
+			}
+			callData, err = abi.ExecuteBatch(dests, funcs)
+		}
+		if err != nil {
+			return nil, err
+		}
+		uo := UserOperation{
+			Sender:               sender,
+			Nonce:                nonce(opts.Nonces[cc.name], i),
+			InitCode:             "0x",
+			CallData:             abi.EncodeHex(callData),
+			CallGasLimit:         encodeQuantity(DefaultCallGasLimit),
+			VerificationGasLimit: encodeQuantity(DefaultVerificationGasLimit),
+			PreVerificationGas:   encodeQuantity(DefaultPreVerificationGas),
+			MaxFeePerGas:         orZero(opts.MaxFeePerGas),
+			MaxPriorityFeePerGas: orZero(opts.MaxPriorityFeePerGas),
+			PaymasterAndData:     paymasterAndData,
+			Signature:            "0x",
+		}
+		if initCode := opts.InitCodes[cc.name]; initCode != "" && i == 0 {
+			uo.InitCode = initCode
+		}
+		steps := make([]int, 0, len(batch))
+		for _, c := range batch {
+			if len(steps) == 0 || steps[len(steps)-1] != c.step {
+				steps = append(steps, c.step)
+			}
+		}
+		userOps = append(userOps, ChainUserOperation{
+			ChainName:     cc.name,
+			NetworkId:     contracts.NetworkId,
+			EntryPoint:    contracts.EntryPoint,
+			Steps:         steps,
+			UserOperation: uo,
+		})
+	}
+	return userOps, nil
+}
+
+// paymasterAndData pays the gas with the token paymaster of the chain, which
+// charges its token to the sender, followed by data. It is "0x" on chains
+// without one, where the sender pays the gas.
+func paymasterAndData(paymaster, data string) (string, error) {
+	if paymaster == "" {
+		return "0x", nil
+	}
+	address, err := abi.DecodeHex(paymaster)
+	if err != nil || len(address) != 20 {
+		return "", errors.Errorf("token paymaster: invalid address %q", paymaster)
+	}
+	extra, err := abi.DecodeHex(data)
+	if err != nil {
+		return "", errors.Errorf("paymaster data: invalid hex %q", data)
+	}
+	return abi.EncodeHex(append(address, extra...)), nil
+}
+
+// nonce offsets the first nonce of a chain by the UserOperations before it.
+func nonce(first string, offset int) string {
+	n, ok := new(big.Int).SetString(strings.TrimPrefix(orZero(first), "0x"), 16)
+	if !ok {
+		n = new(big.Int)
+	}
+	return encodeQuantity(n.Add(n, big.NewInt(int64(offset))))
+}
+
+func encodeQuantity(n *big.Int) string {
+	return "0x" + n.Text(16)
+}
+
+func orZero(quantity string) string {
+	if quantity == "" {
+		return "0x0"
+	}
+	return quantity
+}
+
+func opStep(op model.Op, fallback int) int {
+	switch o := op.Body.(type) {
+	case *model.CrossChainResponse:
+		if o.Step > 0 {
+			return o.Step
+		}
+	case *model.SwapResponse:
+		if o.Step > 0 {
+			return o.Step
+		}
+	}
+	return fallback
+}
+
+// opCalls returns the chain an op runs on and the account calls of sender
+// executing it, preferring the calldata already attached to the op.
+func opCalls(chains *data.Snapshot, op model.Op, step int, sender string) (string, []call, error) {
+	var (
+		chain    string
+		attached []model.Call
+	)
+	switch o := op.Body.(type) {
+	case *model.CrossChainResponse:
+		chain, attached = o.SourceChainName, o.Calldata
+	case *model.SwapResponse:
+		chain, attached = o.ChainName, o.Calldata
+	}
+	calls, err := fromModel(attached)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(calls) == 0 {
+		if calls, err = encodeOp(chains, op, sender); err != nil {
+			return "", nil, err
+		}
+	}
+	for i := range calls {
+		calls[i].step = step
+	}
+	return strings.ToLower(chain), calls, nil
+}
+
+// Calls encodes the calls of the account sender executing op: a token transfer
+// or native send for transfers, approve and a router swap bounded by slippage
+// for swaps, and approve and ccipSend for bridges configured with a CCIP
+// router.
+func Calls(chains *data.Snapshot, op model.Op, sender string) ([]model.Call, error) {
+	calls, err := encodeOp(chains, op, sender)
+	if err != nil {
+		return nil, err
+	}
+	encoded := make([]model.Call, 0, len(calls))
+	for _, c := range calls {
+		encoded = append(encoded, model.Call{To: c.to, Value: encodeQuantity(c.value), Data: abi.EncodeHex(c.data)})
+	}
+	return encoded, nil
+}
+
+func fromModel(calls []model.Call) ([]call, error) {
+	decoded := make([]call, 0, len(calls))
+	for _, c := range calls {
+		value, ok := new(big.Int).SetString(strings.TrimPrefix(orZero(c.Value), "0x"), 16)
+		if !ok {
+			return nil, fmt.Errorf("invalid call value %q", c.Value)
+		}
+		data, err := abi.DecodeHex(c.Data)
+		if err != nil {
+			return nil, fmt.Errorf("invalid calldata %q", c.Data)
+		}
+		decoded = append(decoded, call{to: c.To, value: value, data: data})
+	}
+	return decoded, nil
+}
+
+func encodeOp(chains *data.Snapshot, op model.Op, sender string) ([]call, error) {
+	switch o := op.Body.(type) {
+	case *model.CrossChainResponse:
+		if o.Type != model.ChainInternalTransfer {
+			return bridgeCalls(chains, o)
+		}
+		c, err := transferCall(chains, o.SourceChainName, o.Token, o.Receiver, o.Amount, o.AmountRaw)
+		if err != nil {
+			return nil, err
+		}
+		return []call{c}, nil
+	case *model.SwapResponse:
+		return swapCalls(chains, o, sender)
+	}
+	return nil, errors.New("op has no onchain call")
+}
+
+// transferCall sends amount of token to receiver, natively when the token has
+// no contract address.
+func transferCall(chains *data.Snapshot, chain, symbol, receiver, amount, raw string) (call, error) {
+	token, err := chains.Token(chain, symbol)
+	if err != nil {
+		return call{}, err
+	}
+	value, err := baseUnits(amount, raw, token.Decimal)
+	if err != nil {
+		return call{}, err
+	}
+	if isNative(token.Address) {
+		return call{to: receiver, value: value}, nil
+	}
+	transfer, err := abi.Transfer(receiver, value)
+	if err != nil {
+		return call{}, err
+	}
+	return call{to: token.Address, value: new(big.Int), data: transfer}, nil
+}
+
+// swapCalls approves the router for the input token and swaps through the
+// pools of the quote, bounded by MaxAmountIn and MinAmountOut and paying
+// recipient. Native outputs are unwrapped by the router and the native input
+// it leaves of an exact output swap is refunded.
+func swapCalls(chains *data.Snapshot, o *model.SwapResponse, recipient string) ([]call, error) {
+	if recipient == "" {
+		return nil, errors.New("swap recipient unknown")
+	}
+	params, err := methodParameters(o.RawResponse)
+	if err != nil {
+		return nil, err
+	}
+	res, err := model.DecodeSwapResult(o.RawResponse)
+	if err != nil {
+		return nil, err
+	}
+	if len(res.Route) != 1 {
+		return nil, fmt.Errorf("swap quote has %d routes, want one", len(res.Route))
+	}
+	route := res.Route[0].Route
+	if len(route.Pools) == 0 || len(route.TokenPath) != len(route.Pools)+1 {
+		return nil, errors.New("swap quote has no pool route")
+	}
+	in, err := chains.Token(o.ChainName, o.SourceToken)
+	if err != nil {
+		return nil, err
+	}
+	out, err := chains.Token(o.ChainName, o.TargetToken)
+	if err != nil {
+		return nil, err
+	}
+	tokens := make([]string, 0, len(route.TokenPath))
+	for _, token := range route.TokenPath {
+		tokens = append(tokens, strings.ToLower(token.Address))
+	}
+	fees := make([]uint32, 0, len(route.Pools))
+	for _, pool := range route.Pools {
+		fees = append(fees, uint32(pool.Fee))
+	}
+	if !isNative(in.Address) && !strings.EqualFold(tokens[0], in.Address) || !isNative(out.Address) && !strings.EqualFold(tokens[len(tokens)-1], out.Address) {
+		return nil, fmt.Errorf("swap quote does not route %s to %s", o.SourceToken, o.TargetToken)
+	}
+	amountIn, maxIn, err := bounds(o.SwapIn, o.SwapInRaw, o.MaxAmountIn, o.MaxAmountInRaw, in.Decimal)
+	if err != nil {
+		return nil, err
+	}
+	amountOut, minOut, err := bounds(o.SwapOut, o.SwapOutRaw, o.MinAmountOut, o.MinAmountOutRaw, out.Decimal)
+	if err != nil {
+		return nil, err
+	}
+	payee := recipient
+	if isNative(out.Address) {
+		payee = abi.RouterSelf
+	}
+	var swap []byte
+	switch {
+	case len(fees) == 1 && o.ExactIn:
+		swap, err = abi.ExactInputSingle(abi.SingleSwap{TokenIn: tokens[0], TokenOut: tokens[1], Fee: fees[0], Recipient: payee, Amount: amountIn, Limit: minOut})
+	case len(fees) == 1:
+		swap, err = abi.ExactOutputSingle(abi.SingleSwap{TokenIn: tokens[0], TokenOut: tokens[1], Fee: fees[0], Recipient: payee, Amount: amountOut, Limit: maxIn})
+	case o.ExactIn:
+		var path []byte
+		if path, err = abi.EncodePath(tokens, fees); err == nil {
+			swap, err = abi.ExactInput(abi.PathSwap{Path: path, Recipient: payee, Amount: amountIn, Limit: minOut})
+		}
+	default:
+		var path []byte
+		if path, err = abi.EncodePath(reversePath(tokens, fees)); err == nil {
+			swap, err = abi.ExactOutput(abi.PathSwap{Path: path, Recipient: payee, Amount: amountOut, Limit: maxIn})
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	routerCalls := [][]byte{swap}
+	if isNative(out.Address) {
+		unwrap, err := abi.UnwrapWETH9(minOut, recipient)
+		if err != nil {
+			return nil, err
+		}
+		routerCalls = append(routerCalls, unwrap)
+	}
+	value := new(big.Int)
+	if isNative(in.Address) {
+		value = maxIn
+		if !o.ExactIn {
+			refund, err := abi.RefundETH()
+			if err != nil {
+				return nil, err
+			}
+			routerCalls = append(routerCalls, refund)
+		}
+	}
+	if len(routerCalls) > 1 {
+		if swap, err = abi.Multicall(routerCalls); err != nil {
+			return nil, err
+		}
+	}
+	swapCall := call{to: params.To, value: value, data: swap}
+	if isNative(in.Address) {
+		return []call{swapCall}, nil
+	}
+	approve, err := abi.Approve(params.To, maxIn)
+	if err != nil {
+		return nil, err
+	}
+	return []call{{to: in.Address, value: new(big.Int), data: approve}, swapCall}, nil
+}
+
+// bounds returns an amount of a swap in base units and its slippage bound,
+// the amount itself when the swap has none.
+func bounds(amount, raw, bound, boundRaw string, decimals int) (*big.Int, *big.Int, error) {
+	n, err := baseUnits(amount, raw, decimals)
+	if err != nil || bound == "" {
+		return n, n, err
+	}
+	limit, err := baseUnits(bound, boundRaw, decimals)
+	return n, limit, err
+}
+
+// reversePath turns a pool path around, as exact output swaps read it.
+func reversePath(tokens []string, fees []uint32) ([]string, []uint32) {
+	rTokens := make([]string, len(tokens))
+	for i, token := range tokens {
+		rTokens[len(tokens)-1-i] = token
+	}
+	rFees := make([]uint32, len(fees))
+	for i, fee := range fees {
+		rFees[len(fees)-1-i] = fee
+	}
+	return rTokens, rFees
+}
+
+// ccipConfig is the config of a cross-chain route using a CCIP router.
+type ccipConfig struct {
+	Router            string      `json:"router"`
+	DestChainSelector json.Number `json:"destChainSelector"`
+	FeeToken          string      `json:"feeToken"`
+}
+
+// bridgeCalls approves the CCIP router of the first CCIP route of the cross
+// chain config and sends the tokens through it. Without a fee token the
+// router takes its fee in the native token, paid as the value of ccipSend
+// from the native bridge fee listed on the op.
+func bridgeCalls(chains *data.Snapshot, o *model.CrossChainResponse) ([]call, error) {
+	var routes model.CrossChainResp
+	if len(o.RawResponse) > 0 {
+		if err := json.Unmarshal(o.RawResponse, &routes); err != nil {
+			return nil, errors.Wrap(err, "decode cross chain config")
+		}
+	}
+	for _, route := range routes.Result {
+		var cfg ccipConfig
+		if !strings.EqualFold(route.ProtocolName, "ccip") || json.Unmarshal(route.Config, &cfg) != nil || cfg.Router == "" {
+			continue
+		}
+		selector, ok := new(big.Int).SetString(cfg.DestChainSelector.String(), 10)
+		if !ok || !selector.IsUint64() {
+			continue
+		}
+		token, err := chains.Token(o.SourceChainName, o.Token)
+		if err != nil {
+			return nil, err
+		}
+		if isNative(token.Address) {
+			return nil, errors.New("native tokens are not bridged through ccip")
+		}
+		amount, err := baseUnits(o.Amount, o.AmountRaw, token.Decimal)
+		if err != nil {
+			return nil, err
+		}
+		feeToken, value := cfg.FeeToken, new(big.Int)
+		if feeToken == "" || isNative(feeToken) {
+			feeToken = zeroAddress
+			if value, err = nativeBridgeFee(chains, o); err != nil {
+				return nil, err
+			}
+		}
+		approve, err := abi.Approve(cfg.Router, amount)
+		if err != nil {
+			return nil, err
+		}
+		send, err := abi.CCIPSend(selector.Uint64(), o.Receiver, token.Address, amount, feeToken)
+		if err != nil {
+			return nil, err
+		}
+		return []call{
+			{to: token.Address, value: new(big.Int), data: approve},
+			{to: cfg.Router, value: value, data: send},
+		}, nil
+	}
+	return nil, errors.New("bridge calldata depends on the bridge protocol and is not built offline")
+}
+
+// nativeBridgeFee is the bridge fee of o in the native token of its source
+// chain, in wei.
+func nativeBridgeFee(chains *data.Snapshot, o *model.CrossChainResponse) (*big.Int, error) {
+	chain, err := chains.Chain(o.SourceChainName)
+	if err != nil {
+		return nil, err
+	}
+	for _, fee := range o.Fees {
+		if fee.Kind != model.FeeBridge || !strings.EqualFold(fee.Token, chain.Native) {
+			continue
+		}
+		decimals := 18
+		if native, err := chains.Token(o.SourceChainName, chain.Native); err == nil && native.Decimal > 0 {
+			decimals = native.Decimal
+		}
+		return baseUnits(fee.Amount, "", decimals)
+	}
+	return nil, errors.Errorf("native ccip fee of the %s bridge unknown", o.Token)
+}
+
+type swapMethodParameters struct {
+	Calldata string `json:"calldata"`
+	Value    string `json:"value"`
+	To       string `json:"to"`
+}
+
+// methodParameters finds the router call of a swap quote, either in the quote
+// itself or in the result of the swap service response wrapping it.
+func methodParameters(raw json.RawMessage) (swapMethodParameters, error) {
+	var quote struct {
+		MethodParameters swapMethodParameters `json:"methodParameters"`
+		Result           struct {
+			MethodParameters swapMethodParameters `json:"methodParameters"`
+		} `json:"result"`
+	}
+	if len(raw) > 0 {
+		if err := json.Unmarshal(raw, &quote); err != nil {
+			return swapMethodParameters{}, errors.Wrap(err, "decode swap quote")
+		}
+	}
+	for _, params := range []swapMethodParameters{quote.MethodParameters, quote.Result.MethodParameters} {
+		if params.Calldata != "" && params.To != "" {
+			return params, nil
+		}
+	}
+	return swapMethodParameters{}, errors.New("swap quote has no methodParameters")
+}
+
+func baseUnits(amount, raw string, decimals int) (*big.Int, error) {
+	if raw != "" {
+		if n, ok := new(big.Int).SetString(raw, 10); ok {
+			return n, nil
+		}
+	}
+	d, err := decimal.NewFromString(amount)
+	if err != nil {
+		return nil, fmt.Errorf("invalid amount %q", amount)
+	}
+	return utils.ToBaseUnits(d, decimals), nil
+}
+
+func isNative(address string) bool {
+	b, err := abi.DecodeHex(address)
+	return err == nil && new(big.Int).SetBytes(b).Sign() == 0
+}
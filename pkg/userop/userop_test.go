@@ -0,0 +1,186 @@
+package userop
+
+import (
+	"encoding/json"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/smarterwallet/demand-abstraction-serv/data"
+	"github.com/smarterwallet/demand-abstraction-serv/model"
+	"github.com/smarterwallet/demand-abstraction-serv/pkg/abi"
+)
+
+const (
+	sender   = "0x1111111111111111111111111111111111111111"
+	receiver = "0xd8da6bf26964af9d7eed9e03e53415d37aa96045"
+	usdc     = "0x9999f7fea5938fd3b1e26a12c3f2fdd5c2e0f9a1"
+	dai      = "0x8888f7fea5938fd3b1e26a12c3f2fdd5c2e0f9a1"
+	router   = "0x3fc91a3afd70395cd496c647d5a6cc9d4b2b7fad"
+)
+
+func testChains() *data.Snapshot {
+	b := data.NewSnapshotBuilder(nil, nil)
+	b.AddChain("mumbai", 80001, 80001, "MATIC")
+	b.AddErc4337Contracts("mumbai", data.Erc4337Contracts{
+		NetworkId:      80001,
+		EntryPoint:     "0x5ff137d4b0fdcd49dca30c7cf57e578a026d2789",
+		TokenPaymaster: "0x7777f7fea5938fd3b1e26a12c3f2fdd5c2e0f9a1",
+	})
+	b.AddToken("mumbai", data.Token{Symbol: "USDC", Address: usdc, Decimal: 6})
+	b.AddToken("mumbai", data.Token{Symbol: "DAI", Address: dai, Decimal: 18})
+	b.AddToken("mumbai", data.Token{Symbol: "MATIC", Decimal: 18})
+	return data.NewRegistry().Publish(b)
+}
+
+// swapQuote is a quote of the swap service routing through the pools of fees
+// between the tokens of path.
+func swapQuote(fees []int, path ...string) json.RawMessage {
+	pools := make([]map[string]interface{}, 0, len(fees))
+	for _, fee := range fees {
+		pools = append(pools, map[string]interface{}{"fee": fee})
+	}
+	tokens := make([]map[string]string, 0, len(path))
+	for _, address := range path {
+		tokens = append(tokens, map[string]string{"address": address})
+	}
+	quote, _ := json.Marshal(map[string]interface{}{
+		"code": 200,
+		"result": map[string]interface{}{
+			"methodParameters": map[string]string{"calldata": "0x5ae401dc", "value": "0x00", "to": router},
+			"route":            []map[string]interface{}{{"percent": 100, "route": map[string]interface{}{"pools": pools, "tokenPath": tokens}}},
+		},
+	})
+	return quote
+}
+
+func TestBuild(t *testing.T) {
+	chains := testChains()
+
+	quote := swapQuote([]int{500}, dai, usdc)
+	ops := []model.Op{
+		model.NewOp(&model.SwapResponse{Type: model.OpSwap, Step: 1, ChainName: "mumbai", SourceToken: "DAI", TargetToken: "USDC", SwapIn: "2", SwapOut: "1.9", RawResponse: quote}),
+		model.NewOp(&model.CrossChainResponse{Type: model.ChainInternalTransfer, Step: 2, DependsOn: []int{1}, SourceChainName: "mumbai", Token: "USDC", Amount: "1.5", Receiver: receiver}),
+		model.NewOp(&model.CrossChainResponse{Type: model.CrossChainTransfer, Step: 3, SourceChainName: "mumbai", TargetChainName: "fuji", Token: "USDC", Amount: "1", Receiver: receiver}),
+	}
+	result, err := Build(sender, ops, Options{Nonces: map[string]string{"mumbai": "0x5"}, Chains: chains})
+	assert.Nil(t, err)
+	assert.Equal(t, []Skipped{{Step: 3, Kind: model.OpCrossChainTransfer, Reason: "bridge calldata depends on the bridge protocol and is not built offline"}}, result.Skipped)
+	if assert.Len(t, result.UserOperations, 1) {
+		uo := result.UserOperations[0]
+		assert.Equal(t, 80001, uo.NetworkId)
+		assert.Equal(t, []int{1, 2}, uo.Steps)
+		assert.Equal(t, "0x5", uo.UserOperation.Nonce)
+		assert.Equal(t, "0x7777f7fea5938fd3b1e26a12c3f2fdd5c2e0f9a1", uo.UserOperation.PaymasterAndData)
+		assert.True(t, strings.HasPrefix(uo.UserOperation.CallData, "0x18dfb3c7"))
+		approve, _ := abi.Approve(router, big.NewInt(0).Mul(big.NewInt(2), big.NewInt(1e18)))
+		transfer, _ := abi.Transfer(receiver, big.NewInt(1500000))
+		assert.Contains(t, uo.UserOperation.CallData, abi.EncodeHex(approve)[2:])
+		swap, _ := abi.ExactOutputSingle(abi.SingleSwap{TokenIn: dai, TokenOut: usdc, Fee: 500, Recipient: sender,
+			Amount: big.NewInt(1900000), Limit: big.NewInt(0).Mul(big.NewInt(2), big.NewInt(1e18))})
+		assert.Contains(t, uo.UserOperation.CallData, abi.EncodeHex(swap)[2:])
+		assert.Contains(t, uo.UserOperation.CallData, abi.EncodeHex(transfer)[2:])
+	}
+
+	// native value cannot be batched
+	result, err = Build(sender, []model.Op{
+		ops[1],
+		model.NewOp(&model.CrossChainResponse{Type: model.ChainInternalTransfer, Step: 4, SourceChainName: "mumbai", Token: "MATIC", Amount: "0.1", Receiver: receiver}),
+	}, Options{Chains: chains})
+	assert.Nil(t, err)
+	if assert.Len(t, result.UserOperations, 2) {
+		assert.Equal(t, "0x0", result.UserOperations[0].UserOperation.Nonce)
+		assert.Equal(t, "0x1", result.UserOperations[1].UserOperation.Nonce)
+		assert.True(t, strings.HasPrefix(result.UserOperations[1].UserOperation.CallData, "0xb61d27f6"))
+	}
+
+	// data for the paymaster follows its address
+	result, err = Build(sender, ops[1:2], Options{Chains: chains, PaymasterData: "0x" + strings.Repeat("00", 31) + "2a"})
+	assert.Nil(t, err)
+	if assert.Len(t, result.UserOperations, 1) {
+		assert.Equal(t, "0x7777f7fea5938fd3b1e26a12c3f2fdd5c2e0f9a1"+strings.Repeat("00", 31)+"2a", result.UserOperations[0].UserOperation.PaymasterAndData)
+	}
+	_, err = Build(sender, ops[1:2], Options{Chains: chains, PaymasterData: "0xzz"})
+	assert.ErrorContains(t, err, "paymaster data")
+
+	_, err = Build("0x12", ops, Options{})
+	assert.NotNil(t, err)
+}
+
+func TestCalls(t *testing.T) {
+	chains := testChains()
+	ccipRouter := "0x1035cabc275068e0f4b745a29cedf38e13af41b1"
+	config, _ := json.Marshal(map[string]interface{}{
+		"code": 200,
+		"result": []map[string]interface{}{
+			{"protocolName": "other", "config": map[string]string{}},
+			{"protocolName": "CCIP", "config": map[string]interface{}{"router": ccipRouter, "destChainSelector": uint64(14767482510784806043)}},
+		},
+	})
+	bridge := model.NewOp(&model.CrossChainResponse{Type: model.CrossChainTransfer, SourceChainName: "mumbai", TargetChainName: "fuji", Token: "USDC", Amount: "1", Receiver: receiver, RawResponse: config,
+		Fees: []model.Fee{{Kind: model.FeeBridge, Token: "USDC", Amount: "0.1"}, {Kind: model.FeeBridge, Token: "MATIC", Amount: "0.01"}}})
+	calls, err := Calls(chains, bridge, sender)
+	assert.Nil(t, err)
+	if assert.Len(t, calls, 2) {
+		approve, _ := abi.Approve(ccipRouter, big.NewInt(1000000))
+		send, _ := abi.CCIPSend(14767482510784806043, receiver, usdc, big.NewInt(1000000), "0x0000000000000000000000000000000000000000")
+		assert.Equal(t, model.Call{To: usdc, Value: "0x0", Data: abi.EncodeHex(approve)}, calls[0])
+		assert.Equal(t, model.Call{To: ccipRouter, Value: "0x2386f26fc10000", Data: abi.EncodeHex(send)}, calls[1])
+	}
+
+	// the router reverts on a native fee it is not paid
+	bridge.Body.(*model.CrossChainResponse).Fees = bridge.Body.(*model.CrossChainResponse).Fees[:1]
+	_, err = Calls(chains, bridge, sender)
+	assert.ErrorContains(t, err, "native ccip fee")
+
+	// attached calldata is used as is
+	attached := model.NewOp(&model.CrossChainResponse{Type: model.CrossChainTransfer, SourceChainName: "mumbai", Calldata: []model.Call{{To: receiver, Value: "0x1", Data: "0x"}}})
+	chain, decoded, err := opCalls(chains, attached, 7, sender)
+	assert.Nil(t, err)
+	assert.Equal(t, "mumbai", chain)
+	assert.Equal(t, []call{{step: 7, to: receiver, value: big.NewInt(1), data: []byte{}}}, decoded)
+
+	// swaps approve and spend the input bounded by slippage, paying the sender
+	maxIn := big.NewInt(0).Mul(big.NewInt(201), big.NewInt(1e16))
+	swap := model.NewOp(&model.SwapResponse{ChainName: "mumbai", SourceToken: "DAI", TargetToken: "USDC", SwapIn: "2", MaxAmountIn: "2.01",
+		SwapOut: "1.9", MinAmountOut: "1.9", RawResponse: swapQuote([]int{500}, dai, usdc)})
+	calls, err = Calls(chains, swap, sender)
+	assert.Nil(t, err)
+	if assert.Len(t, calls, 2) {
+		approve, _ := abi.Approve(router, maxIn)
+		assert.Equal(t, abi.EncodeHex(approve), calls[0].Data)
+		send, _ := abi.ExactOutputSingle(abi.SingleSwap{TokenIn: dai, TokenOut: usdc, Fee: 500, Recipient: sender, Amount: big.NewInt(1900000), Limit: maxIn})
+		assert.Equal(t, model.Call{To: router, Value: "0x0", Data: abi.EncodeHex(send)}, calls[1])
+	}
+	_, err = Calls(chains, swap, "")
+	assert.ErrorContains(t, err, "recipient")
+
+	// an exact input paid out natively is unwrapped by the router
+	wmatic := "0x6666f7fea5938fd3b1e26a12c3f2fdd5c2e0f9a1"
+	swap = model.NewOp(&model.SwapResponse{ChainName: "mumbai", SourceToken: "DAI", TargetToken: "MATIC", ExactIn: true, SwapIn: "2", MaxAmountIn: "2",
+		SwapOut: "3", MinAmountOut: "2.985", RawResponse: swapQuote([]int{500, 3000}, dai, usdc, wmatic)})
+	calls, err = Calls(chains, swap, sender)
+	assert.Nil(t, err)
+	if assert.Len(t, calls, 2) {
+		minOut := big.NewInt(0).Mul(big.NewInt(2985), big.NewInt(1e15))
+		path, _ := abi.EncodePath([]string{dai, usdc, wmatic}, []uint32{500, 3000})
+		send, _ := abi.ExactInput(abi.PathSwap{Path: path, Recipient: abi.RouterSelf, Amount: big.NewInt(0).Mul(big.NewInt(2), big.NewInt(1e18)), Limit: minOut})
+		unwrap, _ := abi.UnwrapWETH9(minOut, sender)
+		multicall, _ := abi.Multicall([][]byte{send, unwrap})
+		assert.Equal(t, abi.EncodeHex(multicall), calls[1].Data)
+	}
+
+	// a quote for other tokens or split over routes is not executed
+	swap = model.NewOp(&model.SwapResponse{ChainName: "mumbai", SourceToken: "USDC", TargetToken: "DAI", SwapIn: "2", SwapOut: "1.9", RawResponse: swapQuote([]int{500}, dai, usdc)})
+	_, err = Calls(chains, swap, sender)
+	assert.ErrorContains(t, err, "does not route")
+	split, _ := json.Marshal(map[string]interface{}{"result": map[string]interface{}{
+		"methodParameters": map[string]string{"calldata": "0x5ae401dc", "to": router},
+		"route":            []map[string]interface{}{{"percent": 50}, {"percent": 50}},
+	}})
+	swap.Body.(*model.SwapResponse).RawResponse = split
+	_, err = Calls(chains, swap, sender)
+	assert.ErrorContains(t, err, "2 routes")
+}
@@ -2,6 +2,7 @@ package route
 
 import (
 	"fmt"
+	"io"
 	"net/http"
 
 	"github.com/smarterwallet/demand-abstraction-serv/pkg"
@@ -44,6 +45,9 @@ func (s *HTTPServer) Start() {
 			"message": "ok",
 		})
 	})
+	v1.GET("/schema/op", func(ctx *gin.Context) {
+		ctx.Data(http.StatusOK, "application/schema+json", model.OpSchemaJSON)
+	})
 	v1.POST("/ctx", s.ConversationHistory(), func(ctx *gin.Context) {
 		cid, ok := ctx.Get(model.ConversationID)
 		if !ok {
@@ -82,7 +86,20 @@ func (s *HTTPServer) Start() {
 			SendErrorResponse(ctx, http.StatusBadRequest, errors.New("invalid model"))
 			return
 		}
-		resp, err := s.demandSrv.ChatDemand(ctx, cid.(string), request.Demand)
+		if request.Stream {
+			ctx.Header(model.CIDHeader, cid.(string))
+			events := s.demandSrv.ChatDemandStream(ctx.Request.Context(), cid.(string), request.Demand, request.SlippageBps)
+			ctx.Stream(func(w io.Writer) bool {
+				event, ok := <-events
+				if !ok {
+					return false
+				}
+				ctx.SSEvent(event.Event, event.Data)
+				return true
+			})
+			return
+		}
+		resp, err := s.demandSrv.ChatDemand(ctx, cid.(string), request.Demand, request.SlippageBps)
 		if err != nil {
 			SendErrorResponse(ctx, http.StatusInternalServerError, err)
 			return
@@ -90,6 +107,83 @@ func (s *HTTPServer) Start() {
 		ctx.Header(model.CIDHeader, cid.(string))
 		ctx.JSON(200, resp)
 	})
+	v1.POST("/userops", func(ctx *gin.Context) {
+		var request model.UserOpsRequest
+		if err := ctx.BindJSON(&request); err != nil {
+			SendErrorResponse(ctx, http.StatusBadRequest, err)
+			return
+		}
+		resp, err := s.demandSrv.BuildUserOperations(ctx, ctx.Request.Header.Get(model.CIDHeader), &request)
+		if err != nil {
+			SendErrorResponse(ctx, http.StatusBadRequest, err)
+			return
+		}
+		ctx.JSON(200, resp)
+	})
+	v1.POST("/requote", func(ctx *gin.Context) {
+		var request model.RequoteRequest
+		if err := ctx.BindJSON(&request); err != nil {
+			SendErrorResponse(ctx, http.StatusBadRequest, err)
+			return
+		}
+		resp, err := s.demandSrv.Requote(ctx, ctx.Request.Header.Get(model.CIDHeader), &request)
+		if err != nil {
+			SendErrorResponse(ctx, http.StatusBadRequest, err)
+			return
+		}
+		ctx.JSON(200, resp)
+	})
+	contacts := v1.Group("/contacts")
+	contacts.GET("", func(ctx *gin.Context) {
+		resp, err := s.demandSrv.ListContacts(ctx, ctx.Request.Header.Get(model.CIDHeader))
+		if err != nil {
+			SendErrorResponse(ctx, contactStatus(err), err)
+			return
+		}
+		ctx.JSON(200, resp)
+	})
+	contacts.GET("/:name", func(ctx *gin.Context) {
+		resp, err := s.demandSrv.GetContact(ctx, ctx.Request.Header.Get(model.CIDHeader), ctx.Param("name"))
+		if err != nil {
+			SendErrorResponse(ctx, contactStatus(err), err)
+			return
+		}
+		ctx.JSON(200, resp)
+	})
+	contacts.POST("", func(ctx *gin.Context) {
+		var request model.Contact
+		if err := ctx.BindJSON(&request); err != nil {
+			SendErrorResponse(ctx, http.StatusBadRequest, err)
+			return
+		}
+		resp, err := s.demandSrv.SaveContact(ctx, ctx.Request.Header.Get(model.CIDHeader), &request, true)
+		if err != nil {
+			SendErrorResponse(ctx, contactStatus(err), err)
+			return
+		}
+		ctx.JSON(http.StatusCreated, resp)
+	})
+	contacts.PUT("/:name", func(ctx *gin.Context) {
+		var request model.Contact
+		if err := ctx.BindJSON(&request); err != nil {
+			SendErrorResponse(ctx, http.StatusBadRequest, err)
+			return
+		}
+		request.Name = ctx.Param("name")
+		resp, err := s.demandSrv.SaveContact(ctx, ctx.Request.Header.Get(model.CIDHeader), &request, false)
+		if err != nil {
+			SendErrorResponse(ctx, contactStatus(err), err)
+			return
+		}
+		ctx.JSON(200, resp)
+	})
+	contacts.DELETE("/:name", func(ctx *gin.Context) {
+		if err := s.demandSrv.DeleteContact(ctx, ctx.Request.Header.Get(model.CIDHeader), ctx.Param("name")); err != nil {
+			SendErrorResponse(ctx, contactStatus(err), err)
+			return
+		}
+		ctx.Status(http.StatusNoContent)
+	})
 	log.Infof("server listen on: %s", listenAddr)
 	go func() {
 		if err := s.Run(listenAddr); err != nil && err != http.ErrServerClosed {
@@ -109,7 +203,7 @@ func CORSMiddleware() gin.HandlerFunc {
 		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
 		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
 		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT")
+		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE")
 
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
@@ -137,6 +231,17 @@ func (s *HTTPServer) ConversationHistory() gin.HandlerFunc {
 	}
 }
 
+// contactStatus maps the errors of the contact endpoints to their status.
+func contactStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrContactNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrContactExists):
+		return http.StatusConflict
+	}
+	return http.StatusBadRequest
+}
+
 type ErrorResponse struct {
 	Error struct {
 		Code    int    `json:"code"`
@@ -1,13 +1,16 @@
 package route_test
 
 import (
+	"bufio"
 	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 	"testing"
 
+	"github.com/shopspring/decimal"
 	"github.com/smarterwallet/demand-abstraction-serv/model"
 
 	"github.com/stretchr/testify/assert"
@@ -73,6 +76,16 @@ func TestServer(t *testing.T) {
 		err = chat(cid, "I want to transfer 5 more USDC")
 		assert.Nil(t, err)
 	})
+	t.Run("chat demand stream", func(t *testing.T) {
+		cid, err := startChat()
+		assert.Nil(t, err)
+		err = initBalance(cid)
+		assert.Nil(t, err)
+		events, err := chatStream(cid, "I want to transfer 120USDC to 0x5134F00C95b8e794db38E1eE39397d8086cee7Ed on target chain fuji")
+		assert.Nil(t, err)
+		assert.Contains(t, events, model.StageStrategySelected)
+		assert.Contains(t, events, model.StageResult)
+	})
 	t.Run("no swap + no crosschain", func(t *testing.T) {
 		cid, err := startChat()
 		assert.Nil(t, err)
@@ -109,6 +122,39 @@ func TestServer(t *testing.T) {
 		err = chat(cid, "I want to transfer 120 dollars to 0x5134F00C95b8e794db38E1eE39397d8086cee7Ed on target chain fuji")
 		assert.Nil(t, err)
 	})
+	t.Run("contacts", func(t *testing.T) {
+		cid, err := startChat()
+		assert.Nil(t, err)
+		err = initBalance(cid)
+		assert.Nil(t, err)
+		contact, _ := json.Marshal(&model.Contact{Name: "Alice", Address: "0xd8da6bf26964af9d7eed9e03e53415d37aa96045"})
+		for _, c := range []struct {
+			method string
+			path   string
+			body   []byte
+			status int
+		}{
+			{"POST", "/v1/contacts", contact, http.StatusCreated},
+			{"POST", "/v1/contacts", contact, http.StatusConflict},
+			{"GET", "/v1/contacts/alice", nil, http.StatusOK},
+			{"PUT", "/v1/contacts/Alice", []byte(`{"address":"0x0000000000000000000000000000000000000000"}`), http.StatusBadRequest},
+		} {
+			httpReq, err := http.NewRequest(c.method, "http://127.0.0.1:8080"+c.path, bytes.NewReader(c.body))
+			assert.Nil(t, err)
+			httpReq.Header.Set(model.CIDHeader, cid)
+			resp, err := client.Do(httpReq)
+			assert.Nil(t, err)
+			assert.Equal(t, c.status, resp.StatusCode, c.method+" "+c.path)
+		}
+		err = chat(cid, "I want to transfer 20 USDC to Alice on mumbai")
+		assert.Nil(t, err)
+		httpReq, err := http.NewRequest("DELETE", "http://127.0.0.1:8080/v1/contacts/alice", nil)
+		assert.Nil(t, err)
+		httpReq.Header.Set(model.CIDHeader, cid)
+		resp, err := client.Do(httpReq)
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	})
 
 	// todo test
 	t.Run("no swap + stable + no crosschain", func(t *testing.T) {
@@ -130,8 +176,8 @@ func initBalance(cid string) error {
 		Address:   "0x5134F00C95b8e794db38E1eE39397d8086cee7Ed",
 		BaseChain: "mumbai",
 		Balances: map[string][]model.Reserve{
-			"mumbai": {{Symbol: "USDC", Balance: 100}, {Symbol: "USDT", Balance: 80}, {Symbol: "DAI", Balance: 170}},
-			"fuji":   {{Symbol: "USDC", Balance: 25}, {Symbol: "USDT", Balance: 60}, {Symbol: "DAI", Balance: 90}},
+			"mumbai": {{Symbol: "USDC", Balance: decimal.NewFromInt(100)}, {Symbol: "USDT", Balance: decimal.NewFromInt(80)}, {Symbol: "DAI", Balance: decimal.NewFromInt(170)}},
+			"fuji":   {{Symbol: "USDC", Balance: decimal.NewFromInt(25)}, {Symbol: "USDT", Balance: decimal.NewFromInt(60)}, {Symbol: "DAI", Balance: decimal.NewFromInt(90)}},
 		},
 	}
 	buf, err := json.Marshal(req)
@@ -202,3 +248,36 @@ func chat(cid, demand string) error {
 	fmt.Printf("resp: %+v\n", res)
 	return nil
 }
+
+func chatStream(cid, demand string) ([]string, error) {
+	client := http.Client{}
+	req := &model.DemandRequest{
+		Model:  "v1",
+		Demand: demand,
+		Stream: true,
+	}
+	buf, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	httpReq, err := http.NewRequest("POST", "http://127.0.0.1:8080/v1/chat", bytes.NewReader(buf))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set(model.CIDHeader, cid)
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	events := make([]string, 0)
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "event:") {
+			events = append(events, strings.TrimSpace(strings.TrimPrefix(line, "event:")))
+		}
+		fmt.Printf("stream: %s\n", line)
+	}
+	return events, scanner.Err()
+}
@@ -0,0 +1,122 @@
+package service
+
+import (
+	"context"
+	"strings"
+
+	log "github.com/cihub/seelog"
+	"github.com/pkg/errors"
+
+	"github.com/smarterwallet/demand-abstraction-serv/model"
+	"github.com/smarterwallet/demand-abstraction-serv/pkg/address"
+	"github.com/smarterwallet/demand-abstraction-serv/pkg/strategy"
+)
+
+var (
+	ErrContactNotFound = errors.New("contact not found")
+	ErrContactExists   = errors.New("contact already exists")
+)
+
+// maxContactName bounds the length of contact names.
+const maxContactName = 64
+
+// addressBook loads the contacts of the wallet of demandCtx. A wallet without
+// an address has none.
+func (s *DemandService) addressBook(ctx context.Context, demandCtx *model.CtxRequest) strategy.AddressBook {
+	if demandCtx.Address == "" {
+		return nil
+	}
+	contacts, err := s.cache.Contacts(ctx, demandCtx.Address)
+	if err != nil {
+		log.Errorf("Contacts err=%s\n", err)
+		return nil
+	}
+	return contacts
+}
+
+// contactOwner is the wallet of the conversation cid, whose address book the
+// contact endpoints manage.
+func (s *DemandService) contactOwner(ctx context.Context, cid string) (string, error) {
+	if cid == "" {
+		return "", errors.New("missing cid")
+	}
+	wallet := s.prepareCtx(ctx, cid).Address
+	if wallet == "" {
+		return "", errors.New("address not found")
+	}
+	return wallet, nil
+}
+
+func (s *DemandService) ListContacts(ctx context.Context, cid string) ([]model.Contact, error) {
+	wallet, err := s.contactOwner(ctx, cid)
+	if err != nil {
+		return nil, err
+	}
+	return s.cache.Contacts(ctx, wallet)
+}
+
+func (s *DemandService) GetContact(ctx context.Context, cid, name string) (*model.Contact, error) {
+	wallet, err := s.contactOwner(ctx, cid)
+	if err != nil {
+		return nil, err
+	}
+	contact, err := s.cache.GetContact(ctx, wallet, name)
+	if err != nil {
+		return nil, err
+	}
+	if contact == nil {
+		return nil, ErrContactNotFound
+	}
+	return contact, nil
+}
+
+// SaveContact validates contact and stores it, normalizing its address. A new
+// contact must not exist yet and an updated one must.
+func (s *DemandService) SaveContact(ctx context.Context, cid string, contact *model.Contact, create bool) (*model.Contact, error) {
+	wallet, err := s.contactOwner(ctx, cid)
+	if err != nil {
+		return nil, err
+	}
+	contact.Name = strings.TrimSpace(contact.Name)
+	switch {
+	case contact.Name == "":
+		return nil, errors.New("contact name is empty")
+	case len(contact.Name) > maxContactName:
+		return nil, errors.Errorf("contact name is longer than %d characters", maxContactName)
+	case strings.HasPrefix(strings.ToLower(contact.Name), "0x"):
+		return nil, errors.New("contact name must not be an address")
+	}
+	contact.Chain = strings.ToLower(strings.TrimSpace(contact.Chain))
+	if contact.Address, err = address.Normalize(s.chains.Snapshot(), contact.Chain, contact.Address); err != nil {
+		return nil, err
+	}
+	existing, err := s.cache.GetContact(ctx, wallet, contact.Name)
+	if err != nil {
+		return nil, err
+	}
+	if create && existing != nil {
+		return nil, ErrContactExists
+	}
+	if !create && existing == nil {
+		return nil, ErrContactNotFound
+	}
+	if err := s.cache.SetContact(ctx, wallet, contact); err != nil {
+		return nil, err
+	}
+	return contact, nil
+}
+
+func (s *DemandService) DeleteContact(ctx context.Context, cid, name string) error {
+	wallet, err := s.contactOwner(ctx, cid)
+	if err != nil {
+		return err
+	}
+	deleted, err := s.cache.DeleteContact(ctx, wallet, name)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrContactNotFound
+	}
+	return nil
+}
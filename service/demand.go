@@ -17,10 +17,12 @@ import (
 	"github.com/smarterwallet/demand-abstraction-serv/data"
 
 	"github.com/pkg/errors"
+	"github.com/sashabaranov/go-openai"
 	"github.com/smarterwallet/demand-abstraction-serv/config"
 	"github.com/smarterwallet/demand-abstraction-serv/model"
 	"github.com/smarterwallet/demand-abstraction-serv/pkg/llm"
 	"github.com/smarterwallet/demand-abstraction-serv/pkg/strategy"
+	"github.com/smarterwallet/demand-abstraction-serv/pkg/userop"
 )
 
 type DemandService struct {
@@ -29,28 +31,33 @@ type DemandService struct {
 	cache              *data.Cache
 	localConversations map[string]int64
 	mu                 sync.Mutex
-	tokens             sync.Map
-}
-
-type TokenInfo struct {
-	Symbol  string
-	Address string
-	Decimal int
+	chainAliases       []data.Chain
+	tokenAliases       data.TokenAliases
+	chains             *data.Registry
 }
 
 func NewDemandService(cfg *config.Config) *DemandService {
-	var llmInstance llm.Llm
-	if cfg.AiConfig.APIKey == "" {
-		llmInstance = llm.NewMockOpenAI()
-	} else {
-		llmInstance = llm.NewOpenAI(cfg.AiConfig)
+	llmInstance, err := llm.New(cfg.AiConfig)
+	if err != nil {
+		log.Errorf("init llm error: %v", err)
+		return nil
 	}
 	cache, err := data.NewCache(cfg.Redis)
 	if err != nil {
 		log.Errorf("init cache error: %v", err)
 		return nil
 	}
-	ds := &DemandService{cfg: cfg, llm: llmInstance, cache: cache, localConversations: make(map[string]int64), mu: sync.Mutex{}, tokens: sync.Map{}}
+	chainAliases, err := data.LoadChainAliases(cfg.ChainAliases)
+	if err != nil {
+		log.Errorf("init chain aliases error: %v", err)
+		return nil
+	}
+	tokenAliases, err := data.LoadTokenAliases(cfg.TokenAliases)
+	if err != nil {
+		log.Errorf("init token aliases error: %v", err)
+		return nil
+	}
+	ds := &DemandService{cfg: cfg, llm: llmInstance, cache: cache, localConversations: make(map[string]int64), mu: sync.Mutex{}, chainAliases: chainAliases, tokenAliases: tokenAliases, chains: data.NewRegistry()}
 	if err := ds.loadTokens(); err != nil {
 		log.Errorf("init tokens error: %v", err)
 		return nil
@@ -82,18 +89,10 @@ func (s *DemandService) loadTokens() error {
 		log.Errorf("load tokens error: %v", err)
 		return err
 	}
-	for _, chain := range res.Result.Chain {
-		data.ChainIDMap[strings.ToLower(chain.Name)] = chain.ID
-		tokens := make(map[string]TokenInfo)
-		for _, token := range chain.Tokens {
-			tokens[token.Name] = TokenInfo{
-				Symbol:  token.Name,
-				Address: token.Address,
-				Decimal: token.Decimal,
-			}
-		}
-		s.tokens.Store(strings.ToLower(chain.Name), tokens)
-	}
+	b := data.NewSnapshotBuilder(s.chainAliases, s.tokenAliases)
+	b.AddAssetConfig(res)
+	snap := s.chains.Publish(b)
+	log.Infof("loaded %d chains, version %d", len(res.Result.Chain), snap.Version)
 	return nil
 }
 
@@ -105,32 +104,83 @@ func (s *DemandService) NewChat() string {
 	return cid
 }
 
-func (s *DemandService) analyzeStrategy(ctx context.Context, demand string, demandCtx *model.CtxRequest) strategy.IStrategy {
-	selectStrategy, err := strategy.MatchStrategy("selectStrategy", demandCtx)
+// analyzeStrategy picks the strategy for a demand with the local classifier and
+// only asks the llm when the classifier isn't confident enough.
+func (s *DemandService) analyzeStrategy(ctx context.Context, chains *data.Snapshot, demand string, history []model.Dialogue, demandCtx *model.CtxRequest) (model.Intent, strategy.IStrategy) {
+	guess := strategy.Classify(chains, demand)
+	intent := model.Intent{Category: guess.Category, Confidence: guess.Confidence, Source: model.IntentSourceRule}
+	if guess.Confidence < s.intentThreshold() {
+		// the llm gives no confidence for its choice
+		intent = model.Intent{Category: s.selectStrategy(ctx, chains, demand, history, demandCtx), Source: model.IntentSourceLlm}
+	}
+	log.Infof("selectStrategy=%s confidence=%.2f source=%s\n", intent.Category, intent.Confidence, intent.Source)
+	if intent.Category == "" {
+		return intent, nil
+	}
+	st, err := strategy.MatchStrategy(intent.Category, demandCtx, chains)
 	if err != nil {
-		return nil
+		return intent, nil
 	}
-	name, args, err := s.llm.Chat(ctx, selectStrategy.Prompt(), demand, selectStrategy.Functions())
+	strategy.Progress(ctx, model.StageStrategySelected, model.StrategySelectedEvent{
+		Strategy:   intent.Category,
+		Confidence: intent.Confidence,
+		Source:     intent.Source,
+	})
+	return intent, st
+}
+
+func (s *DemandService) intentThreshold() float64 {
+	if s.cfg.AiConfig.IntentThreshold > 0 {
+		return s.cfg.AiConfig.IntentThreshold
+	}
+	return strategy.DefaultIntentThreshold
+}
+
+// quotePolicy bounds swaps by the slippage of the demand, else of the wallet,
+// else of the config.
+func (s *DemandService) quotePolicy(demandCtx *model.CtxRequest, slippageBps int) strategy.QuotePolicy {
+	policy := strategy.DefaultQuotePolicy
+	if s.cfg.QuoteTTL > 0 {
+		policy.TTL = s.cfg.QuoteTTL
+	}
+	for _, bps := range []int{s.cfg.DefaultSlippageBps, demandCtx.SlippageBps, slippageBps} {
+		if bps > 0 {
+			policy.SlippageBps = bps
+		}
+	}
+	return policy
+}
+
+func validSlippage(bps int) error {
+	if bps < 0 || bps > model.MaxSlippageBps {
+		return errors.Errorf("slippage must be between 0 and %d bps", model.MaxSlippageBps)
+	}
+	return nil
+}
+
+// selectStrategy asks the llm to choose the strategy of an ambiguous demand.
+func (s *DemandService) selectStrategy(ctx context.Context, chains *data.Snapshot, demand string, history []model.Dialogue, demandCtx *model.CtxRequest) string {
+	selectStrategy, err := strategy.MatchStrategy("selectStrategy", demandCtx, chains)
 	if err != nil {
-		log.Errorf("analyzeStrategy err=%v\n", err)
-		return nil
+		return ""
+	}
+	messages := llm.BuildMessages(selectStrategy.Prompt(), history, demand)
+	calls, err := s.chatValid(ctx, selectStrategy, messages, nil)
+	if err != nil || len(calls) == 0 {
+		log.Errorf("selectStrategy err=%v\n", err)
+		return ""
 	}
 	type selectStrategyArgs struct {
 		Strategy string `json:"strategy"`
 	}
-	if name == "select_strategy" {
+	if call := calls[0].Function; call.Name == "select_strategy" {
 		in := selectStrategyArgs{}
-		if err := json.Unmarshal([]byte(args), &in); err != nil {
-			return nil
+		if err := json.Unmarshal([]byte(call.Arguments), &in); err != nil {
+			return ""
 		}
-		log.Infof("selectStrategy=%s\n", in.Strategy)
-		st, err := strategy.MatchStrategy(in.Strategy, demandCtx)
-		if err != nil {
-			return nil
-		}
-		return st
+		return in.Strategy
 	}
-	return nil
+	return ""
 }
 
 func (s *DemandService) InitCtx(ctx context.Context, cid string, req *model.CtxRequest) error {
@@ -138,22 +188,25 @@ func (s *DemandService) InitCtx(ctx context.Context, cid string, req *model.CtxR
 	if req.Address == "" {
 		return errors.New("address not found")
 	}
-	_, ok := s.tokens.Load(strings.ToLower(req.BaseChain))
-	if !ok {
+	if err := validSlippage(req.SlippageBps); err != nil {
+		return err
+	}
+	chains := s.chains.Snapshot()
+	if _, ok := chains.Tokens(req.BaseChain); !ok {
 		return errors.New("chain not found")
 	}
 	userBalances := make(map[string][]model.Reserve)
 	for c, reserves := range req.Balances {
-		if _, ok := s.tokens.Load(c); !ok {
+		if _, ok := chains.Tokens(c); !ok {
 			return errors.New("chain not found")
 		}
 		for i, reserve := range reserves {
-			mm, ok := s.tokens.Load(c)
-			tt, ok := mm.(map[string]TokenInfo)[reserve.Symbol]
-			if !ok {
-				return errors.New("token not found symbol")
+			token, err := chains.ResolveToken(c, reserve.Symbol)
+			if err != nil {
+				return err
 			}
-			reserves[i].Address = tt.Address
+			reserves[i].Symbol = strings.ToUpper(token.Symbol)
+			reserves[i].Address = token.Address
 		}
 		userBalances[c] = reserves
 	}
@@ -164,33 +217,411 @@ func (s *DemandService) InitCtx(ctx context.Context, cid string, req *model.CtxR
 	return nil
 }
 
-func (s *DemandService) ChatDemand(ctx context.Context, cid, demand string) (*model.DemandResponse, error) {
-	demandCtx := s.prepareCtx(ctx, cid)
-	st := s.analyzeStrategy(ctx, demand, demandCtx)
-	if st == nil {
-		return nil, errors.New("strategy not found")
+func (s *DemandService) ChatDemand(ctx context.Context, cid, demand string, slippageBps int) (*model.DemandResponse, error) {
+	if err := validSlippage(slippageBps); err != nil {
+		return nil, err
 	}
+	chains := s.chains.Snapshot()
+	demandCtx := s.prepareCtx(ctx, cid)
+	ctx = strategy.WithQuotePolicy(ctx, s.quotePolicy(demandCtx, slippageBps))
 	history, err := s.getHistory(ctx, cid)
 	if err != nil {
 		log.Errorf("getHistory err=%s\n", err)
 		return nil, err
 	}
-	injectedPrompt := fmt.Sprintf("%s, Here is the chat history:%s", st.Prompt(), history)
-	name, args, err := s.llm.Chat(ctx, injectedPrompt, demand, st.Functions())
+	pending, err := s.cache.GetPending(ctx, cid)
 	if err != nil {
-		return nil, errors.Wrap(err, "ChatDemand")
+		log.Errorf("GetPending err=%s\n", err)
+	}
+	book := s.addressBook(ctx, demandCtx)
+	var (
+		intent    model.Intent
+		st        strategy.IStrategy
+		calls     []openai.ToolCall
+		confirmed bool
+	)
+	if pending != nil {
+		intent = model.Intent{Category: pending.Strategy, Confidence: 1, Source: model.IntentSourcePending}
+		switch {
+		case len(pending.Confirm) > 0 && isAffirmative(demand):
+			st, calls = s.confirmPending(chains, pending, demandCtx)
+			confirmed = st != nil
+		case len(pending.Confirm) > 0 && isNegative(demand):
+			if err := s.cache.ClearPending(ctx, cid); err != nil {
+				log.Errorf("ClearPending err=%s\n", err)
+			}
+			resp := &model.DemandResponse{Category: pending.Strategy, Detail: model.DetailResp{Reply: "Ok, I won't do it."}, Intent: &intent}
+			if err := s.appendToHistory(ctx, cid, demand, nil, nil); err != nil {
+				log.Errorf("appendToHistory err=%s\n", err)
+				return nil, err
+			}
+			return resp, nil
+		default:
+			st, calls = s.answerPending(ctx, chains, pending, demand, history, demandCtx, book)
+			if st == nil {
+				if err := s.cache.ClearPending(ctx, cid); err != nil {
+					log.Errorf("ClearPending err=%s\n", err)
+				}
+				pending = nil
+			}
+		}
+	}
+	if st == nil {
+		intent, st = s.analyzeStrategy(ctx, chains, demand, history, demandCtx)
+		if st == nil {
+			return nil, errors.New("strategy not found")
+		}
+		messages := llm.BuildMessages(st.Prompt(), history, demand)
+		calls, err = s.chatValid(ctx, st, messages, book)
+		if err != nil {
+			return nil, errors.Wrap(err, "ChatDemand")
+		}
+	}
+	calls, resolutions := strategy.ResolveContacts(st, calls, book)
+	for _, call := range calls {
+		strategy.Progress(ctx, model.StageArgumentsExtracted, model.ArgumentsExtractedEvent{
+			Function:  call.Function.Name,
+			Arguments: json.RawMessage(call.Function.Arguments),
+		})
+	}
+	resp, ok := s.clarify(ctx, cid, intent.Category, st, calls)
+	if !ok && !confirmed {
+		resp, ok = s.verify(ctx, cid, intent.Category, st, calls, evidence(demand, history, demandCtx, resolutions))
+	}
+	if ok {
+		resp.Detail.Reply = withNotes(resolutions, resp.Detail.Reply)
+		resp.Intent = &intent
+		if err := s.appendToHistory(ctx, cid, demand, calls, []*model.DemandResponse{resp}); err != nil {
+			log.Errorf("appendToHistory err=%s\n", err)
+			return nil, err
+		}
+		return resp, nil
+	}
+	if pending != nil {
+		if err := s.cache.ClearPending(ctx, cid); err != nil {
+			log.Errorf("ClearPending err=%s\n", err)
+		}
 	}
-	resp := &model.DemandResponse{}
-	if err := st.Render(resp, name, args); err != nil {
+	resp = &model.DemandResponse{}
+	steps, err := strategy.RenderPlan(ctx, st, chains, demandCtx, resp, calls)
+	if err != nil {
 		return nil, errors.Wrap(err, "ChatDemand")
 	}
-	if err := s.appendToHistory(ctx, cid, demand, resp.Detail.Reply); err != nil {
+	resp.Detail.Reply = withNotes(resolutions, resp.Detail.Reply)
+	resp.Intent = &intent
+	if err := s.appendToHistory(ctx, cid, demand, calls, steps); err != nil {
 		log.Errorf("appendToHistory err=%s\n", err)
 		return nil, err
 	}
 	return resp, nil
 }
 
+const defaultRepairAttempts = 2
+
+// chatValid asks the llm for the function calls of st and validates their
+// arguments, with receivers named after contacts of book as their address.
+// Invalid calls are answered with the validation errors as tool results and the
+// llm is asked again, up to the configured repair attempts, or the default ones
+// when none are configured.
+func (s *DemandService) chatValid(ctx context.Context, st strategy.IStrategy, messages []openai.ChatCompletionMessage, book strategy.AddressBook) ([]openai.ToolCall, error) {
+	attempts := defaultRepairAttempts
+	if n := s.cfg.AiConfig.RepairAttempts; n != nil && *n >= 0 {
+		attempts = *n
+	}
+	for attempt := 0; ; attempt++ {
+		calls, err := s.llm.Chat(ctx, messages, st.Functions())
+		if err != nil {
+			return nil, err
+		}
+		results := make([]string, len(calls))
+		resolved, _ := strategy.ResolveContacts(st, calls, book)
+		var invalid error
+		for i, call := range resolved {
+			if err := strategy.Validate(st, call.Function.Name, call.Function.Arguments); err != nil {
+				results[i] = err.Error()
+				invalid = err
+				continue
+			}
+			results[i] = "ok"
+		}
+		if invalid == nil {
+			return calls, nil
+		}
+		if attempt >= attempts {
+			return nil, errors.Wrapf(invalid, "giving up after %d repair attempts", attempts)
+		}
+		log.Warnf("chatValid attempt=%d err=%v\n", attempt+1, invalid)
+		messages = append(messages, openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant, ToolCalls: calls})
+		for i, call := range calls {
+			messages = append(messages, openai.ChatCompletionMessage{
+				Role:       openai.ChatMessageRoleTool,
+				Content:    results[i],
+				ToolCallID: call.ID,
+			})
+		}
+		messages = append(messages, openai.ChatCompletionMessage{
+			Role:    openai.ChatMessageRoleSystem,
+			Content: "Some function arguments were invalid. Call the functions again with every argument corrected.",
+		})
+	}
+}
+
+// answerPending merges the user's answer into the intent waiting for a missing
+// argument, validating a bare answer like the arguments of the llm. It returns
+// a nil strategy when the answer doesn't fill the missing argument, so the
+// demand is analyzed from scratch.
+func (s *DemandService) answerPending(ctx context.Context, chains *data.Snapshot, pending *model.PendingIntent, demand string, history []model.Dialogue, demandCtx *model.CtxRequest, book strategy.AddressBook) (strategy.IStrategy, []openai.ToolCall) {
+	st, err := strategy.MatchStrategy(pending.Strategy, demandCtx, chains)
+	if err != nil {
+		return nil, nil
+	}
+	answers, err := s.chatValid(ctx, st, llm.BuildMessages(st.Prompt(), history, demand), book)
+	if err != nil {
+		log.Warnf("answerPending err=%v\n", err)
+	}
+	used := make([]bool, len(answers))
+	calls := make([]openai.ToolCall, 0, len(pending.Calls))
+	for _, pendingCall := range pending.Calls {
+		args := pendingCall.Args
+		for i, answer := range answers {
+			if used[i] || answer.Function.Name != pendingCall.Function {
+				continue
+			}
+			if update, err := strategy.DecodeArgs(answer.Function.Arguments); err == nil {
+				args = strategy.MergeArgs(args, update)
+			}
+			used[i] = true
+			break
+		}
+		buf, _ := json.Marshal(args)
+		calls = append(calls, openai.ToolCall{
+			Type:     openai.ToolTypeFunction,
+			Function: openai.FunctionCall{Name: pendingCall.Function, Arguments: string(buf)},
+		})
+	}
+	answered := false
+	for i, pendingCall := range pending.Calls {
+		asked, _ := json.Marshal(pendingCall.Args)
+		if strategy.SlotFilled(st, pendingCall.Function, string(asked), pending.Missing) {
+			continue
+		}
+		slot, args, missing := strategy.MissingSlot(st, calls[i].Function.Name, calls[i].Function.Arguments)
+		if missing && slot.Name == pending.Missing && strategy.FillSlot(st, calls[i].Function.Name, args, slot.Name, demand) {
+			buf, _ := json.Marshal(args)
+			if err := strategy.Validate(st, calls[i].Function.Name, string(buf)); err != nil {
+				log.Warnf("answerPending slot=%s err=%v\n", slot.Name, err)
+			} else {
+				calls[i].Function.Arguments = string(buf)
+			}
+		}
+		answered = strategy.SlotFilled(st, calls[i].Function.Name, calls[i].Function.Arguments, pending.Missing)
+		break
+	}
+	if !answered {
+		return nil, nil
+	}
+	return st, calls
+}
+
+// clarify stores the calls as a pending intent and replies with a question when
+// one of them misses an argument the user has to provide.
+func (s *DemandService) clarify(ctx context.Context, cid, category string, st strategy.IStrategy, calls []openai.ToolCall) (*model.DemandResponse, bool) {
+	pending := &model.PendingIntent{Strategy: category}
+	for _, call := range calls {
+		slot, args, missing := strategy.MissingSlot(st, call.Function.Name, call.Function.Arguments)
+		pending.Calls = append(pending.Calls, model.PendingCall{Function: call.Function.Name, Args: args})
+		if missing && pending.Missing == "" {
+			pending.Missing = slot.Name
+			pending.Question = slot.Question
+		}
+	}
+	if pending.Missing == "" {
+		return nil, false
+	}
+	if err := s.cache.SetPending(ctx, cid, pending); err != nil {
+		log.Errorf("SetPending err=%s\n", err)
+	}
+	return &model.DemandResponse{
+		Category: category,
+		Detail:   model.DetailResp{Reply: pending.Question},
+	}, true
+}
+
+// verifyHistoryTurns is the number of recent user messages values of a demand
+// may come from.
+const verifyHistoryTurns = 5
+
+// evidence collects the values the user typed in the demand and recent
+// messages; their own wallet address and the contacts they named count as
+// typed.
+func evidence(demand string, history []model.Dialogue, demandCtx *model.CtxRequest, resolutions []strategy.Resolution) strategy.Evidence {
+	texts := []string{demand, demandCtx.Address}
+	for _, r := range resolutions {
+		if r.Contact != nil {
+			texts = append(texts, r.Contact.Address)
+		}
+	}
+	turns := 0
+	for i := len(history) - 1; i >= 0 && turns < verifyHistoryTurns; i-- {
+		if history[i].Role == model.DialogueRoleUser && history[i].Type == model.DialogueText {
+			texts = append(texts, history[i].Content)
+			turns++
+		}
+	}
+	return strategy.CollectEvidence(texts...)
+}
+
+// verify stores the calls as a pending intent when the llm extracted values the
+// user never typed. A made up receiver is dropped and asked for again, since a
+// transfer to a wrong address can't be undone; other values are replied back
+// for the user to confirm.
+func (s *DemandService) verify(ctx context.Context, cid, category string, st strategy.IStrategy, calls []openai.ToolCall, ev strategy.Evidence) (*model.DemandResponse, bool) {
+	pending := &model.PendingIntent{Strategy: category}
+	var receiver string
+	for _, call := range calls {
+		args, err := strategy.DecodeArgs(call.Function.Arguments)
+		if err != nil {
+			return nil, false
+		}
+		for _, u := range strategy.Verify(st, call.Function.Name, args, ev) {
+			if u.Kind == strategy.TraceAddress {
+				receiver = u.Value
+				delete(args, u.Arg)
+				pending.Missing = u.Arg
+				continue
+			}
+			pending.Confirm = append(pending.Confirm, u.String())
+		}
+		pending.Calls = append(pending.Calls, model.PendingCall{Function: call.Function.Name, Args: args})
+	}
+	switch {
+	case pending.Missing != "":
+		pending.Confirm = nil
+		pending.Question = fmt.Sprintf("I couldn't find the receiver %s in your messages. Which address should receive the transfer?", receiver)
+	case len(pending.Confirm) > 0:
+		pending.Question = fmt.Sprintf("Please confirm %s, which I couldn't find in your message. Reply yes to continue or tell me the correct values.", strings.Join(pending.Confirm, " and "))
+	default:
+		return nil, false
+	}
+	if err := s.cache.SetPending(ctx, cid, pending); err != nil {
+		log.Errorf("SetPending err=%s\n", err)
+	}
+	return &model.DemandResponse{
+		Category: category,
+		Detail:   model.DetailResp{Reply: pending.Question},
+	}, true
+}
+
+// confirmPending returns the calls of a pending intent the user confirmed.
+func (s *DemandService) confirmPending(chains *data.Snapshot, pending *model.PendingIntent, demandCtx *model.CtxRequest) (strategy.IStrategy, []openai.ToolCall) {
+	st, err := strategy.MatchStrategy(pending.Strategy, demandCtx, chains)
+	if err != nil {
+		return nil, nil
+	}
+	calls := make([]openai.ToolCall, 0, len(pending.Calls))
+	for _, pendingCall := range pending.Calls {
+		buf, _ := json.Marshal(pendingCall.Args)
+		calls = append(calls, openai.ToolCall{
+			Type:     openai.ToolTypeFunction,
+			Function: openai.FunctionCall{Name: pendingCall.Function, Arguments: string(buf)},
+		})
+	}
+	return st, calls
+}
+
+var (
+	affirmatives = map[string]bool{"yes": true, "y": true, "yeah": true, "yep": true, "ok": true, "okay": true, "sure": true, "confirm": true, "confirmed": true, "correct": true, "go ahead": true, "yes please": true}
+	negatives    = map[string]bool{"no": true, "n": true, "nope": true, "cancel": true, "stop": true, "abort": true}
+)
+
+func isAffirmative(answer string) bool {
+	return affirmatives[normalizeAnswer(answer)]
+}
+
+func isNegative(answer string) bool {
+	return negatives[normalizeAnswer(answer)]
+}
+
+func normalizeAnswer(answer string) string {
+	return strings.ToLower(strings.Trim(strings.TrimSpace(answer), ".!,"))
+}
+
+// withNotes prefixes reply with what the receivers named by the user were
+// resolved to.
+func withNotes(resolutions []strategy.Resolution, reply string) string {
+	notes := make([]string, 0, len(resolutions)+1)
+	for _, r := range resolutions {
+		notes = append(notes, r.Note())
+	}
+	if reply != "" {
+		notes = append(notes, reply)
+	}
+	return strings.Join(notes, " ")
+}
+
+// ChatDemandStream runs ChatDemand in the background and delivers every stage on
+// the returned channel, ending with a result or error event before it is closed.
+func (s *DemandService) ChatDemandStream(ctx context.Context, cid, demand string, slippageBps int) <-chan model.StreamEvent {
+	events := make(chan model.StreamEvent, 8)
+	send := func(stage string, data interface{}) {
+		select {
+		case events <- model.StreamEvent{Event: stage, Data: data}:
+		case <-ctx.Done():
+		}
+	}
+	go func() {
+		defer close(events)
+		resp, err := s.ChatDemand(strategy.WithProgress(ctx, send), cid, demand, slippageBps)
+		if err != nil {
+			send(model.StageError, err.Error())
+			return
+		}
+		send(model.StageResult, resp)
+	}()
+	return events
+}
+
+// BuildUserOperations encodes the ops of a plan as unsigned UserOperations.
+func (s *DemandService) BuildUserOperations(ctx context.Context, cid string, req *model.UserOpsRequest) (*userop.Result, error) {
+	sender := req.Sender
+	if sender == "" && cid != "" {
+		sender = s.prepareCtx(ctx, cid).Address
+	}
+	if sender == "" {
+		return nil, errors.New("sender not found")
+	}
+	return userop.Build(sender, req.Ops, userop.Options{
+		Nonces:               req.Nonces,
+		InitCodes:            req.InitCodes,
+		MaxFeePerGas:         req.MaxFeePerGas,
+		MaxPriorityFeePerGas: req.MaxPriorityFeePerGas,
+		PaymasterData:        req.PaymasterData,
+		Chains:               s.chains.Snapshot(),
+	})
+}
+
+// Requote refreshes the expired swap quotes of a plan, with the slippage of the
+// request or of the wallet of the conversation.
+func (s *DemandService) Requote(ctx context.Context, cid string, req *model.RequoteRequest) (*model.RequoteResponse, error) {
+	if err := validSlippage(req.SlippageBps); err != nil {
+		return nil, err
+	}
+	demandCtx := &model.CtxRequest{}
+	if cid != "" {
+		demandCtx = s.prepareCtx(ctx, cid)
+	}
+	ctx = strategy.WithQuotePolicy(ctx, s.quotePolicy(demandCtx, req.SlippageBps))
+	sender := req.Sender
+	if sender == "" {
+		sender = demandCtx.Address
+	}
+	requoted, err := strategy.Requote(ctx, s.chains.Snapshot(), sender, req.Ops, req.Force)
+	if err != nil {
+		return nil, err
+	}
+	return &model.RequoteResponse{Ops: req.Ops, Requoted: requoted, Fees: strategy.FeeSummary(req.Ops)}, nil
+}
+
 func (s *DemandService) prepareCtx(ctx context.Context, cid string) *model.CtxRequest {
 	demandCtx := &model.CtxRequest{}
 	res, err := s.cache.GetCtx(ctx, cid)
@@ -202,25 +633,31 @@ func (s *DemandService) prepareCtx(ctx context.Context, cid string) *model.CtxRe
 	return demandCtx
 }
 
-func (s *DemandService) getHistory(ctx context.Context, cid string) (string, error) {
+func (s *DemandService) getHistory(ctx context.Context, cid string) ([]model.Dialogue, error) {
 	dialogues, err := s.cache.ChatHistory(ctx, cid)
 	if err != nil {
-		return "", err
-	}
-	history, err := json.Marshal(dialogues)
-	if err != nil {
-		return "", err
+		return nil, err
 	}
-	log.Infof("cid:%s history: %s\n", cid, string(history))
-	return string(history), nil
+	log.Infof("cid:%s history dialogues: %d\n", cid, len(dialogues))
+	return dialogues, nil
 }
 
-func (s *DemandService) appendToHistory(ctx context.Context, cid, demand, reply string) error {
-	if err := s.cache.AppendChat(ctx, cid, demand, model.DialogueRoleUser); err != nil {
-		return err
-	}
-	if err := s.cache.AppendChat(ctx, cid, reply, model.DialogueRoleAI); err != nil {
-		return err
+// appendToHistory stores the demand, each function the llm called and its
+// rendered result as consecutive turns, so follow-up demands are replayed with
+// full context.
+func (s *DemandService) appendToHistory(ctx context.Context, cid, demand string, calls []openai.ToolCall, steps []*model.DemandResponse) error {
+	dialogues := []*model.Dialogue{{Type: model.DialogueText, Role: model.DialogueRoleUser, Content: demand}}
+	for i, step := range steps {
+		call := calls[i]
+		callID := "call_" + uuid.NewString()
+		result := step.Detail.Reply
+		if result == "" {
+			result = step.Summary
+		}
+		dialogues = append(dialogues,
+			&model.Dialogue{Type: model.DialogueToolCall, Role: model.DialogueRoleAI, Name: call.Function.Name, Content: call.Function.Arguments, ToolCallID: callID},
+			&model.Dialogue{Type: model.DialogueText, Role: model.DialogueRoleTool, Content: result, ToolCallID: callID},
+		)
 	}
-	return nil
+	return s.cache.AppendDialogues(ctx, cid, dialogues...)
 }
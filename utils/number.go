@@ -3,7 +3,8 @@ package utils
 import (
 	"fmt"
 	"math/big"
-	"strings"
+
+	"github.com/shopspring/decimal"
 )
 
 func String2BigFloat(s string) (*big.Float, error) {
@@ -16,12 +17,15 @@ func String2BigFloat(s string) (*big.Float, error) {
 	return f, nil
 }
 
-func Float2String(f float64) string {
-	// 将浮点数转换为字符串（不使用科学记数法）
-	s := fmt.Sprintf("%f", f)
-	// 去除字符串末尾的零
-	s = strings.TrimRight(s, "0")
-	// 如果字符串以小数点结尾，则也去除小数点
-	s = strings.TrimRight(s, ".")
-	return s
+// ToBaseUnits converts a token amount to its integer amount in the token's
+// smallest unit, e.g. 1.5 USDC with 6 decimals is 1500000. Digits beyond the
+// token decimals are truncated, so the result never exceeds the amount.
+func ToBaseUnits(amount decimal.Decimal, decimals int) *big.Int {
+	return amount.Shift(int32(decimals)).Truncate(0).BigInt()
+}
+
+// FromBaseUnits converts an integer amount in the token's smallest unit back
+// to a token amount.
+func FromBaseUnits(raw *big.Int, decimals int) decimal.Decimal {
+	return decimal.NewFromBigInt(raw, -int32(decimals))
 }
@@ -0,0 +1,20 @@
+package utils
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBaseUnits(t *testing.T) {
+	amount := decimal.RequireFromString("1234567.123456789012345678")
+	raw := ToBaseUnits(amount, 18)
+	assert.Equal(t, "1234567123456789012345678", raw.String())
+	assert.True(t, amount.Equal(FromBaseUnits(raw, 18)))
+
+	assert.Equal(t, "1500000", ToBaseUnits(decimal.RequireFromString("1.5"), 6).String())
+	assert.Equal(t, "1", ToBaseUnits(decimal.RequireFromString("0.0000019"), 6).String())
+	assert.Equal(t, "0.000001", FromBaseUnits(big.NewInt(1), 6).String())
+}
@@ -0,0 +1,48 @@
+package utils
+
+import "strings"
+
+// EditDistance is the Levenshtein distance between a and b, ignoring case.
+func EditDistance(a, b string) int {
+	ra := []rune(strings.ToLower(a))
+	rb := []rune(strings.ToLower(b))
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min3(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
+
+// MaxTypos is the edit distance tolerated when matching a name of n
+// characters: none for very short names, one up to six characters, two above.
+func MaxTypos(n int) int {
+	switch {
+	case n <= 2:
+		return 0
+	case n <= 6:
+		return 1
+	}
+	return 2
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
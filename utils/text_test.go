@@ -0,0 +1,16 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEditDistance(t *testing.T) {
+	assert.Equal(t, 0, EditDistance("Alice", "alice"))
+	assert.Equal(t, 1, EditDistance("alice", "alise"))
+	assert.Equal(t, 1, EditDistance("fuji", "fuj"))
+	assert.Equal(t, 3, EditDistance("kitten", "sitting"))
+	assert.Equal(t, 4, EditDistance("", "goli"))
+	assert.Equal(t, 1, MaxTypos(len("alice")))
+}